@@ -0,0 +1,138 @@
+// Package apperror はクライアントに返すエラーの種類と安定したコードを定義する。
+// HTTP のステータスへの対応づけは controller.HTTPErrorHandler で行う
+package apperror
+
+import (
+	"errors"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Kind はエラーの種類
+type Kind int
+
+const (
+	KindInternal        Kind = iota // 予期しないエラー（内容はクライアントに返さない）
+	KindBadRequest                  // リクエストの形式が不正
+	KindValidation                  // 入力値の検証エラー（項目ごとの内容を持つ）
+	KindUnauthorized                // 認証に失敗した
+	KindForbidden                   // 権限がない
+	KindNotFound                    // リソースが存在しない（他のユーザーのものを含む）
+	KindConflict                    // 既存のリソースと競合する
+	KindUpstreamFailure             // 外部サービス（ストレージ、音楽生成など）の失敗
+	KindUnprocessable               // 形式は正しいが処理できない内容（プロバイダーが拒否したプロンプトなど）
+	KindTooManyRequests             // 呼び出しの上限に達している（時間をおけば成功する）
+	KindUnavailable                 // 一時的に利用できない（時間をおけば成功する）
+)
+
+// Error はクライアントに返すコードとメッセージを持つエラー
+type Error struct {
+	Kind    Kind
+	Code    string            // フロントエンドが分岐に使うコード (diary_not_found など)
+	Message string            // クライアントに返す説明
+	Fields  map[string]string // 項目ごとの検証エラー (KindValidation のみ)
+	Err     error             // 元のエラー（ログにのみ出力する）
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is は種類とコードが同じ場合に一致とみなす（errors.Is(err, usecase.ErrDiaryNotFound) のように使う）
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind && t.Code == e.Code
+}
+
+// Wrap は元のエラーを付けたコピーを返す
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+func newError(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error {
+	return newError(KindBadRequest, code, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return newError(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return newError(KindForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return newError(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return newError(KindConflict, code, message)
+}
+
+func Unprocessable(code, message string) *Error {
+	return newError(KindUnprocessable, code, message)
+}
+
+func TooManyRequests(code, message string) *Error {
+	return newError(KindTooManyRequests, code, message)
+}
+
+func Unavailable(code, message string) *Error {
+	return newError(KindUnavailable, code, message)
+}
+
+// UpstreamFailure は外部サービスの失敗を表す（err の内容はクライアントに返さない）
+func UpstreamFailure(code, message string, err error) *Error {
+	return newError(KindUpstreamFailure, code, message).Wrap(err)
+}
+
+// Validation は ozzo-validation の検証エラーを項目ごとの内容を持つエラーに変換する。
+// 検証ルール自体の失敗 (validation.InternalError) はそのまま返す
+func Validation(err error) error {
+	var internal validation.InternalError
+	if errors.As(err, &internal) {
+		return err
+	}
+	fields := map[string]string{}
+	var errs validation.Errors
+	if errors.As(err, &errs) {
+		flattenErrors(fields, "", errs)
+	} else {
+		fields[""] = err.Error()
+	}
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "validation_failed",
+		Message: "Validation failed",
+		Fields:  fields,
+		Err:     err,
+	}
+}
+
+// flattenErrors は validation.Each などで入れ子になったエラーを "events.0" のようなキーにする
+func flattenErrors(fields map[string]string, prefix string, errs validation.Errors) {
+	for key, err := range errs {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		var nested validation.Errors
+		if errors.As(err, &nested) {
+			flattenErrors(fields, key, nested)
+			continue
+		}
+		fields[key] = err.Error()
+	}
+}
@@ -0,0 +1,288 @@
+// Package config はアプリケーションの設定を読み込み、起動時に検証する。
+// 値はデフォルト値 < 設定ファイル (YAML / TOML) < 環境変数 < コマンドラインフラグ の順に上書きされる。
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type Config struct {
+	Env             string        `env:"GO_ENV"`                         // dev の場合は .env を読み込む
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"` // 停止時に処理中のリクエストやジョブを待つ時間
+	HTTP            HTTPConfig
+	Auth            AuthConfig
+	Admin           AdminConfig
+	Log             LogConfig
+	Database        DatabaseConfig
+	Music           MusicConfig
+	Storage         StorageConfig
+	Asset           AssetConfig
+	Webhook         WebhookConfig
+	Mail            MailConfig
+	Export          ExportConfig
+	Metrics         MetricsConfig
+	Tracing         TracingConfig
+}
+
+type HTTPConfig struct {
+	Port        int    `env:"PORT" default:"8080"`
+	APIDomain   string `env:"API_DOMAIN"` // Cookieのドメイン
+	FrontendURL string `env:"FE_URL"`     // CORSで許可するオリジン
+}
+
+// Addr は Echo の Start に渡すアドレス
+func (c HTTPConfig) Addr() string {
+	return fmt.Sprintf(":%d", c.Port)
+}
+
+type AuthConfig struct {
+	Secret               string        `env:"SECRET"`                                       // JWTの署名鍵
+	AccessTokenTTL       time.Duration `env:"ACCESS_TOKEN_TTL" default:"15m"`               // アクセストークン（Cookie: token）の有効期間
+	RefreshTokenTTL      time.Duration `env:"REFRESH_TOKEN_TTL" default:"720h"`             // 最後に更新してからセッションが切れるまでの期間
+	PasswordResetTTL     time.Duration `env:"PASSWORD_RESET_TTL" default:"1h"`              // パスワード再設定のリンクの有効期間
+	EmailVerificationTTL time.Duration `env:"EMAIL_VERIFICATION_TTL" default:"48h"`         // メールアドレス確認のリンクの有効期間
+	DeletionGracePeriod  time.Duration `env:"ACCOUNT_DELETION_GRACE_PERIOD" default:"720h"` // アカウントの削除を取り消せる期間（過ぎるとデータを削除する）
+}
+
+type AdminConfig struct {
+	UserIDs []uint `env:"ADMIN_USER_IDS"` // /debug/status などを使えるユーザー（カンマ区切り）
+}
+
+// IsAdmin はユーザーが管理者かを返す
+func (c AdminConfig) IsAdmin(userId uint) bool {
+	for _, id := range c.UserIDs {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+type LogConfig struct {
+	Level  string `env:"LOG_LEVEL" default:"info"`  // debug | info | warn | error
+	Format string `env:"LOG_FORMAT" default:"json"` // json | text
+}
+
+type DatabaseConfig struct {
+	User     string `env:"POSTGRES_USER"`
+	Password string `env:"POSTGRES_PW"`
+	Host     string `env:"POSTGRES_HOST" default:"localhost"`
+	Port     int    `env:"POSTGRES_PORT" default:"5432"`
+	Name     string `env:"POSTGRES_DB"`
+}
+
+// DSN は接続文字列を返す
+func (c DatabaseConfig) DSN() string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   fmt.Sprintf("%s:%d", c.Host, c.Port),
+		Path:   c.Name,
+	}
+	return u.String()
+}
+
+type MusicConfig struct {
+	Provider         string `env:"MUSIC_PROVIDER" default:"topmediai"` // topmediai | stub
+	Workers          int    `env:"MUSIC_WORKERS" default:"2"`
+	PromptMaxLength  int    `env:"PROMPT_MAX_LENGTH" default:"200"`
+	QuotaPlans       string `env:"MUSIC_QUOTA_PLANS"` // "free=5/60,pro=30/600"（空の場合はデフォルトのプラン）
+	TopMediaiBaseURL string `env:"TOPMEDIAI_BASE_URL" default:"https://api.topmediai.com/v1"`
+	TopMediaiAPIKey  string `env:"API_KEY"`
+	StubBaseURL      string `env:"STUB_BASE_URL" default:"http://localhost:8080"`
+}
+
+type StorageConfig struct {
+	Backend  string `env:"STORAGE_BACKEND" default:"local"` // local | s3
+	LocalDir string `env:"STORAGE_LOCAL_DIR" default:"./data/assets"`
+	S3       S3Config
+}
+
+type S3Config struct {
+	Endpoint  string `env:"S3_ENDPOINT"`
+	AccessKey string `env:"S3_ACCESS_KEY"`
+	SecretKey string `env:"S3_SECRET_KEY"`
+	Bucket    string `env:"S3_BUCKET"`
+	Region    string `env:"S3_REGION"`
+	UseSSL    bool   `env:"S3_USE_SSL"`
+}
+
+type AssetConfig struct {
+	BaseURL    string `env:"ASSET_BASE_URL" default:"http://localhost:8080"`
+	SigningKey string `env:"ASSET_SIGNING_KEY"` // dev 以外では必須。SECRET と同じ値は使えない
+}
+
+type MailConfig struct {
+	Backend      string `env:"MAIL_BACKEND" default:"outbox"` // smtp | outbox（ファイルに書き出してログに出力する。開発用）
+	From         string `env:"MAIL_FROM" default:"diary-music <no-reply@localhost>"`
+	LinkBaseURL  string `env:"MAIL_LINK_BASE_URL"` // メールに載せるリンクのベースURL（空の場合は FE_URL）
+	OutboxDir    string `env:"MAIL_OUTBOX_DIR" default:"./data/outbox"`
+	SMTPHost     string `env:"SMTP_HOST"`
+	SMTPPort     int    `env:"SMTP_PORT" default:"587"` // STARTTLS に対応していれば使う
+	SMTPUser     string `env:"SMTP_USER"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+}
+
+type ExportConfig struct {
+	LinkTTL   time.Duration `env:"EXPORT_LINK_TTL" default:"24h"`   // ダウンロードの署名付きURLの有効期間
+	Retention time.Duration `env:"EXPORT_RETENTION" default:"168h"` // 作成したアーカイブを残す期間（過ぎるとストレージから削除する）
+}
+
+type WebhookConfig struct {
+	AllowPrivate bool `env:"WEBHOOK_ALLOW_PRIVATE"` // プライベートアドレスへの送信を許可する（開発用）
+}
+
+type MetricsConfig struct {
+	Token string `env:"METRICS_TOKEN"` // 設定した場合 /metrics に "Authorization: Bearer <token>" を要求する
+}
+
+type TracingConfig struct {
+	Exporter     string  `env:"TRACING_EXPORTER" default:"none"` // none | otlp | stdout | file
+	File         string  `env:"TRACING_FILE" default:"traces.jsonl"`
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"` // 未設定の場合は OTEL_EXPORTER_OTLP_ENDPOINT (デフォルト localhost:4318)
+	OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE"` // HTTP で送信する
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+// Validate はサーバーの起動に必要な値を検証する
+func (c *Config) Validate() error {
+	return validation.Errors{
+		"ShutdownTimeout": validation.Validate(c.ShutdownTimeout, validation.Min(time.Second)),
+		"HTTP":            c.HTTP.Validate(),
+		"Auth":            c.Auth.Validate(),
+		"Database":        c.Database.Validate(),
+		"Music":           c.Music.Validate(),
+		"Storage":         c.Storage.Validate(),
+		"Asset":           c.Asset.Validate(c.Env == "dev", c.Auth.Secret),
+		"Mail":            c.Mail.Validate(),
+		"Export":          c.Export.Validate(),
+		"Log":             c.Log.Validate(),
+		"Tracing":         c.Tracing.Validate(),
+	}.Filter()
+}
+
+func (c HTTPConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Port, validation.Required, validation.Max(65535)),
+	)
+}
+
+func (c LogConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Level, validation.In("debug", "info", "warn", "error")),
+		validation.Field(&c.Format, validation.In("json", "text")),
+	)
+}
+
+func (c TracingConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Exporter, validation.In("none", "otlp", "stdout", "file")),
+		validation.Field(&c.File, validation.When(c.Exporter == "file", validation.Required)),
+		validation.Field(&c.SampleRatio, validation.Min(0.0), validation.Max(1.0)),
+	)
+}
+
+func (c AuthConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Secret, validation.Required.Error("SECRET is required")),
+		validation.Field(&c.AccessTokenTTL, validation.Min(time.Minute)),
+		validation.Field(&c.RefreshTokenTTL, validation.Min(c.AccessTokenTTL)),
+		validation.Field(&c.PasswordResetTTL, validation.Min(time.Minute)),
+		validation.Field(&c.EmailVerificationTTL, validation.Min(time.Minute)),
+		validation.Field(&c.DeletionGracePeriod, validation.Min(time.Duration(0))),
+	)
+}
+
+func (c DatabaseConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.User, validation.Required.Error("POSTGRES_USER is required")),
+		validation.Field(&c.Host, validation.Required.Error("POSTGRES_HOST is required")),
+		validation.Field(&c.Port, validation.Required, validation.Max(65535)),
+		validation.Field(&c.Name, validation.Required.Error("POSTGRES_DB is required")),
+	)
+}
+
+func (c MusicConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Workers, validation.Min(1)),
+		validation.Field(&c.PromptMaxLength, validation.Min(1)),
+		validation.Field(&c.TopMediaiAPIKey,
+			validation.When(strings.EqualFold(c.Provider, "topmediai"), validation.Required.Error("API_KEY is required for the topmediai provider"))),
+		validation.Field(&c.TopMediaiBaseURL,
+			validation.When(strings.EqualFold(c.Provider, "topmediai"), validation.Required)),
+		validation.Field(&c.StubBaseURL,
+			validation.When(strings.EqualFold(c.Provider, "stub"), validation.Required)),
+	)
+}
+
+func (c StorageConfig) Validate() error {
+	if err := validation.ValidateStruct(&c,
+		validation.Field(&c.Backend, validation.In("local", "s3")),
+	); err != nil {
+		return err
+	}
+	if c.Backend != "s3" {
+		return nil
+	}
+	return validation.ValidateStruct(&c.S3,
+		validation.Field(&c.S3.Endpoint, validation.Required.Error("S3_ENDPOINT is required")),
+		validation.Field(&c.S3.Bucket, validation.Required.Error("S3_BUCKET is required")),
+	)
+}
+
+// Validate は dev 以外で署名鍵を必須にし、JWT の署名鍵 (authSecret) との使い回しを禁止する
+func (c AssetConfig) Validate(dev bool, authSecret string) error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.BaseURL, validation.Required),
+		validation.Field(&c.SigningKey,
+			validation.When(!dev, validation.Required.Error("ASSET_SIGNING_KEY is required outside GO_ENV=dev")),
+			validation.When(authSecret != "", validation.NotIn(authSecret).Error("ASSET_SIGNING_KEY must differ from SECRET")),
+		),
+	)
+}
+
+func (c ExportConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.LinkTTL, validation.Min(time.Minute)),
+		validation.Field(&c.Retention, validation.Min(time.Hour)),
+	)
+}
+
+func (c MailConfig) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Backend, validation.In("smtp", "outbox")),
+		validation.Field(&c.From, validation.Required),
+		validation.Field(&c.SMTPHost, validation.When(c.Backend == "smtp", validation.Required.Error("SMTP_HOST is required"))),
+		validation.Field(&c.SMTPPort, validation.Max(65535)),
+	)
+}
+
+// MailLinkBaseURL はメールに載せるリンクのベースURLを返す
+func (c *Config) MailLinkBaseURL() string {
+	if c.Mail.LinkBaseURL != "" {
+		return strings.TrimRight(c.Mail.LinkBaseURL, "/")
+	}
+	if c.HTTP.FrontendURL != "" {
+		return strings.TrimRight(c.HTTP.FrontendURL, "/")
+	}
+	return "http://localhost:3000"
+}
+
+// AssetSigningKey は署名付きURLの署名鍵を返す。
+// 未設定の場合 (dev のみ) は SECRET をそのまま使わず、用途を表すラベルとの HMAC で別の鍵を導出する
+func (c *Config) AssetSigningKey() string {
+	if c.Asset.SigningKey != "" {
+		return c.Asset.SigningKey
+	}
+	mac := hmac.New(sha256.New, []byte(c.Auth.Secret))
+	mac.Write([]byte("diary-music asset url signing"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAssetConfigValidateSigningKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		dev     bool
+		wantErr string
+	}{
+		{"dedicated key", "asset-key", false, ""},
+		{"missing outside dev", "", false, "required"},
+		{"missing in dev", "", true, ""},
+		{"reuses SECRET", "secret", false, "differ from SECRET"},
+		{"reuses SECRET in dev", "secret", true, "differ from SECRET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := AssetConfig{BaseURL: "http://localhost:8080", SigningKey: tt.key}
+			err := c.Validate(tt.dev, "secret")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAssetSigningKeyDoesNotReuseSecret(t *testing.T) {
+	c := &Config{Auth: AuthConfig{Secret: "secret"}}
+	derived := c.AssetSigningKey()
+	if derived == "" || derived == "secret" {
+		t.Fatalf("derived key = %q, want a key distinct from SECRET", derived)
+	}
+	if again := c.AssetSigningKey(); again != derived {
+		t.Fatalf("derived key is not stable: %q != %q", again, derived)
+	}
+	c.Asset.SigningKey = "asset-key"
+	if got := c.AssetSigningKey(); got != "asset-key" {
+		t.Fatalf("AssetSigningKey() = %q, want the configured key", got)
+	}
+}
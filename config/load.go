@@ -0,0 +1,207 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load は args をフラグとして解釈し、設定を読み込む（検証は行わない）。
+//
+//	-config path   設定ファイル (.yaml / .yml / .toml)。未指定の場合は CONFIG_FILE
+//	-port N        PORT を上書きする
+//	-set KEY=VALUE 任意の値を上書きする（複数指定可）
+//
+// 設定ファイルのキーは環境変数と同じ名前を使う。入れ子にした場合は "_" で連結する
+// （postgres: {host: db} は POSTGRES_HOST）。
+func Load(args []string) (*Config, error) {
+	overrides := map[string]string{}
+	flags := flag.NewFlagSet("diary-music", flag.ContinueOnError)
+	path := flags.String("config", "", "path to a YAML or TOML config file")
+	port := flags.String("port", "", "HTTP port")
+	flags.Func("set", "override a config value (KEY=VALUE)", func(s string) error {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("expected KEY=VALUE, got %q", s)
+		}
+		overrides[strings.ToUpper(key)] = value
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *port != "" {
+		overrides["PORT"] = *port
+	}
+
+	// 開発環境のみ.envファイルを読み込む（既に設定されている環境変数は上書きしない）
+	if os.Getenv("GO_ENV") == "dev" {
+		if err := godotenv.Load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if *path == "" {
+		*path = os.Getenv("CONFIG_FILE")
+	}
+	file := map[string]string{}
+	if *path != "" {
+		var err error
+		if file, err = readFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	// フラグ → 環境変数 → 設定ファイル の順に探す。空の値は設定されていないものとして次を探す
+	// （docker compose などで FOO= のように空で渡された環境変数が設定ファイルの値を消さないように）
+	lookup := func(key string) (string, bool) {
+		if v := overrides[key]; v != "" {
+			return v, true
+		}
+		if v := os.Getenv(key); v != "" {
+			return v, true
+		}
+		if v := file[key]; v != "" {
+			return v, true
+		}
+		return "", false
+	}
+
+	cfg := &Config{}
+	if err := fill(reflect.ValueOf(cfg).Elem(), lookup); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// fill は env タグの付いたフィールドに値を設定する
+func fill(v reflect.Value, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := fill(value, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		// どこにも設定されていない場合のみデフォルト値を使う
+		raw, ok := lookup(key)
+		if !ok {
+			raw, ok = field.Tag.Lookup("default")
+		}
+		if !ok {
+			continue
+		}
+		if err := setValue(value, raw); err != nil {
+			return fmt.Errorf("config %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		// カンマ区切りの値
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Uint:
+		n, err := strconv.ParseUint(raw, 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// readFile は設定ファイルを読み込み、環境変数と同じ形式のキーに平坦化する
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file type %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	values := map[string]string{}
+	flatten("", tree, values)
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]interface{}, values map[string]string) {
+	for key, value := range tree {
+		key = strings.ToUpper(prefix + key)
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(key+"_", v, values)
+		case nil:
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("GO_ENV", "")
+	t.Setenv("CONFIG_FILE", "")
+	path := writeConfigFile(t, "port: 9000\npostgres:\n  host: file-db\n  port: 5434\n  user: file-user\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		port int
+		host string
+		user string
+	}{
+		{"file", nil, nil, 9000, "file-db", "file-user"},
+		{"env overrides file", map[string]string{"PORT": "9100", "POSTGRES_HOST": "env-db"}, nil, 9100, "env-db", "file-user"},
+		{"empty env falls back to file", map[string]string{"PORT": "", "POSTGRES_HOST": "", "POSTGRES_USER": ""}, nil, 9000, "file-db", "file-user"},
+		{"flags override env", map[string]string{"PORT": "9100", "POSTGRES_HOST": "env-db"}, []string{"-port", "9200", "-set", "postgres_host=flag-db"}, 9200, "flag-db", "file-user"},
+		{"empty flag falls back to env", map[string]string{"POSTGRES_HOST": "env-db"}, []string{"-set", "POSTGRES_HOST="}, 9000, "env-db", "file-user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"PORT", "POSTGRES_HOST", "POSTGRES_USER"} {
+				if v, ok := tt.env[key]; ok {
+					t.Setenv(key, v)
+				} else {
+					t.Setenv(key, "")
+					os.Unsetenv(key)
+				}
+			}
+			cfg, err := Load(append([]string{"-config", path}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.HTTP.Port != tt.port || cfg.Database.Host != tt.host || cfg.Database.User != tt.user {
+				t.Errorf("got port=%d host=%q user=%q, want port=%d host=%q user=%q",
+					cfg.HTTP.Port, cfg.Database.Host, cfg.Database.User, tt.port, tt.host, tt.user)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("GO_ENV", "")
+	t.Setenv("CONFIG_FILE", "")
+	// 空の環境変数はデフォルト値を消さない
+	t.Setenv("PORT", "")
+	t.Setenv("POSTGRES_PORT", "")
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Port != 8080 {
+		t.Errorf("HTTP.Port = %d, want default 8080", cfg.HTTP.Port)
+	}
+	if cfg.Database.Port != 5432 {
+		t.Errorf("Database.Port = %d, want default 5432", cfg.Database.Port)
+	}
+}
+
+func TestLoadInvalidValue(t *testing.T) {
+	t.Setenv("GO_ENV", "")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("PORT", "not-a-number")
+	if _, err := Load(nil); err == nil {
+		t.Error("Load() with an invalid PORT should fail")
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// IAccountController はパスワードの再設定とメールアドレスの確認を行う
+type IAccountController interface {
+	ForgotPassword(c echo.Context) error
+	ResetPassword(c echo.Context) error
+	VerifyEmail(c echo.Context) error
+	ResendVerification(c echo.Context) error
+	DeleteAccount(c echo.Context) error
+	CancelDeletion(c echo.Context) error
+}
+
+type accountController struct {
+	au  usecase.IAccountUsecase
+	du  usecase.IAccountDeletionUsecase
+	cfg config.HTTPConfig
+}
+
+func NewAccountController(au usecase.IAccountUsecase, du usecase.IAccountDeletionUsecase, cfg config.HTTPConfig) IAccountController {
+	return &accountController{au, du, cfg}
+}
+
+// ForgotPassword は再設定のメールを送る。メールアドレスが登録されているかどうかに関わらず 202 を返す
+func (ac *accountController) ForgotPassword(c echo.Context) error {
+	req := model.PasswordForgotRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	if err := ac.au.ForgotPassword(c.Request().Context(), req.Email); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ResetPassword はメールのトークンでパスワードを変更する（ログイン中の端末はすべてログアウトされる）
+func (ac *accountController) ResetPassword(c echo.Context) error {
+	req := model.PasswordResetRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	if err := ac.au.ResetPassword(c.Request().Context(), req); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// VerifyEmail はメールのトークンでメールアドレスを確認済みにする（ログインしていなくても使える）
+func (ac *accountController) VerifyEmail(c echo.Context) error {
+	req := model.EmailVerifyRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	if err := ac.au.VerifyEmail(c.Request().Context(), req.Token); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResendVerification はログイン中のユーザーに確認メールを再送する
+func (ac *accountController) ResendVerification(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	if err := ac.au.SendVerification(c.Request().Context(), userId); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// DeleteAccount はパスワードを確認してアカウントの削除を予定し、ログアウトさせる。
+// 猶予期間を過ぎるとバックグラウンドでデータを削除する
+func (ac *accountController) DeleteAccount(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.AccountDeletionRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	res, err := ac.du.RequestDeletion(c.Request().Context(), userId, req)
+	if err != nil {
+		return err
+	}
+	clearAuthCookies(c, ac.cfg)
+	return c.JSON(http.StatusAccepted, res)
+}
+
+// CancelDeletion は削除を始める前であれば予定を取り消す
+func (ac *accountController) CancelDeletion(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	if err := ac.du.CancelDeletion(c.Request().Context(), userId); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/storage"
+	"github.com/labstack/echo/v4"
+)
+
+// IAssetController は署名付きURLで保存済みの音声・カバー画像を配信する
+type IAssetController interface {
+	GetAsset(c echo.Context) error
+}
+
+type AssetController struct {
+	st     storage.IStorage
+	signer *storage.URLSigner
+}
+
+func NewAssetController(st storage.IStorage, signer *storage.URLSigner) IAssetController {
+	return &AssetController{st, signer}
+}
+
+func (ac *AssetController) GetAsset(c echo.Context) error {
+	key := c.Param("*")
+	if err := ac.signer.Verify(key, c.QueryParam("expires"), c.QueryParam("signature")); err != nil {
+		return apperror.Forbidden("invalid_signature", err.Error()).Wrap(err)
+	}
+
+	obj, info, err := ac.st.Open(c.Request().Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return apperror.NotFound("asset_not_found", "Asset not found").Wrap(err)
+	}
+	if err != nil {
+		return apperror.UpstreamFailure("storage_unavailable", "Storage is unavailable", err)
+	}
+	defer obj.Close()
+
+	// エクスポートのアーカイブはブラウザで開かずに保存させる
+	if info.ContentType == "application/zip" {
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", path.Base(key)))
+	}
+	// 署名付きURLの有効期限内はブラウザにキャッシュさせる
+	serveObject(c, obj, info, "private, max-age=3600")
+	return nil
+}
+
+// serveObject はストレージのオブジェクトを返す。
+// Range / If-Range / If-None-Match / If-Modified-Since は http.ServeContent が処理する。
+func serveObject(c echo.Context, obj storage.Object, info *storage.ObjectInfo, cacheControl string) {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, info.ContentType)
+	header.Set("Cache-Control", cacheControl)
+	header.Set("Accept-Ranges", "bytes")
+	if info.ETag != "" {
+		header.Set("ETag", `"`+info.ETag+`"`)
+	}
+	http.ServeContent(c.Response(), c.Request(), path.Base(info.Key), info.ModTime, obj)
+}
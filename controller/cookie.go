@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	accessTokenCookie  = "token"         // アクセストークン（JWT）
+	refreshTokenCookie = "refresh_token" // リフレッシュトークン（POST /refresh と /logout で使う）
+)
+
+// setAuthCookies はログイン・トークンの更新で発行したトークンを Cookie に設定する
+func setAuthCookies(c echo.Context, cfg config.HTTPConfig, tokens model.AuthTokens) {
+	c.SetCookie(newAuthCookie(cfg, accessTokenCookie, tokens.AccessToken, tokens.AccessExpiresAt))
+	c.SetCookie(newAuthCookie(cfg, refreshTokenCookie, tokens.RefreshToken, tokens.RefreshExpiresAt))
+}
+
+// clearAuthCookies はトークンの Cookie を削除する
+func clearAuthCookies(c echo.Context, cfg config.HTTPConfig) {
+	c.SetCookie(newAuthCookie(cfg, accessTokenCookie, "", time.Now()))
+	c.SetCookie(newAuthCookie(cfg, refreshTokenCookie, "", time.Now()))
+}
+
+func newAuthCookie(cfg config.HTTPConfig, name, value string, expires time.Time) *http.Cookie {
+	cookie := new(http.Cookie)    // Cookieの生成
+	cookie.Name = name            // Cookie名
+	cookie.Value = value          // Cookie値
+	cookie.Expires = expires      // 有効期限
+	cookie.Path = "/"             // パス
+	cookie.Domain = cfg.APIDomain // ドメイン
+	cookie.Secure = true
+	cookie.HttpOnly = true                  // JavaScriptからのアクセスを禁止
+	cookie.SameSite = http.SameSiteNoneMode // SameSite属性
+	return cookie
+}
+
+// refreshTokenFromCookie は Cookie のリフレッシュトークンを返す（ない場合は空文字列）
+func refreshTokenFromCookie(c echo.Context) string {
+	cookie, err := c.Cookie(refreshTokenCookie)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// sessionClient はログインした端末の情報を返す
+func sessionClient(c echo.Context) model.SessionClient {
+	return model.SessionClient{
+		UserAgent: c.Request().UserAgent(),
+		IPAddress: c.RealIP(),
+	}
+}
@@ -43,9 +43,9 @@ func (dc *diaryController) GetAllDiaries(c echo.Context) error {
 		pageSize = 10
 	}
 	// GetAllDiariesメソッドを呼び出し
-	response, err := dc.du.GetAllDiaries(userId, page, pageSize)
+	response, err := dc.du.GetAllDiaries(c.Request().Context(), userId, page, pageSize)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
 	}
 
 	return c.JSON(http.StatusOK, response)
@@ -57,11 +57,13 @@ func (dc *diaryController) GetDiaryById(c echo.Context) error {
 	claims := user.Claims.(jwt.MapClaims)
 	userId := claims["user_id"]
 
-	id := c.Param("diaryId")
-	diaryId, _ := strconv.Atoi(id)
-	diaries, err := dc.du.GetDiaryById(uint(userId.(float64)), uint(diaryId))
+	diaryId, err := paramID(c, "diaryId")
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
+	}
+	diaries, err := dc.du.GetDiaryById(c.Request().Context(), uint(userId.(float64)), diaryId)
+	if err != nil {
+		return err
 	}
 	return c.JSON(http.StatusOK, diaries)
 }
@@ -74,9 +76,9 @@ func (dc *diaryController) GetDiaryDates(c echo.Context) error {
 	year := c.QueryParam("year")
 	month := c.QueryParam("month")
 
-	dates, err := dc.du.GetDiaryDates(userId, year, month)
+	dates, err := dc.du.GetDiaryDates(c.Request().Context(), userId, year, month)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
 	}
 	return c.JSON(http.StatusOK, dates)
 }
@@ -89,7 +91,7 @@ func (dc *diaryController) CreateDiary(c echo.Context) error {
 
 	diary := model.Diary{}
 	if err := c.Bind(&diary); err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return bindError(err)
 	}
 	diary.UserId = uint(userId.(float64))
 
@@ -119,11 +121,16 @@ func (dc *diaryController) CreateDiary(c echo.Context) error {
 	// 	UpdatedAt: updatedAt,
 	// }
 
-	diaryRes, err := dc.du.CreateDiaryWithMusic(&diary)
+	// 音楽はバックグラウンドで生成されるため 202 を返す
+	// 音楽生成の上限に達している・メールアドレスが未確認の場合は日記のみ保存して 201 と music_skipped を返す
+	diaryRes, err := dc.du.CreateDiaryWithMusic(c.Request().Context(), &diary)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
 	}
-	return c.JSON(http.StatusOK, diaryRes)
+	if diaryRes.MusicSkip != nil {
+		return c.JSON(http.StatusCreated, diaryRes)
+	}
+	return c.JSON(http.StatusAccepted, diaryRes)
 }
 
 func (dc *diaryController) UpdateDiary(c echo.Context) error {
@@ -132,15 +139,17 @@ func (dc *diaryController) UpdateDiary(c echo.Context) error {
 	claims := user.Claims.(jwt.MapClaims)
 	userId := claims["user_id"]
 
-	id := c.Param("diaryId")
-	taskId, _ := strconv.Atoi(id)
+	diaryId, err := paramID(c, "diaryId")
+	if err != nil {
+		return err
+	}
 	diary := model.Diary{}
 	if err := c.Bind(&diary); err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return bindError(err)
 	}
-	diaryRes, err := dc.du.UpdateDiary(uint(userId.(float64)), uint(taskId), diary)
+	diaryRes, err := dc.du.UpdateDiary(c.Request().Context(), uint(userId.(float64)), diaryId, diary)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
 	}
 	return c.JSON(http.StatusOK, diaryRes)
 }
@@ -151,11 +160,12 @@ func (dc *diaryController) DeleteDiary(c echo.Context) error {
 	claims := user.Claims.(jwt.MapClaims)
 	userId := claims["user_id"]
 
-	id := c.Param("diaryId")
-	taskId, _ := strconv.Atoi(id)
-	err := dc.du.DeleteDiary(uint(userId.(float64)), uint(taskId))
+	diaryId, err := paramID(c, "diaryId")
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
+	}
+	if err := dc.du.DeleteDiary(c.Request().Context(), uint(userId.(float64)), diaryId); err != nil {
+		return err
 	}
 	return c.NoContent(http.StatusNoContent)
 }
@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// MIMEApplicationProblemJSON は RFC 7807 のエラーレスポンスの Content-Type
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// エラーの種類ごとのステータスコード
+var kindStatus = map[apperror.Kind]int{
+	apperror.KindBadRequest:      http.StatusBadRequest,
+	apperror.KindValidation:      http.StatusBadRequest,
+	apperror.KindUnauthorized:    http.StatusUnauthorized,
+	apperror.KindForbidden:       http.StatusForbidden,
+	apperror.KindNotFound:        http.StatusNotFound,
+	apperror.KindConflict:        http.StatusConflict,
+	apperror.KindUpstreamFailure: http.StatusBadGateway,
+	apperror.KindUnprocessable:   http.StatusUnprocessableEntity,
+	apperror.KindTooManyRequests: http.StatusTooManyRequests,
+	apperror.KindUnavailable:     http.StatusServiceUnavailable,
+}
+
+// HTTPErrorHandler はハンドラーやミドルウェアが返したエラーを RFC 7807 の problem+json で返す。
+// 500 の場合は内容を返さない（エラーの内容はリクエストログに出力される）
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	problem := toProblem(err)
+	problem.Instance = c.Request().URL.Path
+	problem.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+	// 音楽生成の上限に達している場合は再び生成できるまでの秒数を返す
+	var quotaErr *usecase.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		setRetryAfter(c, quotaErr.ResetsAt)
+	}
+
+	var werr error
+	if c.Request().Method == http.MethodHead {
+		werr = c.NoContent(problem.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+		werr = c.JSON(problem.Status, problem)
+	}
+	if werr != nil {
+		slog.ErrorContext(c.Request().Context(), "failed to write error response", "error", werr)
+	}
+}
+
+// toProblem はエラーをレスポンスの内容に変換する
+func toProblem(err error) model.Problem {
+	var quotaErr *usecase.QuotaExceededError
+	var appErr *apperror.Error
+	var httpErr *echo.HTTPError
+	// 音楽生成プロバイダーのエラーがそのまま返された場合も種類に応じたステータスにする
+	if providerErr := usecase.ProviderError(err); providerErr != nil && !errors.As(err, &appErr) {
+		err = providerErr
+	}
+	switch {
+	case errors.As(err, &quotaErr):
+		problem := newProblem(http.StatusTooManyRequests, "quota_exceeded", quotaErr.Error())
+		problem.ResetsAt = &quotaErr.ResetsAt
+		return problem
+	case errors.As(err, &appErr):
+		status, ok := kindStatus[appErr.Kind]
+		if !ok {
+			break
+		}
+		problem := newProblem(status, appErr.Code, appErr.Message)
+		problem.Errors = appErr.Fields
+		return problem
+	case errors.As(err, &httpErr):
+		// ルートが見つからない、JWT・CSRF の検証に失敗したなど Echo やミドルウェアが返したエラー
+		if httpErr.Code >= http.StatusInternalServerError {
+			break
+		}
+		code := statusCode(httpErr.Code)
+		if errors.Is(err, middleware.ErrCSRFInvalid) {
+			code = "invalid_csrf_token"
+		}
+		detail, _ := httpErr.Message.(string)
+		return newProblem(httpErr.Code, code, detail)
+	}
+	return newProblem(http.StatusInternalServerError, statusCode(http.StatusInternalServerError), "")
+}
+
+func newProblem(status int, code, detail string) model.Problem {
+	return model.Problem{
+		Type:   model.ProblemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// statusCode はステータスの説明からコードを作る (404 → "not_found")
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// bindError はリクエストボディを読み取れなかったことを表すエラーを返す
+func bindError(err error) error {
+	return apperror.BadRequest("invalid_request_body", "Invalid request format").Wrap(err)
+}
+
+// paramID はパスパラメーターのIDを読み取る
+func paramID(c echo.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		return 0, apperror.BadRequest("invalid_parameter", "Invalid "+name).Wrap(err)
+	}
+	return uint(id), nil
+}
+
+// setRetryAfter は再び生成できるまでの秒数を Retry-After ヘッダーに設定する
+func setRetryAfter(c echo.Context, at time.Time) {
+	seconds := int(math.Ceil(time.Until(at).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+}
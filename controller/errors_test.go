@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/service"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+func TestToProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"provider unavailable", &service.ProviderError{Provider: "topmediai", StatusCode: 500, Kind: service.ErrProviderUnavailable}, http.StatusServiceUnavailable, "music_provider_unavailable"},
+		{"circuit open", fmt.Errorf("create music: %w", service.ErrCircuitOpen), http.StatusServiceUnavailable, "music_provider_unavailable"},
+		{"provider rate limited", &service.ProviderError{Provider: "topmediai", StatusCode: 429, Kind: service.ErrProviderQuotaExceeded}, http.StatusTooManyRequests, "music_provider_rate_limited"},
+		{"invalid prompt", &service.ProviderError{Provider: "topmediai", StatusCode: 400, Kind: service.ErrProviderBadPrompt}, http.StatusUnprocessableEntity, "invalid_prompt"},
+		{"provider auth failed", &service.ProviderError{Provider: "topmediai", StatusCode: 401, Kind: service.ErrProviderAuthFailed}, http.StatusBadGateway, "music_provider_error"},
+		{"app error wrapping provider error", usecase.ErrMusicNotFound.Wrap(service.ErrProviderUnavailable), http.StatusNotFound, "music_not_found"},
+		{"quota exceeded", &usecase.QuotaExceededError{Period: "daily", Limit: 5, Used: 5, ResetsAt: time.Now()}, http.StatusTooManyRequests, "quota_exceeded"},
+		{"echo error", echo.NewHTTPError(http.StatusNotFound, "Not Found"), http.StatusNotFound, "not_found"},
+		{"unexpected", errors.New("boom"), http.StatusInternalServerError, "internal_server_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := toProblem(tt.err)
+			if problem.Status != tt.status || problem.Code != tt.code {
+				t.Errorf("toProblem() = %d %s, want %d %s", problem.Status, problem.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestToProblemHidesProviderMessage(t *testing.T) {
+	err := &service.ProviderError{Provider: "topmediai", StatusCode: 503, Message: "upstream secret detail", Kind: service.ErrProviderUnavailable}
+	problem := toProblem(err)
+	if problem.Detail != usecase.ErrProviderUnavailable.Message {
+		t.Errorf("Detail = %q, want %q", problem.Detail, usecase.ErrProviderUnavailable.Message)
+	}
+}
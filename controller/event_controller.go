@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/event"
+	"github.com/labstack/echo/v4"
+)
+
+// 接続を維持するためのコメントを送る間隔
+const sseHeartbeatInterval = 25 * time.Second
+
+// IEventController はServer-Sent Eventsでユーザーにイベントを配信する
+type IEventController interface {
+	Stream(c echo.Context) error
+}
+
+type EventController struct {
+	broker *event.Broker
+}
+
+func NewEventController(broker *event.Broker) IEventController {
+	return &EventController{broker}
+}
+
+func (ec *EventController) Stream(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	// EventSource は再接続時に Last-Event-ID ヘッダーを送る。
+	// 初回接続でも指定できるようクエリパラメータも受け付ける
+	lastID := c.Request().Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.QueryParam("last_event_id")
+	}
+	lastEventID, _ := strconv.ParseUint(lastID, 10, 64)
+
+	replay, events, cancel := ec.broker.Subscribe(userId, lastEventID)
+	defer cancel()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // リバースプロキシのバッファリングを無効化
+	res.WriteHeader(http.StatusOK)
+	// 再接続までの待ち時間（ミリ秒）
+	fmt.Fprint(res, "retry: 3000\n\n")
+	for _, ev := range replay {
+		writeEvent(res, ev)
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				// 受信が追いつかず切断された。クライアントは再接続して続きを受け取る
+				return nil
+			}
+			writeEvent(res, ev)
+			res.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": ping\n\n")
+			res.Flush()
+		}
+	}
+}
+
+func writeEvent(res *echo.Response, ev event.Event) {
+	fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// IExportController は個人データのエクスポートを受け付け、ダウンロードのリンクを返す
+type IExportController interface {
+	CreateExport(c echo.Context) error
+	GetExports(c echo.Context) error
+	GetExportById(c echo.Context) error
+}
+
+type exportController struct {
+	eu usecase.IExportUsecase
+}
+
+func NewExportController(eu usecase.IExportUsecase) IExportController {
+	return &exportController{eu}
+}
+
+// CreateExport はエクスポートを受け付ける。アーカイブはバックグラウンドで作成し、できたらメールで知らせる
+func (ec *exportController) CreateExport(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	res, err := ec.eu.RequestExport(c.Request().Context(), userId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, res)
+}
+
+func (ec *exportController) GetExports(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	res, err := ec.eu.GetExports(c.Request().Context(), userId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetExportById は状態を返す。作成済みの場合は download_url に新しい署名付きURLを付ける
+func (ec *exportController) GetExportById(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+	exportId, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	res, err := ec.eu.GetExportById(c.Request().Context(), userId, exportId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// IHealthController はロードバランサーと運用者向けの状態を返す
+type IHealthController interface {
+	Healthz(c echo.Context) error
+	Readyz(c echo.Context) error
+	GetDebugStatus(c echo.Context) error
+}
+
+type healthController struct {
+	su usecase.IStatusUsecase
+}
+
+func NewHealthController(su usecase.IStatusUsecase) IHealthController {
+	return &healthController{su}
+}
+
+// Healthz はプロセスが動いていれば常に 200 を返す
+func (hc *healthController) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{"status": model.HealthStatusOK})
+}
+
+// Readyz は依存先の状態を返す。リクエストを受け付けられない場合は 503 を返す
+func (hc *healthController) Readyz(c echo.Context) error {
+	res := hc.su.Ready(c.Request().Context())
+	if res.Status == model.HealthStatusUnavailable {
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (hc *healthController) GetDebugStatus(c echo.Context) error {
+	status, err := hc.su.DebugStatus(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, status)
+}
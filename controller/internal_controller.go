@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/kenta-kenta/diary-music/service"
+	"github.com/labstack/echo/v4"
+)
+
+// IInternalController は運用向けの内部ステータスを返す
+type IInternalController interface {
+	GetStatus(c echo.Context) error
+}
+
+type InternalController struct {
+	provider string
+	cb       *service.CircuitBreaker
+}
+
+func NewInternalController(provider string, cb *service.CircuitBreaker) IInternalController {
+	return &InternalController{provider, cb}
+}
+
+func (ic *InternalController) GetStatus(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{
+		"music_provider": ic.cb.Status(ic.provider),
+	})
+}
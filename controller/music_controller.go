@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/apperror"
 	"github.com/kenta-kenta/diary-music/model"
 	"github.com/kenta-kenta/diary-music/usecase"
 	"github.com/labstack/echo/v4"
@@ -13,6 +14,11 @@ import (
 type IMusicController interface {
 	CreateMusic(c echo.Context) error
 	GetMusicsList(c echo.Context) error
+	GetMusicTakes(c echo.Context) error
+	GetMusicStatus(c echo.Context) error
+	SetPrimaryMusic(c echo.Context) error
+	GetMusicAudio(c echo.Context) error
+	GetMusicCover(c echo.Context) error
 }
 
 type MusicController struct {
@@ -23,67 +29,33 @@ func NewMusicController(mu usecase.IMusicUsecase) *MusicController {
 	return &MusicController{mu}
 }
 
+// CreateMusic は既存の日記の音楽を指定したパラメータで再生成する
 func (mc *MusicController) CreateMusic(c echo.Context) error {
-	diaryID := c.QueryParam("diary_id")
-	if diaryID == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Diary ID is required",
-		})
-	}
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
 
-	dID, err := strconv.ParseUint(diaryID, 10, 32)
+	diaryId, err := paramID(c, "diaryId")
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid diary ID",
-		})
+		return err
 	}
 	// JSONリクエストをバインド
-	request := new(model.MusicRequest)
-	if err := c.Bind(request); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request format",
-		})
+	request := model.MusicRequest{}
+	if err := c.Bind(&request); err != nil {
+		return bindError(err)
 	}
 
-	// バリデーション
-	if request.Prompt == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Prompt, lyrics and title are required",
-		})
-	}
-
-	// デフォルト値の設定
-	if request.IsAuto == 0 {
+	// 歌詞の指定がなければ自動生成にする
+	if request.Lyrics == "" {
 		request.IsAuto = 1
 	}
-	if request.Instrumental == 0 {
-		request.Instrumental = 0
-	}
-
-	// テストデータ
-	// response := model.MusicResponse{
-	// 	Status:  200,
-	// 	Message: "Success",
-	// 	Data: []model.MusicData{
-	// 		{
-	// 			AudioFile: "https://files.topmediai.com/aimusic/api/be2151dc-cbff-4be6-ab1b-afa02ea772f1-audio.mp3",
-	// 			ImageFile: "https://files.topmediai.com/aimusic/9960040/8f2fd590-95d0-40ff-8e26-ba1b2224cc33-image.png",
-	// 			ItemUUID:  "be2151dc-cbff-4be6-ab1b-afa02ea772f1",
-	// 			Title:     request.Title,
-	// 			Lyric:     "lyric",
-	// 			Tags:      "uplifting, danceable, pop",
-	// 		},
-	// 	},
-	// }
-
-	// usecaseの呼び出し
-	response, err := mc.mu.CreateMusic(request.Prompt, request.Lyrics, request.Title, request.IsAuto, request.Instrumental, uint(dID))
+
+	// usecaseの呼び出し（生成はバックグラウンドで行う）
+	response, err := mc.mu.CreateMusic(c.Request().Context(), userId, diaryId, request)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return err
 	}
-	return c.JSON(http.StatusOK, response)
+	return c.JSON(http.StatusAccepted, response)
 }
 
 func (mc *MusicController) GetMusicsList(c echo.Context) error {
@@ -103,23 +75,102 @@ func (mc *MusicController) GetMusicsList(c echo.Context) error {
 
 	pageInt, err := strconv.Atoi(page)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid page",
-		})
+		return apperror.BadRequest("invalid_parameter", "Invalid page").Wrap(err)
 	}
 
 	limitInt, err := strconv.Atoi(limit)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid limit",
-		})
+		return apperror.BadRequest("invalid_parameter", "Invalid limit").Wrap(err)
 	}
 
-	musics, err := mc.mu.GetMusicsList(pageInt, limitInt, userId)
+	musics, err := mc.mu.GetMusicsList(c.Request().Context(), pageInt, limitInt, userId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": err.Error(),
-		})
+		return err
 	}
 	return c.JSON(http.StatusOK, musics)
 }
+
+func (mc *MusicController) GetMusicTakes(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	diaryId, err := paramID(c, "diaryId")
+	if err != nil {
+		return err
+	}
+
+	takes, err := mc.mu.GetMusicTakes(c.Request().Context(), userId, diaryId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, takes)
+}
+
+func (mc *MusicController) GetMusicStatus(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	diaryId, err := paramID(c, "diaryId")
+	if err != nil {
+		return err
+	}
+
+	status, err := mc.mu.GetMusicStatus(c.Request().Context(), userId, diaryId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+func (mc *MusicController) SetPrimaryMusic(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	diaryId, err := paramID(c, "diaryId")
+	if err != nil {
+		return err
+	}
+	musicId, err := paramID(c, "musicId")
+	if err != nil {
+		return err
+	}
+
+	musicData, err := mc.mu.SetPrimaryMusic(c.Request().Context(), userId, diaryId, musicId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, musicData)
+}
+
+func (mc *MusicController) GetMusicAudio(c echo.Context) error {
+	return mc.serveMusicAsset(c, usecase.MusicAssetAudio)
+}
+
+func (mc *MusicController) GetMusicCover(c echo.Context) error {
+	return mc.serveMusicAsset(c, usecase.MusicAssetCover)
+}
+
+// serveMusicAsset はログイン中のユーザーの曲のファイルをRangeリクエストに対応して返す
+func (mc *MusicController) serveMusicAsset(c echo.Context, asset string) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	musicId, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+
+	obj, info, err := mc.mu.OpenMusicAsset(c.Request().Context(), userId, musicId, asset)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	// 内容はチェックサムで識別できるので、再検証を前提に共有キャッシュには載せない
+	serveObject(c, obj, info, "private, no-cache")
+	return nil
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+// ISessionController はトークンの更新とログイン中のセッションの管理を行う
+type ISessionController interface {
+	Refresh(c echo.Context) error
+	GetSessions(c echo.Context) error
+	DeleteSession(c echo.Context) error
+	// RequireSession は無効にしたセッションのアクセストークンを拒否するミドルウェア（JWT の検証より後に使う）
+	RequireSession(next echo.HandlerFunc) echo.HandlerFunc
+}
+
+type sessionController struct {
+	su  usecase.ISessionUsecase
+	cfg config.HTTPConfig
+}
+
+func NewSessionController(su usecase.ISessionUsecase, cfg config.HTTPConfig) ISessionController {
+	return &sessionController{su, cfg}
+}
+
+// Refresh はリフレッシュトークンを交換してトークンの Cookie を更新する。
+// 使用済みのリフレッシュトークンが使われた場合はセッションごと無効にする
+func (sc *sessionController) Refresh(c echo.Context) error {
+	tokens, err := sc.su.Refresh(c.Request().Context(), refreshTokenFromCookie(c))
+	if err != nil {
+		clearAuthCookies(c, sc.cfg)
+		return err
+	}
+	setAuthCookies(c, sc.cfg, tokens)
+	return c.NoContent(http.StatusOK)
+}
+
+func (sc *sessionController) GetSessions(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+	sessionId, _ := claims["session_id"].(float64)
+
+	sessions, err := sc.su.GetSessions(c.Request().Context(), userId, uint(sessionId))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, sessions)
+}
+
+// DeleteSession はセッションを無効にする（そのセッションのトークンはすぐに使えなくなる）
+func (sc *sessionController) DeleteSession(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	sessionId, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	if err := sc.su.RevokeSession(c.Request().Context(), userId, sessionId); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (sc *sessionController) RequireSession(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := c.Get("user").(*jwt.Token)
+		claims := user.Claims.(jwt.MapClaims)
+		userId := uint(claims["user_id"].(float64))
+		// セッションの導入前に発行したトークンには session_id がない
+		sessionId, ok := claims["session_id"].(float64)
+		if !ok {
+			return usecase.ErrSessionRevoked
+		}
+		if err := sc.su.CheckSession(c.Request().Context(), userId, uint(sessionId)); err != nil {
+			return err
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/kenta-kenta/diary-music/service"
+	"github.com/labstack/echo/v4"
+)
+
+// IStubController はスタブプロバイダーが返すURLの音声・画像を配信する
+type IStubController interface {
+	GetAudio(c echo.Context) error
+	GetCover(c echo.Context) error
+}
+
+type StubController struct{}
+
+func NewStubController() IStubController {
+	return &StubController{}
+}
+
+func (sc *StubController) GetAudio(c echo.Context) error {
+	seed := c.Param("seed")
+	if !service.IsStubSeed(seed) {
+		return echo.ErrNotFound
+	}
+	return c.Blob(http.StatusOK, "audio/wav", service.StubAudio(seed))
+}
+
+func (sc *StubController) GetCover(c echo.Context) error {
+	seed := c.Param("seed")
+	if !service.IsStubSeed(seed) {
+		return echo.ErrNotFound
+	}
+	return c.Blob(http.StatusOK, "image/png", service.StubCover(seed))
+}
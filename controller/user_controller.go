@@ -2,10 +2,9 @@ package controller
 
 import (
 	"net/http"
-	"os"
-	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/config"
 	"github.com/kenta-kenta/diary-music/model"
 	"github.com/kenta-kenta/diary-music/usecase"
 	"github.com/labstack/echo/v4"
@@ -17,26 +16,33 @@ type IUserController interface {
 	Logout(c echo.Context) error
 	CsrfToken(c echo.Context) error
 	GetUser(c echo.Context) error
+	GetUsage(c echo.Context) error
+	UpdateProfile(c echo.Context) error
+	ChangeEmail(c echo.Context) error
+	ChangePassword(c echo.Context) error
 }
 
 type UserController struct {
-	uu usecase.IUserUsecase
+	uu  usecase.IUserUsecase
+	qu  usecase.IQuotaUsecase
+	su  usecase.ISessionUsecase
+	cfg config.HTTPConfig
 }
 
-func NewUserController(uu usecase.IUserUsecase) IUserController {
-	return &UserController{uu}
+func NewUserController(uu usecase.IUserUsecase, qu usecase.IQuotaUsecase, su usecase.ISessionUsecase, cfg config.HTTPConfig) IUserController {
+	return &UserController{uu, qu, su, cfg}
 }
 
 func (uc *UserController) SignUp(c echo.Context) error {
 	user := model.User{}
 	// リクエストボディのバインド
 	if err := c.Bind(&user); err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return bindError(err)
 	}
 	// ユーザー登録
-	resUser, err := uc.uu.SignUp(user)
+	resUser, err := uc.uu.SignUp(c.Request().Context(), user)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
 	}
 	return c.JSON(http.StatusCreated, resUser)
 }
@@ -45,38 +51,23 @@ func (uc *UserController) Login(c echo.Context) error {
 	user := model.User{}
 	// リクエストボディのバインド
 	if err := c.Bind(&user); err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return bindError(err)
 	}
-	// ログイン
-	token, err := uc.uu.Login(user)
+	// ログイン（セッションを作ってアクセストークンとリフレッシュトークンを発行する）
+	tokens, err := uc.uu.Login(c.Request().Context(), user, sessionClient(c))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return err
 	}
-
-	cookie := new(http.Cookie)                      // Cookieの生成
-	cookie.Name = "token"                           // Cookie名
-	cookie.Value = token                            // Cookie値
-	cookie.Expires = time.Now().Add(24 * time.Hour) // 有効期限
-	cookie.Path = "/"                               // パス
-	cookie.Domain = os.Getenv("API_DOMAIN")         // ドメイン
-	cookie.Secure = true
-	cookie.HttpOnly = true                  // JavaScriptからのアクセスを禁止
-	cookie.SameSite = http.SameSiteNoneMode // SameSite属性
-	c.SetCookie(cookie)                     // Cookieの設定
+	setAuthCookies(c, uc.cfg, tokens)
 	return c.NoContent(http.StatusOK)
 }
 
+// Logout はセッションを無効にしてトークンの Cookie を削除する
 func (uc *UserController) Logout(c echo.Context) error {
-	cookie := new(http.Cookie)              // Cookieの生成
-	cookie.Name = "token"                   // Cookie名
-	cookie.Value = ""                       // Cookie値
-	cookie.Expires = time.Now()             // 有効期限
-	cookie.Path = "/"                       // パス
-	cookie.Domain = os.Getenv("API_DOMAIN") // ドメイン
-	cookie.Secure = true
-	cookie.HttpOnly = true                  // JavaScriptからのアクセスを禁止
-	cookie.SameSite = http.SameSiteNoneMode // SameSite属性
-	c.SetCookie(cookie)                     // Cookieの設定
+	if err := uc.su.Logout(c.Request().Context(), refreshTokenFromCookie(c)); err != nil {
+		return err
+	}
+	clearAuthCookies(c, uc.cfg)
 	return c.NoContent(http.StatusOK)
 }
 
@@ -94,16 +85,81 @@ func (uc *UserController) GetUser(c echo.Context) error {
 
 	// DBからユーザー情報取得
 	userInfo := model.User{}
-	if err := uc.uu.GetUserById(&userInfo, userId); err != nil {
-		return c.JSON(http.StatusNotFound, "ユーザーが見つかりません")
+	if err := uc.uu.GetUserById(c.Request().Context(), &userInfo, userId); err != nil {
+		return err
 	}
 
 	// レスポンス用の構造体
 	response := model.UserResponse{
-		ID:       userInfo.ID,
-		Email:    userInfo.Email,
-		UserName: userInfo.UserName,
+		ID:                  userInfo.ID,
+		Email:               userInfo.Email,
+		UserName:            userInfo.UserName,
+		EmailVerified:       userInfo.EmailVerifiedAt != nil,
+		DeletionScheduledAt: userInfo.DeletionScheduledAt,
 	}
 
 	return c.JSON(http.StatusOK, response)
 }
+
+// GetUsage は音楽生成の今日・今月の使用量と上限を返す
+func (uc *UserController) GetUsage(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	usage, err := uc.qu.GetUsage(c.Request().Context(), userId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, usage)
+}
+
+// UpdateProfile はユーザー名を変更する
+func (uc *UserController) UpdateProfile(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.UserProfileRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	res, err := uc.uu.UpdateProfile(c.Request().Context(), userId, req)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ChangeEmail は変更後のメールアドレスに確認のメールを送る（メールのリンクを開くと変更される）
+func (uc *UserController) ChangeEmail(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	req := model.EmailChangeRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	if err := uc.uu.ChangeEmail(c.Request().Context(), userId, req); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusAccepted)
+}
+
+// ChangePassword は現在のパスワードを確認してパスワードを変更する（他の端末はログアウトされる）
+func (uc *UserController) ChangePassword(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+	sessionId, _ := claims["session_id"].(float64)
+
+	req := model.PasswordChangeRequest{}
+	if err := c.Bind(&req); err != nil {
+		return bindError(err)
+	}
+	if err := uc.uu.ChangePassword(c.Request().Context(), userId, uint(sessionId), req); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/usecase"
+	"github.com/labstack/echo/v4"
+)
+
+type IWebhookController interface {
+	CreateWebhook(c echo.Context) error
+	GetWebhooks(c echo.Context) error
+	DeleteWebhook(c echo.Context) error
+	GetDeliveries(c echo.Context) error
+	Redeliver(c echo.Context) error
+}
+
+type webhookController struct {
+	wu usecase.IWebhookUsecase
+}
+
+func NewWebhookController(wu usecase.IWebhookUsecase) IWebhookController {
+	return &webhookController{wu}
+}
+
+func (wc *webhookController) CreateWebhook(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	request := model.WebhookRequest{}
+	if err := c.Bind(&request); err != nil {
+		return bindError(err)
+	}
+	webhook, err := wc.wu.CreateWebhook(c.Request().Context(), userId, request)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, webhook)
+}
+
+func (wc *webhookController) GetWebhooks(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	webhooks, err := wc.wu.GetWebhooks(c.Request().Context(), userId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, webhooks)
+}
+
+func (wc *webhookController) DeleteWebhook(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	webhookId, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	if err := wc.wu.DeleteWebhook(c.Request().Context(), userId, webhookId); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (wc *webhookController) GetDeliveries(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	webhookId, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	deliveries, err := wc.wu.GetDeliveries(c.Request().Context(), userId, webhookId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+func (wc *webhookController) Redeliver(c echo.Context) error {
+	user := c.Get("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userId := uint(claims["user_id"].(float64))
+
+	webhookId, err := paramID(c, "id")
+	if err != nil {
+		return err
+	}
+	deliveryId, err := paramID(c, "deliveryId")
+	if err != nil {
+		return err
+	}
+	delivery, err := wc.wu.Redeliver(c.Request().Context(), userId, webhookId, deliveryId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, delivery)
+}
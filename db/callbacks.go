@@ -0,0 +1,40 @@
+package db
+
+import "gorm.io/gorm"
+
+type registerFunc func(name string, fn func(*gorm.DB)) error
+
+// registerAround は create / query / update / delete / row / raw の各操作の前後に
+// before と after を登録する。after には操作の種類が渡される
+func registerAround(db *gorm.DB, name string, before func(*gorm.DB), after func(operation string) func(*gorm.DB)) error {
+	cb := db.Callback()
+	processors := []struct {
+		operation string
+		before    registerFunc
+		after     registerFunc
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, p := range processors {
+		if err := p.before(name+":before_"+p.operation, before); err != nil {
+			return err
+		}
+		if err := p.after(name+":after_"+p.operation, after(p.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableName はメトリクスとスパンに記録するテーブル名を返す
+func tableName(db *gorm.DB) string {
+	if db.Statement.Table == "" {
+		return "unknown"
+	}
+	return db.Statement.Table
+}
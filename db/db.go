@@ -1,34 +1,34 @@
 package db
 
 import (
-	"fmt"
 	"log"
-	"os"
+	"log/slog"
 
-	"github.com/joho/godotenv"
+	"github.com/kenta-kenta/diary-music/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewDB() *gorm.DB {
-	// 開発環境のみ.envファイルを読み込む
-	if os.Getenv("GO_ENV") == "dev" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Fatalln(err)
-		}
-	}
-	// DB接続情報を環境変数から取得
-	url := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PW"), os.Getenv("POSTGRES_HOST"), os.Getenv("POSTGRES_PORT"), os.Getenv("POSTGRES_DB"))
-
+func NewDB(cfg config.DatabaseConfig) *gorm.DB {
 	// DB接続
-	db, err := gorm.Open(postgres.Open(url), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{
+		Logger: newGormLogger(),
+		// 一意制約違反などを gorm.ErrDuplicatedKey に変換する
+		TranslateError: true,
+	})
 
 	// DB接続エラー処理
 	if err != nil {
 		log.Fatalln(err)
 	}
-	fmt.Println("Connected")
+	// クエリの実行時間と失敗をメトリクスとトレースに記録
+	if err := registerMetrics(db); err != nil {
+		log.Fatalln(err)
+	}
+	if err := registerTracing(db); err != nil {
+		log.Fatalln(err)
+	}
+	slog.Info("database connected", "host", cfg.Host, "name", cfg.Name)
 	return db
 }
 
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/metrics"
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "metrics:query_start"
+
+// registerMetrics はクエリごとに実行時間と失敗を記録するコールバックを登録する
+func registerMetrics(db *gorm.DB) error {
+	return registerAround(db, "metrics", startQuery, observeQuery)
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		table := tableName(db)
+		metrics.DBQueryDuration.WithLabelValues(table, operation).Observe(time.Since(start).Seconds())
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			metrics.DBQueryErrors.WithLabelValues(table, operation).Inc()
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/kenta-kenta/diary-music/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const querySpanKey = "tracing:query_span"
+
+// registerTracing はクエリごとにスパンを記録するコールバックを登録する。
+// リポジトリが db.WithContext(ctx) で渡した context のスパンの子になる
+func registerTracing(db *gorm.DB) error {
+	return registerAround(db, "tracing", startSpan, endSpan)
+}
+
+func startSpan(db *gorm.DB) {
+	ctx := db.Statement.Context
+	// 親のスパンがないクエリ（起動時など）は記録しない
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	_, span := tracing.Tracer().Start(ctx, "gorm", trace.WithSpanKind(trace.SpanKindClient))
+	db.InstanceSet(querySpanKey, span)
+}
+
+func endSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(querySpanKey)
+		if !ok {
+			return
+		}
+		span, ok := v.(trace.Span)
+		if !ok {
+			return
+		}
+		table := tableName(db)
+		span.SetName(operation + " " + table)
+		// パラメータは埋め込まない（パスワードや日記の本文を記録しないため）
+		span.SetAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+			semconv.DBQueryText(db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.RowsAffected),
+		)
+		var err error
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			err = db.Error
+		}
+		tracing.End(span, err)
+	}
+}
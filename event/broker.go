@@ -0,0 +1,139 @@
+// Package event はユーザーごとのイベントをプロセス内で配信する
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const (
+	// ユーザーごとに保持する過去のイベント数（再接続時の再送に使う）
+	historySize = 100
+	// 購読者ごとのバッファ。溢れた購読者は切断し、再接続時に再送で追いつかせる
+	subscriberBuffer = 16
+)
+
+type Event struct {
+	ID        uint64
+	UserID    uint
+	Type      string
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+type subscriber struct {
+	ch chan Event
+}
+
+// Broker はイベントを購読中の接続に配信し、直近のイベントを保持する
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     map[uint][]Event
+	subscribers map[uint]map[*subscriber]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		// 再起動後もIDが前のプロセスより大きくなるよう起動時刻から始める
+		nextID:      uint64(time.Now().UnixMilli()) * 1000,
+		history:     map[uint][]Event{},
+		subscribers: map[uint]map[*subscriber]struct{}{},
+	}
+}
+
+// Publish はユーザーにイベントを送る。data はJSONに変換して送信する
+func (b *Broker) Publish(ctx context.Context, userId uint, eventType string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	ev := Event{
+		ID:        b.nextID,
+		UserID:    userId,
+		Type:      eventType,
+		Data:      payload,
+		CreatedAt: time.Now(),
+	}
+
+	history := append(b.history[userId], ev)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+	b.history[userId] = history
+
+	for sub := range b.subscribers[userId] {
+		select {
+		case sub.ch <- ev:
+		default:
+			// 受信が追いつかない接続は切断する
+			b.remove(userId, sub)
+		}
+	}
+}
+
+// Subscribe はユーザーのイベントの購読を開始する。
+// lastEventID より後のイベントのうち保持しているものは replay として返す。
+// チャネルが閉じられたら接続を終了し、最後に受け取ったIDで再接続すること。
+func (b *Broker) Subscribe(userId uint, lastEventID uint64) (replay []Event, events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if lastEventID > 0 {
+		for _, ev := range b.history[userId] {
+			if ev.ID > lastEventID {
+				replay = append(replay, ev)
+			}
+		}
+	}
+
+	sub := &subscriber{ch: make(chan Event, subscriberBuffer)}
+	// 停止中は購読させず、クライアントには再接続してもらう
+	if b.closed {
+		close(sub.ch)
+		return replay, sub.ch, func() {}
+	}
+	if b.subscribers[userId] == nil {
+		b.subscribers[userId] = map[*subscriber]struct{}{}
+	}
+	b.subscribers[userId][sub] = struct{}{}
+
+	return replay, sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userId, sub)
+	}
+}
+
+// remove は購読者を外してチャネルを閉じる。b.mu を保持した状態で呼ぶこと
+func (b *Broker) remove(userId uint, sub *subscriber) {
+	subs := b.subscribers[userId]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(b.subscribers, userId)
+	}
+}
+
+// Close はすべての購読を終了させ、以降の購読を受け付けない（サーバーの停止時に呼ぶ）
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for userId, subs := range b.subscribers {
+		for sub := range subs {
+			b.remove(userId, sub)
+		}
+	}
+}
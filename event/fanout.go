@@ -0,0 +1,17 @@
+package event
+
+import "context"
+
+// Publisher はユーザーにイベントを送る
+type Publisher interface {
+	Publish(ctx context.Context, userId uint, eventType string, data interface{})
+}
+
+// Fanout は複数の Publisher に同じイベントを送る
+type Fanout []Publisher
+
+func (f Fanout) Publish(ctx context.Context, userId uint, eventType string, data interface{}) {
+	for _, p := range f {
+		p.Publish(ctx, userId, eventType, data)
+	}
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/storage"
+)
+
+// OpenFunc はストレージに保存した音声・カバー画像を開く（存在しない場合は storage.ErrNotFound）
+type OpenFunc func(ctx context.Context, key string) (io.ReadCloser, error)
+
+// Stats はアーカイブに含めた件数
+type Stats struct {
+	Diaries int
+	Musics  int
+	Files   int
+}
+
+// Write はユーザーの日記と曲を zip にして w に書き込む。diaries には Music を読み込んでおくこと。
+// アーカイブの構成:
+//
+//	README.md          アーカイブの説明
+//	diaries.md         日記と曲（歌詞）を読める形にしたもの
+//	data.json          すべてのデータ (Document)
+//	files/diary-<日記ID>/music-<曲ID>-audio.<拡張子>
+//	files/diary-<日記ID>/music-<曲ID>-cover.<拡張子>
+func Write(ctx context.Context, w io.Writer, user model.User, diaries []model.Diary, open OpenFunc, now time.Time) (Stats, error) {
+	zw := zip.NewWriter(w)
+	doc := Document{
+		FormatVersion: FormatVersion,
+		ExportedAt:    now,
+		Profile:       newProfile(user),
+		Diaries:       make([]Diary, 0, len(diaries)),
+	}
+	stats := Stats{}
+
+	for _, diary := range diaries {
+		d := newDiary(diary)
+		for _, music := range diary.Music {
+			m := newMusic(music)
+			prefix := fmt.Sprintf("files/diary-%d/music-%d", diary.ID, music.ID)
+			var err error
+			if m.AudioFile, err = copyFile(ctx, zw, open, music.AudioKey, prefix+"-audio", music.CreatedAt); err != nil {
+				return stats, err
+			}
+			if m.ImageFile, err = copyFile(ctx, zw, open, music.ImageKey, prefix+"-cover", music.CreatedAt); err != nil {
+				return stats, err
+			}
+			for _, f := range []string{m.AudioFile, m.ImageFile} {
+				if f != "" {
+					stats.Files++
+				}
+			}
+			d.Musics = append(d.Musics, m)
+			stats.Musics++
+		}
+		doc.Diaries = append(doc.Diaries, d)
+		stats.Diaries++
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return stats, err
+	}
+	for _, f := range []struct {
+		name string
+		body []byte
+	}{
+		{"README.md", renderReadme(doc, stats)},
+		{"diaries.md", renderDiaries(doc)},
+		{"data.json", data},
+	} {
+		if err := writeFile(zw, f.name, f.body, now); err != nil {
+			return stats, err
+		}
+	}
+	return stats, zw.Close()
+}
+
+// copyFile はストレージのファイルを name に拡張子を付けたパスにコピーし、アーカイブ内のパスを返す。
+// 保存していない・見つからないファイルは含めない
+func copyFile(ctx context.Context, zw *zip.Writer, open OpenFunc, key string, name string, modified time.Time) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	r, err := open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		slog.WarnContext(ctx, "export skipped missing asset", "key", key)
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("open asset %s: %w", key, err)
+	}
+	defer r.Close()
+
+	name += path.Ext(key)
+	// 音声・画像は圧縮済みなのでそのまま格納する
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(fw, r); err != nil {
+		return "", fmt.Errorf("copy asset %s: %w", key, err)
+	}
+	return name, nil
+}
+
+func writeFile(zw *zip.Writer, name string, body []byte, modified time.Time) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = fw.Write(body)
+	return err
+}
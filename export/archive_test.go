@@ -0,0 +1,131 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/storage"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	verified := now.Add(-time.Hour)
+	user := model.User{ID: 1, UserName: "taro", Email: "taro@example.com", Password: "bcrypt-password-hash", Plan: "free", EmailVerifiedAt: &verified, CreatedAt: now.AddDate(0, -1, 0)}
+	diaries := []model.Diary{{
+		ID:        3,
+		UserId:    1,
+		Content:   "海に行った",
+		CreatedAt: now.Add(-2 * time.Hour),
+		UpdatedAt: now.Add(-2 * time.Hour),
+		Music: []model.Music{{
+			ID:          5,
+			DiaryID:     3,
+			JobID:       7,
+			Title:       "海の歌",
+			Lyrics:      "波の音",
+			IsPrimary:   true,
+			AudioFile:   "https://provider.example.com/5.mp3",
+			ImageFile:   "https://provider.example.com/5.png",
+			AudioKey:    "users/1/diaries/3/abc.mp3",
+			AudioSHA256: "abc",
+			// カバー画像はストレージから消えている
+			ImageKey:  "users/1/diaries/3/def.png",
+			CreatedAt: now.Add(-time.Hour),
+		}},
+	}}
+	files := map[string]string{"users/1/diaries/3/abc.mp3": "audio"}
+	open := func(ctx context.Context, key string) (io.ReadCloser, error) {
+		body, ok := files[key]
+		if !ok {
+			return nil, storage.ErrNotFound
+		}
+		return io.NopCloser(strings.NewReader(body)), nil
+	}
+
+	var buf bytes.Buffer
+	stats, err := Write(context.Background(), &buf, user, diaries, open, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != (Stats{Diaries: 1, Musics: 1, Files: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]string{}
+	var names []string
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = string(body)
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	want := []string{"README.md", "data.json", "diaries.md", "files/diary-3/music-5-audio.mp3"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("entries = %v, want %v", names, want)
+	}
+	if entries["files/diary-3/music-5-audio.mp3"] != "audio" {
+		t.Errorf("audio = %q", entries["files/diary-3/music-5-audio.mp3"])
+	}
+	if !strings.Contains(entries["diaries.md"], "海に行った") || !strings.Contains(entries["README.md"], "taro") {
+		t.Errorf("markdown does not include the diary and profile")
+	}
+
+	var doc Document
+	if err := json.Unmarshal([]byte(entries["data.json"]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.FormatVersion != FormatVersion || !doc.ExportedAt.Equal(now) {
+		t.Errorf("document = %+v", doc)
+	}
+	if doc.Profile != (Profile{ID: 1, UserName: "taro", Email: "taro@example.com", EmailVerified: true, Plan: "free", CreatedAt: user.CreatedAt}) {
+		t.Errorf("profile = %+v", doc.Profile)
+	}
+	if len(doc.Diaries) != 1 || len(doc.Diaries[0].Musics) != 1 {
+		t.Fatalf("diaries = %+v", doc.Diaries)
+	}
+	m := doc.Diaries[0].Musics[0]
+	if m.AudioFile != "files/diary-3/music-5-audio.mp3" || m.AudioSHA256 != "abc" || !m.IsPrimary || m.Title != "海の歌" {
+		t.Errorf("music = %+v", m)
+	}
+	// 見つからないファイルは含めず、生成元のURLを残す
+	if m.ImageFile != "" || m.SourceImageURL != "https://provider.example.com/5.png" {
+		t.Errorf("missing cover = %q, source %q", m.ImageFile, m.SourceImageURL)
+	}
+	if strings.Contains(entries["data.json"], "bcrypt-password-hash") {
+		t.Error("data.json includes the password hash")
+	}
+}
+
+func TestWriteStorageError(t *testing.T) {
+	diaries := []model.Diary{{ID: 3, Music: []model.Music{{ID: 5, AudioKey: "users/1/diaries/3/abc.mp3"}}}}
+	unavailable := errors.New("connection refused")
+	open := func(ctx context.Context, key string) (io.ReadCloser, error) {
+		return nil, unavailable
+	}
+
+	_, err := Write(context.Background(), io.Discard, model.User{ID: 1}, diaries, open, time.Now())
+	if !errors.Is(err, unavailable) {
+		t.Fatalf("err = %v, want the storage error", err)
+	}
+}
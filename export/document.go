@@ -0,0 +1,97 @@
+// Package export はユーザーの個人データをダウンロードできるアーカイブ (zip) にまとめる
+package export
+
+import (
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// FormatVersion は data.json の形式のバージョン（互換性のない変更をしたら上げる）
+const FormatVersion = 1
+
+// Document は data.json の内容。形式は docs/Export.md に記載する
+type Document struct {
+	FormatVersion int       `json:"format_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+	Profile       Profile   `json:"profile"`
+	Diaries       []Diary   `json:"diaries"`
+}
+
+type Profile struct {
+	ID            uint      `json:"id"`
+	UserName      string    `json:"user_name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Plan          string    `json:"plan"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Diary struct {
+	ID        uint      `json:"id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Musics    []Music   `json:"musics"`
+}
+
+type Music struct {
+	ID             uint      `json:"id"`
+	JobID          uint      `json:"job_id"`
+	Title          string    `json:"title"`
+	Lyrics         string    `json:"lyrics"`
+	Tags           string    `json:"tags"`
+	Prompt         string    `json:"prompt"`
+	PromptTags     string    `json:"prompt_tags"`
+	IsAuto         bool      `json:"is_auto"`
+	Instrumental   bool      `json:"instrumental"`
+	IsPrimary      bool      `json:"is_primary"`
+	AudioFile      string    `json:"audio_file"` // アーカイブ内のパス（保存していない場合は空）
+	ImageFile      string    `json:"image_file"`
+	AudioSHA256    string    `json:"audio_sha256"`
+	ImageSHA256    string    `json:"image_sha256"`
+	SourceAudioURL string    `json:"source_audio_url"` // 生成したプロバイダーのURL（期限切れの場合がある）
+	SourceImageURL string    `json:"source_image_url"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func newProfile(user model.User) Profile {
+	return Profile{
+		ID:            user.ID,
+		UserName:      user.UserName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Plan:          user.Plan,
+		CreatedAt:     user.CreatedAt,
+	}
+}
+
+func newDiary(diary model.Diary) Diary {
+	return Diary{
+		ID:        diary.ID,
+		Content:   diary.Content,
+		CreatedAt: diary.CreatedAt,
+		UpdatedAt: diary.UpdatedAt,
+		Musics:    make([]Music, 0, len(diary.Music)),
+	}
+}
+
+func newMusic(music model.Music) Music {
+	return Music{
+		ID:             music.ID,
+		JobID:          music.JobID,
+		Title:          music.Title,
+		Lyrics:         music.Lyrics,
+		Tags:           music.Tags,
+		Prompt:         music.Prompt,
+		PromptTags:     music.PromptTags,
+		IsAuto:         music.IsAuto != 0,
+		Instrumental:   music.Instrumental != 0,
+		IsPrimary:      music.IsPrimary,
+		AudioSHA256:    music.AudioSHA256,
+		ImageSHA256:    music.ImageSHA256,
+		SourceAudioURL: music.AudioFile,
+		SourceImageURL: music.ImageFile,
+		CreatedAt:      music.CreatedAt,
+	}
+}
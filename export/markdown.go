@@ -0,0 +1,76 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const timeFormat = "2006-01-02 15:04"
+
+// renderReadme はアーカイブの説明を書く
+func renderReadme(doc Document, stats Stats) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# diary-music のデータ\n\n")
+	fmt.Fprintf(&b, "%s さんのデータを %s に書き出したものです。\n\n", doc.Profile.UserName, doc.ExportedAt.Format(timeFormat))
+	fmt.Fprintf(&b, "- 日記: %d 件\n- 曲: %d 曲\n- 音声・カバー画像: %d ファイル\n\n", stats.Diaries, stats.Musics, stats.Files)
+	b.WriteString("## ファイル\n\n")
+	b.WriteString("| パス | 内容 |\n| --- | --- |\n")
+	b.WriteString("| `diaries.md` | 日記と曲（歌詞）を日付順に並べたもの |\n")
+	fmt.Fprintf(&b, "| `data.json` | すべてのデータ（形式のバージョン %d）。他のサービスへの移行に使う |\n", doc.FormatVersion)
+	b.WriteString("| `files/diary-<日記ID>/music-<曲ID>-audio.*` | 曲の音声 |\n")
+	b.WriteString("| `files/diary-<日記ID>/music-<曲ID>-cover.*` | 曲のカバー画像 |\n\n")
+	b.WriteString("音声・カバー画像を保存していない曲は `data.json` の `source_audio_url` / `source_image_url` に生成元のURLがあります（期限切れの場合があります）。\n\n")
+	b.WriteString("## プロフィール\n\n")
+	fmt.Fprintf(&b, "- ユーザー名: %s\n", doc.Profile.UserName)
+	fmt.Fprintf(&b, "- メールアドレス: %s\n", doc.Profile.Email)
+	fmt.Fprintf(&b, "- プラン: %s\n", doc.Profile.Plan)
+	fmt.Fprintf(&b, "- 登録日時: %s\n", doc.Profile.CreatedAt.Format(timeFormat))
+	return b.Bytes()
+}
+
+// renderDiaries は日記と曲を読める形にする（日記の本文はそのまま、歌詞は引用にする）
+func renderDiaries(doc Document) []byte {
+	var b bytes.Buffer
+	b.WriteString("# 日記\n")
+	if len(doc.Diaries) == 0 {
+		b.WriteString("\n日記はありません。\n")
+	}
+	for _, d := range doc.Diaries {
+		fmt.Fprintf(&b, "\n## %s\n\n", d.CreatedAt.Format(timeFormat))
+		b.WriteString(strings.TrimSpace(d.Content))
+		b.WriteString("\n")
+		if !d.UpdatedAt.Equal(d.CreatedAt) {
+			fmt.Fprintf(&b, "\n（%s に編集）\n", d.UpdatedAt.Format(timeFormat))
+		}
+		for _, m := range d.Musics {
+			title := m.Title
+			if title == "" {
+				title = fmt.Sprintf("曲 %d", m.ID)
+			}
+			if m.IsPrimary {
+				title += "（代表曲）"
+			}
+			fmt.Fprintf(&b, "\n### %s\n\n", title)
+			fmt.Fprintf(&b, "- 作成日時: %s\n", m.CreatedAt.Format(timeFormat))
+			if m.Tags != "" {
+				fmt.Fprintf(&b, "- タグ: %s\n", m.Tags)
+			}
+			if m.AudioFile != "" {
+				fmt.Fprintf(&b, "- 音声: [%s](%s)\n", m.AudioFile, m.AudioFile)
+			}
+			if m.ImageFile != "" {
+				fmt.Fprintf(&b, "- カバー画像: [%s](%s)\n", m.ImageFile, m.ImageFile)
+			}
+			if m.Instrumental || strings.TrimSpace(m.Lyrics) == "" {
+				continue
+			}
+			b.WriteString("\n")
+			for _, line := range strings.Split(strings.TrimSpace(m.Lyrics), "\n") {
+				b.WriteString(strings.TrimRight("> "+line, " "))
+				b.WriteString("\n")
+			}
+		}
+	}
+	return b.Bytes()
+}
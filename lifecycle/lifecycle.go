@@ -0,0 +1,106 @@
+// Package lifecycle はサーバーやワーカーなどのコンポーネントの起動と停止をまとめて管理する
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Hook はコンポーネントの起動・停止の処理。どちらも nil でよい
+type Hook struct {
+	Name string
+	// Start はコンポーネントを起動してすぐに戻る。ctx はすべての Stop が終わるまでキャンセルされない
+	Start func(ctx context.Context) error
+	// Stop は処理中の仕事を ctx の期限までに終わらせて停止する
+	Stop func(ctx context.Context) error
+}
+
+// Lifecycle は登録された順にコンポーネントを起動し、SIGINT / SIGTERM を受けると逆順に停止する
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	failed  chan error
+	timeout time.Duration
+}
+
+// timeout はすべてのコンポーネントの停止にかけられる時間の合計
+func New(timeout time.Duration) *Lifecycle {
+	return &Lifecycle{
+		failed:  make(chan error, 1),
+		timeout: timeout,
+	}
+}
+
+// Append はコンポーネントを登録する。後に登録したものほど先に停止する
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hook)
+}
+
+// Fail は起動後のコンポーネントが続行できないエラーで止まったことを知らせ、全体を停止させる
+func (l *Lifecycle) Fail(err error) {
+	select {
+	case l.failed <- err:
+	default:
+	}
+}
+
+// Run はすべてのコンポーネントを起動し、シグナルまたは Fail を受けるまで待ってから停止する。
+// 起動・実行・停止中に起きたエラーをまとめて返す
+func (l *Lifecycle) Run() error {
+	l.mu.Lock()
+	hooks := append([]Hook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	signalCtx, stopSignal := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignal()
+	appCtx, cancelApp := context.WithCancel(context.Background())
+	defer cancelApp()
+
+	var errs []error
+	started := 0
+	for _, hook := range hooks {
+		if hook.Start != nil {
+			if err := hook.Start(appCtx); err != nil {
+				errs = append(errs, fmt.Errorf("start %s: %w", hook.Name, err))
+				break
+			}
+		}
+		started++
+	}
+
+	if len(errs) == 0 {
+		select {
+		case <-signalCtx.Done():
+			slog.Info("shutting down")
+		case err := <-l.failed:
+			slog.Error("shutting down", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	// 停止中にもう一度シグナルを受けたら即座に終了する
+	stopSignal()
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+	for i := started - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if hook.Stop == nil {
+			continue
+		}
+		begin := time.Now()
+		if err := hook.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", hook.Name, err))
+			continue
+		}
+		slog.Info("stopped", "component", hook.Name, "elapsed_ms", time.Since(begin).Milliseconds())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+// Package logging は slog による構造化ログを設定する。
+// リクエストIDやユーザーIDは context に入れておくと、*Context 系の関数で出力したログに自動で付く。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userIDKey
+)
+
+// redacted はログに出力しない値の代わりに出力する文字列
+const redacted = "[REDACTED]"
+
+// sensitiveKeys はログに値を出力しないキー（大文字小文字を区別しない）
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"content":       true, // 日記の本文
+	"lyrics":        true,
+	"prompt":        true, // 日記の本文から作るため
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+	"cookie":        true,
+	"api_key":       true,
+}
+
+// New は cfg に従ったロガーを作る
+func New(cfg config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", cfg.Level)
+	}
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(&contextHandler{handler}), nil
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// WithRequestID はリクエストIDを context に入れる
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID は context に入っているリクエストIDを返す
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUserID はログインしているユーザーのIDを context に入れる
+func WithUserID(ctx context.Context, userId uint) context.Context {
+	return context.WithValue(ctx, userIDKey, userId)
+}
+
+// UserID は context に入っているユーザーのIDを返す
+func UserID(ctx context.Context) (uint, bool) {
+	userId, ok := ctx.Value(userIDKey).(uint)
+	return userId, ok
+}
+
+// contextHandler は context に入っているリクエストIDとユーザーIDをログに付ける
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if userId, ok := ctx.Value(userIDKey).(uint); ok {
+		r.AddAttrs(slog.Uint64("user_id", uint64(userId)))
+	}
+	// トレースと突き合わせられるようにする
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,98 @@
+// Package mail はパスワードの再設定やメールアドレスの確認に使うメールを送信する
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+)
+
+// Message は送信するテキストメール
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type IMailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer は cfg.Backend (smtp | outbox) に応じた送信方法を作る
+func NewMailer(cfg config.MailConfig) (IMailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	switch backend := cfg.Backend; backend {
+	case "", "outbox":
+		return NewOutboxMailer(cfg.OutboxDir, from)
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			User:     cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+		}, from), nil
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", backend)
+	}
+}
+
+// render は msg を RFC 5322 の形式にする（本文は UTF-8 の quoted-printable）
+func render(from *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	id, err := messageID(from)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	header := func(key, value string) {
+		// ヘッダーインジェクションを防ぐ
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		fmt.Fprintf(&b, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", id)
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func messageID(from *mail.Address) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">", nil
+}
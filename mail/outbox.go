@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// outboxMailer は送信する代わりに dir に .eml ファイルとして書き出す（開発・テスト用）
+type outboxMailer struct {
+	dir  string
+	from *mail.Address
+}
+
+func NewOutboxMailer(dir string, from *mail.Address) (IMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &outboxMailer{dir, from}, nil
+}
+
+func (m *outboxMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	body, err := render(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%d.eml", now.Format("20060102T150405"), now.Nanosecond())
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, body, 0o600); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "mail written to outbox", "to", msg.To, "subject", msg.Subject, "path", path)
+	// 本文にはトークンを含むリンクが入るので debug のときだけ出力する
+	slog.DebugContext(ctx, "mail body", "to", msg.To, "text", msg.Text)
+	return nil
+}
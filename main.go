@@ -1,27 +1,211 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
 	"github.com/kenta-kenta/diary-music/controller"
 	"github.com/kenta-kenta/diary-music/db"
+	"github.com/kenta-kenta/diary-music/event"
+	"github.com/kenta-kenta/diary-music/lifecycle"
+	"github.com/kenta-kenta/diary-music/logging"
+	"github.com/kenta-kenta/diary-music/mail"
+	"github.com/kenta-kenta/diary-music/metrics"
+	"github.com/kenta-kenta/diary-music/prompt"
 	"github.com/kenta-kenta/diary-music/repository"
 	"github.com/kenta-kenta/diary-music/router"
+	"github.com/kenta-kenta/diary-music/service"
+	"github.com/kenta-kenta/diary-music/storage"
+	"github.com/kenta-kenta/diary-music/tracing"
 	"github.com/kenta-kenta/diary-music/usecase"
 	"github.com/kenta-kenta/diary-music/validator"
+	"github.com/kenta-kenta/diary-music/worker"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// version はビルド時に -ldflags "-X main.version=..." で設定する
+var version = ""
+
+// buildVersion は version が設定されていなければ VCS のリビジョンを返す
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "dev"
+}
+
 func main() {
-	db := db.NewDB()
+	// 設定の読み込み (環境変数, -config の YAML / TOML ファイル, フラグ)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+	// 構造化ログ (LOG_LEVEL: debug | info | warn | error, LOG_FORMAT: json | text)
+	logger, err := logging.New(cfg.Log, os.Stdout)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	slog.SetDefault(logger)
+	// 起動した順と逆に停止する（HTTPサーバー → ワーカー → DB → トレース）
+	app := lifecycle.New(cfg.ShutdownTimeout)
+	// トレース (TRACING_EXPORTER: none | otlp | stdout | file)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, buildVersion())
+	if err != nil {
+		fatal(err)
+	}
+	app.Append(lifecycle.Hook{Name: "tracing", Stop: shutdownTracing})
+	dbConn := db.NewDB(cfg.Database)
+	app.Append(lifecycle.Hook{
+		Name: "database",
+		Stop: func(context.Context) error {
+			db.CloseDB(dbConn)
+			return nil
+		},
+	})
 	userValidator := validator.NewUserValidator()
 	diaryValidator := validator.NewDiaryValidator()
-	userRepository := repository.NewUserRepository(db)
-	diaryRepository := repository.NewDiaryRepository(db)
-	musicRepository := repository.NewMusicRepository(db)
-	userUsecase := usecase.NewUserUsecase(userRepository, userValidator)
-	diaryUsecase := usecase.NewDiaryUsecase(diaryRepository, diaryValidator)
-	musicUsecase := usecase.NewMusicUsecase(musicRepository)
-	userController := controller.NewUserController(userUsecase)
+	musicValidator := validator.NewMusicValidator()
+	userRepository := repository.NewUserRepository(dbConn)
+	diaryRepository := repository.NewDiaryRepository(dbConn)
+	musicRepository := repository.NewMusicRepository(dbConn)
+	musicJobRepository := repository.NewMusicJobRepository(dbConn)
+	// 音楽生成プロバイダー (MUSIC_PROVIDER: topmediai | stub)
+	musicProvider, err := service.NewMusicProvider(cfg.Music)
+	if err != nil {
+		fatal(err)
+	}
+	// 連続5回失敗したら30秒間プロバイダーの呼び出しを止める
+	musicBreaker := service.NewCircuitBreaker(5, 30*time.Second)
+	// 内側から: 呼び出しごとのトレース・メトリクス・ログ → ブレーカー → リトライ
+	musicService := service.NewMetricsMusicService(service.NewMusicService(
+		service.NewRetryProvider(service.NewCircuitBreakerProvider(service.NewInstrumentedProvider(musicProvider), musicBreaker), service.DefaultRetryPolicy),
+	), musicProvider.Name())
+	// 生成した音声・カバー画像の保存先 (STORAGE_BACKEND: local | s3)
+	assetStorage, err := storage.NewStorage(cfg.Storage)
+	if err != nil {
+		fatal(err)
+	}
+	assetMirror := service.NewAssetMirror(assetStorage)
+	// 保存したファイルの署名付きURL (署名鍵は ASSET_SIGNING_KEY。dev で未設定の場合は SECRET から導出する)
+	if cfg.Asset.SigningKey == "" {
+		slog.Warn("ASSET_SIGNING_KEY is not set; using a key derived from SECRET (development only)")
+	}
+	assetSigner := storage.NewURLSigner(cfg.AssetSigningKey(), cfg.Asset.BaseURL, time.Hour)
+	// SSEで配信するイベントのブローカー
+	eventBroker := event.NewBroker()
+	// Webhookの配信 (WEBHOOK_ALLOW_PRIVATE=true でプライベートアドレスへの送信を許可する)
+	webhookRepository := repository.NewWebhookRepository(dbConn)
+	webhookSender := service.NewWebhookSender(cfg.Webhook.AllowPrivate)
+	webhookDeliveryUsecase := usecase.NewWebhookDeliveryUsecase(webhookRepository, webhookSender)
+	webhookWorkerPool := worker.NewPool("webhook", 2, webhookDeliveryUsecase.ProcessNextDelivery)
+	app.Append(lifecycle.Hook{Name: "webhook worker", Start: webhookWorkerPool.Start, Stop: webhookWorkerPool.Stop})
+	webhookUsecase := usecase.NewTracingWebhookUsecase(usecase.NewWebhookUsecase(webhookRepository, validator.NewWebhookValidator(), webhookWorkerPool))
+	// イベントはSSEとWebhookの両方に送る
+	eventPublisher := event.Fanout{eventBroker, webhookUsecase}
+	// 音楽生成の上限 (MUSIC_QUOTA_PLANS: "free=5/60,pro=30/600" のように プラン=日/月, 0 は上限なし)
+	quotaPlans := usecase.DefaultQuotaPlans
+	if cfg.Music.QuotaPlans != "" {
+		quotaPlans, err = usecase.ParseQuotaPlans(cfg.Music.QuotaPlans)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	quotaUsecase := usecase.NewTracingQuotaUsecase(usecase.NewQuotaUsecase(repository.NewUsageRepository(dbConn), userRepository, musicJobRepository, quotaPlans, musicProvider.Name()))
+	musicJobUsecase := usecase.NewMusicJobUsecase(musicJobRepository, musicService, assetMirror, musicBreaker, quotaUsecase, eventPublisher, assetSigner)
+	// 音楽生成ワーカー数 (MUSIC_WORKERS, デフォルト2)
+	musicWorkerPool := worker.NewPool("music", cfg.Music.Workers, musicJobUsecase.ProcessNextJob)
+	app.Append(lifecycle.Hook{Name: "music worker", Start: musicWorkerPool.Start, Stop: musicWorkerPool.Stop})
+	// 日記からプロンプトを組み立てるパイプライン (PROMPT_MAX_LENGTH, デフォルト200文字)
+	promptBuilder := prompt.NewDefaultPipeline(cfg.Music.PromptMaxLength)
+	// ログインごとのセッション (ACCESS_TOKEN_TTL, デフォルト15分 / REFRESH_TOKEN_TTL, デフォルト30日)
+	sessionRepository := repository.NewSessionRepository(dbConn)
+	sessionUsecase := usecase.NewTracingSessionUsecase(usecase.NewSessionUsecase(sessionRepository, cfg.Auth))
+	// パスワード再設定・メールアドレス確認のメール (MAIL_BACKEND: outbox | smtp)
+	mailer, err := mail.NewMailer(cfg.Mail)
+	if err != nil {
+		fatal(err)
+	}
+	accountUsecase := usecase.NewTracingAccountUsecase(usecase.NewAccountUsecase(userRepository, repository.NewAccountRepository(dbConn), sessionRepository, userValidator, mailer, cfg.Auth, cfg.MailLinkBaseURL()))
+	// アカウントの削除 (ACCOUNT_DELETION_GRACE_PERIOD を過ぎた予定をワーカーが削除する)
+	accountDeletionUsecase := usecase.NewTracingAccountDeletionUsecase(usecase.NewAccountDeletionUsecase(userRepository, repository.NewAccountDeletionRepository(dbConn), sessionRepository, userValidator, assetStorage, mailer, cfg.Auth))
+	accountDeletionWorkerPool := worker.NewPool("account_deletion", 1, accountDeletionUsecase.PurgeNextAccount)
+	app.Append(lifecycle.Hook{Name: "account deletion worker", Start: accountDeletionWorkerPool.Start, Stop: accountDeletionWorkerPool.Stop})
+	// 個人データのエクスポート (ダウンロードのリンクは EXPORT_LINK_TTL, アーカイブは EXPORT_RETENTION を過ぎると削除する)
+	exportSigner := storage.NewURLSigner(cfg.AssetSigningKey(), cfg.Asset.BaseURL, cfg.Export.LinkTTL)
+	// ワーカーは受け付けたときに通知を受けるので、プールを先に作ってからユースケースを渡す
+	var exportUsecase usecase.IExportUsecase
+	exportWorkerPool := worker.NewPool("export", 1, func(ctx context.Context) (bool, error) {
+		return exportUsecase.ProcessNextExport(ctx)
+	})
+	exportUsecase = usecase.NewTracingExportUsecase(usecase.NewExportUsecase(userRepository, repository.NewExportRepository(dbConn), assetStorage, exportSigner, exportWorkerPool, mailer, cfg.Export))
+	app.Append(lifecycle.Hook{Name: "export worker", Start: exportWorkerPool.Start, Stop: exportWorkerPool.Stop})
+	userUsecase := usecase.NewTracingUserUsecase(usecase.NewUserUsecase(userRepository, userValidator, sessionUsecase, accountUsecase))
+	diaryUsecase := usecase.NewTracingDiaryUsecase(usecase.NewDiaryUsecase(diaryRepository, diaryValidator, promptBuilder, musicWorkerPool, quotaUsecase, assetSigner, eventPublisher, assetStorage))
+	musicUsecase := usecase.NewTracingMusicUsecase(usecase.NewMusicUsecase(musicRepository, musicJobRepository, diaryRepository, musicValidator, promptBuilder, musicWorkerPool, quotaUsecase, assetSigner, assetStorage, eventPublisher))
+	userController := controller.NewUserController(userUsecase, quotaUsecase, sessionUsecase, cfg.HTTP)
+	sessionController := controller.NewSessionController(sessionUsecase, cfg.HTTP)
+	accountController := controller.NewAccountController(accountUsecase, accountDeletionUsecase, cfg.HTTP)
+	exportController := controller.NewExportController(exportUsecase)
 	diaryController := controller.NewDiaryController(diaryUsecase)
 	musicController := controller.NewMusicController(musicUsecase)
-	e := router.NewRouter(userController, diaryController, musicController)
-	e.Logger.Fatal(e.Start(":8080"))
+	assetController := controller.NewAssetController(assetStorage, assetSigner)
+	eventController := controller.NewEventController(eventBroker)
+	var stubController controller.IStubController
+	if musicProvider.Name() == "stub" {
+		stubController = controller.NewStubController()
+	}
+	internalController := controller.NewInternalController(musicProvider.Name(), musicBreaker)
+	webhookController := controller.NewWebhookController(webhookUsecase)
+	statusUsecase := usecase.NewTracingStatusUsecase(usecase.NewStatusUsecase(repository.NewHealthRepository(dbConn), musicJobRepository, webhookRepository, musicBreaker, musicProvider.Name(), buildVersion(), musicWorkerPool, webhookWorkerPool))
+	healthController := controller.NewHealthController(statusUsecase)
+	// キューの件数と直近15分にリクエストしたユーザー数は /metrics の取得時に数える
+	activeUsers := metrics.NewActiveUsers(15 * time.Minute)
+	prometheus.MustRegister(
+		metrics.NewQueueDepthCollector("music_jobs", musicJobRepository.CountActiveJobs),
+		metrics.NewQueueDepthCollector("webhook_deliveries", webhookRepository.CountActiveDeliveries),
+		activeUsers,
+	)
+	e := router.NewRouter(cfg, userController, diaryController, musicController, assetController, eventController, stubController, internalController, webhookController, healthController, sessionController, accountController, exportController, activeUsers)
+	// 停止を始めたらSSEの接続を閉じる（開いたままだとリクエストの完了を待ち続けるため）
+	e.Server.RegisterOnShutdown(eventBroker.Close)
+	app.Append(lifecycle.Hook{
+		Name: "http server",
+		Start: func(context.Context) error {
+			go func() {
+				if err := e.Start(cfg.HTTP.Addr()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					app.Fail(err)
+				}
+			}()
+			return nil
+		},
+		Stop: e.Shutdown,
+	})
+
+	// SIGINT / SIGTERM を受けたら処理中のリクエストとジョブを SHUTDOWN_TIMEOUT まで待ってから終了する
+	if err := app.Run(); err != nil {
+		fatal(err)
+	}
+}
+
+// fatal はエラーをログに出力して終了する
+func fatal(err error) {
+	slog.Error("fatal", "error", err)
+	os.Exit(1)
 }
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var queueDepthDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "queue_depth"),
+	"Number of unfinished items in a persisted queue by status.",
+	[]string{"queue", "status"}, nil,
+)
+
+// queueDepthCollector はスクレイプのたびにキューに残っている件数を数える
+type queueDepthCollector struct {
+	queue string
+	count func(ctx context.Context) (map[string]int64, error)
+}
+
+// NewQueueDepthCollector は count が返す状態ごとの件数を queue_depth として公開するコレクターを返す
+func NewQueueDepthCollector(queue string, count func(ctx context.Context) (map[string]int64, error)) prometheus.Collector {
+	return &queueDepthCollector{queue, count}
+}
+
+func (c *queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (c *queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		// 値を出さないことで、古い値のままアラートが判定されないようにする
+		slog.Warn("failed to count queue depth", "queue", c.queue, "error", err)
+		return
+	}
+	for status, count := range counts {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(count), c.queue, status)
+	}
+}
+
+var activeUsersDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "active_users"),
+	"Number of distinct users who made an authenticated request within the window on this instance.",
+	nil, nil,
+)
+
+// ActiveUsers はログインしているユーザーのうち、直近 window 以内にリクエストしたユーザーを数える。
+// インスタンスごとに数えるため、複数台で動かしている場合は重複して数える
+type ActiveUsers struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[uint]time.Time
+}
+
+func NewActiveUsers(window time.Duration) *ActiveUsers {
+	return &ActiveUsers{window: window, seen: map[uint]time.Time{}}
+}
+
+// Seen はユーザーがリクエストしたことを記録する
+func (a *ActiveUsers) Seen(userId uint) {
+	a.mu.Lock()
+	a.seen[userId] = time.Now()
+	a.mu.Unlock()
+}
+
+func (a *ActiveUsers) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeUsersDesc
+}
+
+func (a *ActiveUsers) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	since := time.Now().Add(-a.window)
+	for userId, at := range a.seen {
+		if at.Before(since) {
+			delete(a.seen, userId)
+		}
+	}
+	count := len(a.seen)
+	a.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(activeUsersDesc, prometheus.GaugeValue, float64(count))
+}
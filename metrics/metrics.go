@@ -0,0 +1,83 @@
+// Package metrics は /metrics で公開する Prometheus のメトリクスを定義する。
+// メトリクスは prometheus.DefaultRegisterer に登録する（Go ランタイムとプロセスのメトリクスも含まれる）
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "diary_music"
+
+var (
+	// HTTPRequests はルート（/diaries/:diaryId など）ごとのリクエスト数
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status code.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration はルートごとのリクエストの処理時間
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration はテーブル・操作ごとのクエリの実行時間
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by table and operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"table", "operation"})
+
+	// DBQueryErrors はテーブル・操作ごとの失敗したクエリの数（レコードが見つからない場合は含めない）
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Number of failed database queries by table and operation.",
+	}, []string{"table", "operation"})
+
+	// MusicProviderAttempts はプロバイダーの呼び出し回数（リトライを含む）
+	MusicProviderAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "music_provider_attempts_total",
+		Help:      "Number of music provider calls, including retries, by provider and result.",
+	}, []string{"provider", "result"})
+
+	// MusicProviderDuration はプロバイダーの呼び出し1回あたりの所要時間
+	MusicProviderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "music_provider_duration_seconds",
+		Help:      "Latency of a single music provider call by provider.",
+		Buckets:   musicBuckets,
+	}, []string{"provider"})
+
+	// MusicGenerations はリトライを含めた音楽生成の結果
+	MusicGenerations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "music_generations_total",
+		Help:      "Number of music generations by provider and result (success or failure).",
+	}, []string{"provider", "result"})
+
+	// MusicGenerationDuration はリトライを含めた音楽生成の所要時間
+	MusicGenerationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "music_generation_duration_seconds",
+		Help:      "Latency of music generation, including retries, by provider.",
+		Buckets:   musicBuckets,
+	}, []string{"provider"})
+)
+
+// musicBuckets は音楽生成の所要時間のバケット（生成には数十秒から数分かかる）
+var musicBuckets = []float64{1, 2.5, 5, 10, 20, 30, 60, 90, 120, 180, 300}
+
+// Result は成功・失敗を result ラベルの値にする
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -1,20 +1,105 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
 
+	"github.com/kenta-kenta/diary-music/config"
 	"github.com/kenta-kenta/diary-music/db"
-	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/migration"
 )
 
+const usage = `usage: go run migrate/migrate.go <command> [options]
+
+commands:
+  up [-steps N] [-dry-run]    未適用のマイグレーションを適用する（-steps を省略するとすべて）
+  down [-steps N] [-dry-run]  適用済みのマイグレーションを新しい順に戻す（デフォルト1件）
+  status                      マイグレーションの適用状況を表示する
+  create [-go] <name>         新しいマイグレーションを作成する（デフォルトは SQL ファイル）
+`
+
 func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	steps := flags.Int("steps", 0, "number of migrations to apply or revert")
+	dryRun := flags.Bool("dry-run", false, "print the SQL and roll back")
+	goStep := flags.Bool("go", false, "create a Go migration instead of SQL files")
+	flags.Parse(args)
+
+	// create はDBに接続しない
+	if command == "create" {
+		if flags.NArg() != 1 {
+			log.Fatalln("create: migration name is required")
+		}
+		paths, err := migration.Create("migration", flags.Arg(0), *goStep, time.Now())
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
+	// マイグレーションに必要なのはDBの設定のみ（CONFIG_FILE の設定ファイルも読み込む）
+	cfg, err := config.Load(nil)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		log.Fatalf("invalid config: %v", err)
+	}
+
 	// DB接続
-	dbConn := db.NewDB()
-	defer fmt.Println("Successfully Migrated")
+	dbConn := db.NewDB(cfg.Database)
 	defer db.CloseDB(dbConn)
+	migrator := migration.New(dbConn, os.Stdout)
+	migrator.DryRun = *dryRun
 
-	dbConn.Migrator().DropTable(&model.User{}, &model.Diary{}, &model.Music{})
-
-	// マイグレーション
-	dbConn.AutoMigrate(&model.User{}, &model.Diary{}, &model.Music{})
+	switch command {
+	case "up":
+		n, err := migrator.Up(*steps)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		n, err := migrator.Down(*steps)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("reverted %d migration(s)\n", n)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Missing {
+				appliedAt += " (missing)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
 }
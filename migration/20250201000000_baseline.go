@@ -0,0 +1,164 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ベースラインは DropTable + AutoMigrate で作っていた時点のスキーマ。
+// 既存のデータベースに対しては足りないテーブル・カラムを追加するだけで、既存のインデックスの
+// 定義は変えない（AutoMigrate はインデックスを名前でしか比較しない）。定義の違うインデックスは
+// 後のマイグレーションで作り直す（musics.diary_id は 20261018040000_make_musics_diary_id_non_unique）。
+// model の構造体を変更してもこのマイグレーションが変わらないよう、当時の定義をここに固定する。
+
+type baselineUser struct {
+	ID           uint `gorm:"primaryKey"`
+	UserName     string
+	Email        string `gorm:"unique"`
+	Password     string
+	Plan         string `gorm:"not null;default:free"`
+	DailyQuota   *int
+	MonthlyQuota *int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineDiary struct {
+	ID        uint `gorm:"primaryKey"`
+	UserId    uint
+	Content   string
+	Music     []baselineMusic `gorm:"foreignKey:DiaryID"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineDiary) TableName() string { return "diaries" }
+
+type baselineMusic struct {
+	ID           uint `gorm:"primaryKey"`
+	UserID       uint
+	DiaryID      uint `gorm:"index"`
+	JobID        uint `gorm:"index"`
+	IsAuto       int
+	Prompt       string
+	PromptTags   string
+	Lyrics       string
+	Title        string
+	Tags         string
+	Instrumental int
+	AudioFile    string
+	ImageFile    string
+	AudioKey     string
+	AudioSHA256  string
+	AudioSize    int64
+	ImageKey     string
+	ImageSHA256  string
+	ImageSize    int64
+	ItemUUID     string
+	IsPrimary    bool `gorm:"not null;default:false"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineMusic) TableName() string { return "musics" }
+
+type baselineMusicJob struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index"`
+	DiaryID      uint   `gorm:"index"`
+	Status       string `gorm:"index;not null;default:queued"`
+	IsAuto       int
+	Prompt       string
+	PromptTags   string
+	Lyrics       string
+	Title        string
+	Instrumental int
+	Attempts     int
+	Error        string
+	RunAfter     *time.Time
+	StartedAt    *time.Time
+	FinishedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (baselineMusicJob) TableName() string { return "music_jobs" }
+
+type baselineWebhook struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	URL       string `gorm:"not null"`
+	Secret    string `gorm:"not null"`
+	Events    string
+	Active    bool `gorm:"not null;default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineWebhook) TableName() string { return "webhooks" }
+
+type baselineWebhookDelivery struct {
+	ID             uint `gorm:"primaryKey"`
+	WebhookID      uint `gorm:"index"`
+	UserID         uint `gorm:"index"`
+	EventType      string
+	Payload        string
+	Status         string `gorm:"index;not null;default:pending"`
+	Attempts       int
+	ResponseStatus int
+	Error          string
+	RedeliveryOf   *uint
+	NextAttemptAt  time.Time `gorm:"index"`
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (baselineWebhookDelivery) TableName() string { return "webhook_deliveries" }
+
+type baselineUsageRecord struct {
+	ID        uint `gorm:"primaryKey"`
+	UserID    uint `gorm:"index:idx_usage_user_created"`
+	DiaryID   uint
+	JobID     uint `gorm:"index"`
+	Provider  string
+	CostUnits int
+	Outcome   string `gorm:"not null"`
+	Error     string
+	CreatedAt time.Time `gorm:"index:idx_usage_user_created"`
+	UpdatedAt time.Time
+}
+
+func (baselineUsageRecord) TableName() string { return "usage_records" }
+
+func init() {
+	Register(Migration{
+		Version: "20250201000000",
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&baselineUser{},
+				&baselineDiary{},
+				&baselineMusic{},
+				&baselineMusicJob{},
+				&baselineWebhook{},
+				&baselineWebhookDelivery{},
+				&baselineUsageRecord{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&baselineUsageRecord{},
+				&baselineWebhookDelivery{},
+				&baselineWebhook{},
+				&baselineMusicJob{},
+				&baselineMusic{},
+				&baselineDiary{},
+				&baselineUser{},
+			)
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+const sqlTemplate = `-- %s_%s (%s)
+`
+
+const goTemplate = `package migration
+
+import "gorm.io/gorm"
+
+func init() {
+	Register(Migration{
+		Version: "%s",
+		Name:    "%s",
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`
+
+// Create は dir に新しいマイグレーションのファイルを作成し、作成したファイルのパスを返す。
+// goStep が false の場合は sql/ 以下に up / down の SQL ファイル、true の場合は Go のファイルを作る
+func Create(dir string, name string, goStep bool, now time.Time) ([]string, error) {
+	name = strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name is required")
+	}
+	version := now.UTC().Format(VersionLayout)
+	if _, ok := migrations[version]; ok {
+		return nil, fmt.Errorf("migration %s already exists", version)
+	}
+
+	var files [][2]string // パスと内容
+	if goStep {
+		files = append(files, [2]string{filepath.Join(dir, version+"_"+name+".go"), fmt.Sprintf(goTemplate, version, name)})
+	} else {
+		for _, direction := range []string{"up", "down"} {
+			path := filepath.Join(dir, "sql", fmt.Sprintf("%s_%s.%s.sql", version, name, direction))
+			files = append(files, [2]string{path, fmt.Sprintf(sqlTemplate, version, name, direction)})
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		path, body := file[0], file[1]
+		// 既存のファイルは上書きしない
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return paths, err
+		}
+		_, err = f.WriteString(body)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,129 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeRows は SELECT に返す結果
+type fakeRows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+// fakeDB は実行された SQL を記録し、SELECT には rows のうち SQL に含まれるキーのものを返す database/sql のドライバー。
+// Postgres を起動せずに Migrator が実行する SQL とトランザクションを検証するために使う
+type fakeDB struct {
+	mu         sync.Mutex
+	rows       map[string]fakeRows
+	statements []string
+	commits    int
+	rollbacks  int
+}
+
+func newFakeDB(t *testing.T, rows map[string]fakeRows) (*gorm.DB, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{rows: rows}
+	sqlDB := sql.OpenDB(fake)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:               logger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fake
+}
+
+// executed は SQL のうち query を含むものを実行順に返す
+func (f *fakeDB) executed(query string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []string
+	for _, s := range f.statements {
+		if strings.Contains(s, query) {
+			found = append(found, s)
+		}
+	}
+	return found
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDB: use sql.OpenDB")
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB: prepared statements are not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{c.db}, nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	for key, rows := range c.db.rows {
+		if strings.Contains(query, key) {
+			return &fakeResultRows{rows: rows}, nil
+		}
+	}
+	return &fakeResultRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx *fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+type fakeResultRows struct {
+	rows fakeRows
+	next int
+}
+
+func (r *fakeResultRows) Columns() []string { return r.rows.Columns }
+func (r *fakeResultRows) Close() error      { return nil }
+
+func (r *fakeResultRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.Values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.Values[r.next])
+	r.next++
+	return nil
+}
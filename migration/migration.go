@@ -0,0 +1,79 @@
+// Package migration はバージョン管理されたスキーマのマイグレーションを提供する。
+// マイグレーションは Go のコード（Register）または sql/ 以下の SQL ファイルで定義し、
+// 適用済みのバージョンは schema_migrations テーブルに記録する。
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VersionLayout はバージョン（作成日時）の形式
+const VersionLayout = "20060102150405"
+
+var versionPattern = regexp.MustCompile(`^\d{14}$`)
+
+// Step はマイグレーションの1方向分の処理。トランザクションの中で実行される
+type Step func(tx *gorm.DB) error
+
+// SQL は SQL 文をそのまま実行する Step を返す
+func SQL(statements ...string) Step {
+	return func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type Migration struct {
+	Version string // 作成日時（VersionLayout）。この順に適用する
+	Name    string
+	Up      Step
+	Down    Step // nil の場合は戻せない
+}
+
+var migrations = map[string]Migration{}
+
+// Register はマイグレーションを登録する。同じバージョンを2回登録すると panic する
+func Register(m Migration) {
+	if !versionPattern.MatchString(m.Version) {
+		panic(fmt.Sprintf("migration: invalid version %q", m.Version))
+	}
+	if m.Up == nil {
+		panic(fmt.Sprintf("migration: %s has no up step", m.Version))
+	}
+	if _, ok := migrations[m.Version]; ok {
+		panic(fmt.Sprintf("migration: duplicate version %s", m.Version))
+	}
+	migrations[m.Version] = m
+}
+
+// All は登録されたマイグレーションをバージョン順に返す
+func All() []Migration {
+	all := make([]Migration, 0, len(migrations))
+	for _, m := range migrations {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Version < all[j].Version
+	})
+	return all
+}
+
+// SchemaMigration は適用済みのマイグレーションの記録
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:14"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
@@ -0,0 +1,209 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// errDryRun はドライランのトランザクションを巻き戻すためのエラー
+var errDryRun = errors.New("dry run")
+
+// Status はマイグレーションの適用状況
+type Status struct {
+	Version   string
+	Name      string
+	AppliedAt *time.Time // nil の場合は未適用
+	Missing   bool       // 適用済みだがコードに定義がない
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	out        io.Writer
+	// DryRun が true の場合、すべての変更を1つのトランザクションで実行して巻き戻し、実行した SQL を out に出力する
+	DryRun bool
+}
+
+// New は登録済みのすべてのマイグレーションを扱う Migrator を返す。進捗は out に出力する
+func New(db *gorm.DB, out io.Writer) *Migrator {
+	return &Migrator{db: db, migrations: All(), out: out}
+}
+
+// Status はすべてのマイグレーションの適用状況をバージョン順に返す
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, record := range applied {
+		appliedAt := record.AppliedAt
+		statuses = append(statuses, Status{Version: record.Version, Name: record.Name, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Up は未適用のマイグレーションを古い順に最大 steps 件適用する（0 の場合はすべて）。適用した件数を返す
+func (m *Migrator) Up(steps int) (int, error) {
+	count := 0
+	err := m.run(func(db *gorm.DB) error {
+		if err := m.ensureTable(db); err != nil {
+			return err
+		}
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if steps > 0 && count >= steps {
+				break
+			}
+			fmt.Fprintf(m.out, "up   %s_%s\n", migration.Version, migration.Name)
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down は適用済みのマイグレーションを新しい順に steps 件戻す。戻した件数を返す
+func (m *Migrator) Down(steps int) (int, error) {
+	if steps < 1 {
+		steps = 1
+	}
+	defined := map[string]Migration{}
+	for _, migration := range m.migrations {
+		defined[migration.Version] = migration
+	}
+
+	count := 0
+	err := m.run(func(db *gorm.DB) error {
+		if err := m.ensureTable(db); err != nil {
+			return err
+		}
+		var records []SchemaMigration
+		if err := db.Order("version DESC").Limit(steps).Find(&records).Error; err != nil {
+			return err
+		}
+		for _, record := range records {
+			migration, ok := defined[record.Version]
+			if !ok {
+				return fmt.Errorf("migration %s_%s is applied but not defined", record.Version, record.Name)
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %s_%s cannot be reverted", migration.Version, migration.Name)
+			}
+			fmt.Fprintf(m.out, "down %s_%s\n", migration.Version, migration.Name)
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := migration.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// run は fn を実行する。ドライランの場合は SQL を出力しながら実行し、最後に巻き戻す
+func (m *Migrator) run(fn func(db *gorm.DB) error) error {
+	if !m.DryRun {
+		return fn(m.db)
+	}
+	db := m.db.Session(&gorm.Session{Logger: &sqlEchoLogger{m.out}})
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		fmt.Fprintln(m.out, "-- dry run: rolled back")
+		return nil
+	}
+	return err
+}
+
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
+// applied は適用済みのマイグレーションをバージョンごとに返す（テーブルがなければ空）
+func (m *Migrator) applied(db *gorm.DB) (map[string]SchemaMigration, error) {
+	applied := map[string]SchemaMigration{}
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// sqlEchoLogger はスキーマを変更する SQL を出力する（ドライラン用）
+type sqlEchoLogger struct {
+	out io.Writer
+}
+
+func (l *sqlEchoLogger) LogMode(logger.LogLevel) logger.Interface { return l }
+
+func (l *sqlEchoLogger) Info(context.Context, string, ...interface{}) {}
+
+func (l *sqlEchoLogger) Warn(context.Context, string, ...interface{}) {}
+
+func (l *sqlEchoLogger) Error(context.Context, string, ...interface{}) {}
+
+func (l *sqlEchoLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	// スキーマの確認やセーブポイントは出力しない
+	statement := strings.ToUpper(strings.TrimSpace(sql))
+	for _, prefix := range []string{"SELECT", "SAVEPOINT", "RELEASE", "ROLLBACK"} {
+		if strings.HasPrefix(statement, prefix) {
+			return
+		}
+	}
+	fmt.Fprintf(l.out, "%s;\n", strings.TrimRight(strings.TrimSpace(sql), ";"))
+	if err != nil {
+		fmt.Fprintf(l.out, "-- error: %v\n", err)
+	}
+}
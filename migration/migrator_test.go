@@ -0,0 +1,168 @@
+package migration
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMigrations はバージョン順に並べたテスト用のマイグレーション
+func testMigrations() []Migration {
+	return []Migration{
+		{Version: "20990101000000", Name: "create_a", Up: SQL("CREATE TABLE a (id int)"), Down: SQL("DROP TABLE a")},
+		{Version: "20990102000000", Name: "create_b", Up: SQL("CREATE TABLE b (id int)"), Down: SQL("DROP TABLE b")},
+		{Version: "20990103000000", Name: "create_c", Up: SQL("CREATE TABLE c (id int)")},
+	}
+}
+
+// appliedRows は schema_migrations のテーブルがあり、versions が適用済みの状態にする
+func appliedRows(versions ...string) map[string]fakeRows {
+	records := fakeRows{Columns: []string{"version", "name", "applied_at"}}
+	for _, v := range versions {
+		records.Values = append(records.Values, []driver.Value{v, "create", time.Now()})
+	}
+	return map[string]fakeRows{
+		"information_schema.tables": {Columns: []string{"count"}, Values: [][]driver.Value{{int64(1)}}},
+		`FROM "schema_migrations"`:  records,
+	}
+}
+
+func TestAllOrdersByVersion(t *testing.T) {
+	all := All()
+	if len(all) == 0 {
+		t.Fatal("no migrations registered")
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Version >= all[i].Version {
+			t.Fatalf("migrations out of order: %s before %s", all[i-1].Version, all[i].Version)
+		}
+	}
+	// 埋め込んだ SQL ファイルも登録されている
+	found := false
+	for _, m := range all {
+		if m.Version == "20261018040000" {
+			found = m.Name == "make_musics_diary_id_non_unique" && m.Up != nil && m.Down != nil
+		}
+	}
+	if !found {
+		t.Error("embedded migration 20261018040000 is not registered with up and down steps")
+	}
+}
+
+func TestMigratorUpAppliesPendingInOrder(t *testing.T) {
+	db, fake := newFakeDB(t, appliedRows("20990101000000"))
+	var out bytes.Buffer
+	m := &Migrator{db: db, migrations: testMigrations(), out: &out}
+
+	n, err := m.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("applied %d, want 2", n)
+	}
+	if got := fake.executed("CREATE TABLE a"); len(got) != 0 {
+		t.Errorf("applied migration ran again: %v", got)
+	}
+	created := fake.executed("CREATE TABLE")
+	if len(created) != 2 || !strings.Contains(created[0], "b") || !strings.Contains(created[1], "c") {
+		t.Errorf("created = %v, want b then c", created)
+	}
+	if inserts := fake.executed(`INSERT INTO "schema_migrations"`); len(inserts) != 2 {
+		t.Errorf("recorded %d migrations, want 2", len(inserts))
+	}
+	if want := "up   20990102000000_create_b\nup   20990103000000_create_c\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestMigratorUpSteps(t *testing.T) {
+	db, fake := newFakeDB(t, appliedRows())
+	m := &Migrator{db: db, migrations: testMigrations(), out: &bytes.Buffer{}}
+
+	n, err := m.Up(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created := fake.executed("CREATE TABLE"); n != 1 || len(created) != 1 || !strings.Contains(created[0], "CREATE TABLE a") {
+		t.Fatalf("applied %d (%v), want only a", n, created)
+	}
+}
+
+func TestMigratorDown(t *testing.T) {
+	// Down は version の降順で取得した記録を順に戻す
+	db, fake := newFakeDB(t, appliedRows("20990102000000", "20990101000000"))
+	m := &Migrator{db: db, migrations: testMigrations(), out: &bytes.Buffer{}}
+
+	n, err := m.Down(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped := fake.executed("DROP TABLE")
+	if n != 2 || len(dropped) != 2 || dropped[0] != "DROP TABLE b" || dropped[1] != "DROP TABLE a" {
+		t.Fatalf("reverted %d (%v), want b then a", n, dropped)
+	}
+	if deletes := fake.executed(`DELETE FROM "schema_migrations"`); len(deletes) != 2 {
+		t.Errorf("deleted %d records, want 2", len(deletes))
+	}
+}
+
+func TestMigratorDownIrreversible(t *testing.T) {
+	db, _ := newFakeDB(t, appliedRows("20990103000000"))
+	m := &Migrator{db: db, migrations: testMigrations(), out: &bytes.Buffer{}}
+
+	if _, err := m.Down(1); err == nil || !strings.Contains(err.Error(), "cannot be reverted") {
+		t.Fatalf("err = %v, want an irreversible migration error", err)
+	}
+}
+
+func TestMigratorDryRunRollsBack(t *testing.T) {
+	db, fake := newFakeDB(t, appliedRows())
+	var out bytes.Buffer
+	m := &Migrator{db: db, migrations: testMigrations(), out: &out, DryRun: true}
+
+	n, err := m.Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("applied %d, want 3", n)
+	}
+	if fake.commits != 0 || fake.rollbacks != 1 {
+		t.Fatalf("commits = %d, rollbacks = %d, want everything rolled back", fake.commits, fake.rollbacks)
+	}
+	for _, want := range []string{"CREATE TABLE a (id int);", "CREATE TABLE c (id int);", `INSERT INTO "schema_migrations"`, "-- dry run: rolled back"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "SELECT") || strings.Contains(out.String(), "SAVEPOINT") {
+		t.Errorf("output includes queries or savepoints:\n%s", out.String())
+	}
+}
+
+func TestMigratorStatus(t *testing.T) {
+	db, _ := newFakeDB(t, appliedRows("20990101000000", "20980101000000"))
+	m := &Migrator{db: db, migrations: testMigrations()}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range statuses {
+		state := "pending"
+		if s.Missing {
+			state = "missing"
+		} else if s.AppliedAt != nil {
+			state = "applied"
+		}
+		got = append(got, s.Version+":"+state)
+	}
+	want := "20980101000000:missing,20990101000000:applied,20990102000000:pending,20990103000000:pending"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("status = %v, want %s", got, want)
+	}
+}
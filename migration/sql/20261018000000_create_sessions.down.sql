@@ -0,0 +1,3 @@
+-- 20261018000000_create_sessions (down)
+DROP TABLE refresh_tokens;
+DROP TABLE sessions;
@@ -0,0 +1,28 @@
+-- 20261018000000_create_sessions (up)
+-- ログインごとのセッションと，そのセッションで発行したリフレッシュトークン（ハッシュのみ保存する）
+CREATE TABLE sessions (
+    id             bigserial PRIMARY KEY,
+    user_id        bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_agent     text NOT NULL DEFAULT '',
+    ip_address     text NOT NULL DEFAULT '',
+    last_used_at   timestamptz NOT NULL,
+    expires_at     timestamptz NOT NULL,
+    revoked_at     timestamptz,
+    revoked_reason text NOT NULL DEFAULT '',
+    created_at     timestamptz NOT NULL,
+    updated_at     timestamptz NOT NULL
+);
+
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE refresh_tokens (
+    id         bigserial PRIMARY KEY,
+    session_id bigint NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens (session_id);
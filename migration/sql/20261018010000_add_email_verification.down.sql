@@ -0,0 +1,3 @@
+-- 20261018010000_add_email_verification (down)
+DROP TABLE user_tokens;
+ALTER TABLE users DROP COLUMN email_verified_at;
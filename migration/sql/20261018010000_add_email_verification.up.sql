@@ -0,0 +1,19 @@
+-- 20261018010000_add_email_verification (up)
+-- 確認の導入前に登録したユーザーは確認済みとして扱う（音楽生成を止めないため）
+ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+UPDATE users SET email_verified_at = created_at;
+
+-- パスワード再設定・メールアドレス確認のワンタイムトークン（ハッシュのみ保存する）
+CREATE TABLE user_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    purpose    text NOT NULL,
+    token_hash text NOT NULL,
+    email      text NOT NULL DEFAULT '',
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_user_tokens_token_hash ON user_tokens (token_hash);
+CREATE INDEX idx_user_tokens_user_id_purpose ON user_tokens (user_id, purpose);
@@ -0,0 +1,3 @@
+-- 20261018020000_add_account_deletions (down)
+DROP TABLE account_deletions;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
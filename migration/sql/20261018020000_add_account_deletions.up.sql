@@ -0,0 +1,27 @@
+-- 20261018020000_add_account_deletions (up)
+-- 削除を予定しているユーザー（GET /user で返す。取り消すと NULL に戻す）
+ALTER TABLE users ADD COLUMN deletion_scheduled_at timestamptz;
+
+-- アカウント削除の記録。ユーザーを削除した後も監査のために残すので users への外部キーは付けない
+CREATE TABLE account_deletions (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint NOT NULL,
+    requested_at timestamptz NOT NULL,
+    purge_after  timestamptz NOT NULL,
+    started_at   timestamptz,
+    cancelled_at timestamptz,
+    purged_at    timestamptz,
+    diaries      integer NOT NULL DEFAULT 0,
+    musics       integer NOT NULL DEFAULT 0,
+    assets       integer NOT NULL DEFAULT 0,
+    last_error   text NOT NULL DEFAULT '',
+    created_at   timestamptz NOT NULL,
+    updated_at   timestamptz NOT NULL
+);
+
+CREATE INDEX idx_account_deletions_user_id ON account_deletions (user_id);
+-- 取り消しも削除もされていない予定はユーザーごとに1つだけ
+CREATE UNIQUE INDEX idx_account_deletions_pending ON account_deletions (user_id)
+    WHERE cancelled_at IS NULL AND purged_at IS NULL;
+CREATE INDEX idx_account_deletions_purge_after ON account_deletions (purge_after)
+    WHERE cancelled_at IS NULL AND purged_at IS NULL;
@@ -0,0 +1,2 @@
+-- 20261018030000_create_user_exports (down)
+DROP TABLE user_exports;
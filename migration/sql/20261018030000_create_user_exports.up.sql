@@ -0,0 +1,25 @@
+-- 20261018030000_create_user_exports (up)
+-- 個人データのエクスポート。アーカイブはストレージに保存し、expires_at を過ぎると削除する
+CREATE TABLE user_exports (
+    id          bigserial PRIMARY KEY,
+    user_id     bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status      text NOT NULL DEFAULT 'queued',
+    storage_key text NOT NULL DEFAULT '',
+    size        bigint NOT NULL DEFAULT 0,
+    diaries     integer NOT NULL DEFAULT 0,
+    musics      integer NOT NULL DEFAULT 0,
+    files       integer NOT NULL DEFAULT 0,
+    attempts    integer NOT NULL DEFAULT 0,
+    error       text NOT NULL DEFAULT '',
+    expires_at  timestamptz,
+    started_at  timestamptz,
+    finished_at timestamptz,
+    created_at  timestamptz NOT NULL,
+    updated_at  timestamptz NOT NULL
+);
+
+CREATE INDEX idx_user_exports_user_id ON user_exports (user_id);
+CREATE INDEX idx_user_exports_status ON user_exports (status);
+-- 作成中のエクスポートはユーザーごとに1つだけ
+CREATE UNIQUE INDEX idx_user_exports_active ON user_exports (user_id)
+    WHERE status IN ('queued', 'running');
@@ -0,0 +1,2 @@
+-- 20261018040000_make_musics_diary_id_non_unique (down)
+-- 1つの日記に複数の曲を保存しているため UNIQUE には戻さない（何もしない）
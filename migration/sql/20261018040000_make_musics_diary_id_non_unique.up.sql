@@ -0,0 +1,6 @@
+-- 20261018040000_make_musics_diary_id_non_unique (up)
+-- 初期の model/music.go では diary_id が uniqueIndex だった。ベースラインの AutoMigrate は
+-- インデックスを名前でしか比較しないため、既存のデータベースには UNIQUE のインデックスが残り、
+-- 1つの日記に2曲目（バリエーション）を保存できない。通常のインデックスに作り直す
+DROP INDEX IF EXISTS idx_musics_diary_id;
+CREATE INDEX IF NOT EXISTS idx_musics_diary_id ON musics (diary_id);
@@ -0,0 +1,97 @@
+package migration
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// sql/ 以下の {version}_{name}.up.sql / {version}_{name}.down.sql をマイグレーションとして登録する
+//
+//go:embed sql
+var sqlFiles embed.FS
+
+var sqlFilePattern = regexp.MustCompile(`^(\d{14})_(\w+)\.(up|down)\.sql$`)
+
+func init() {
+	if err := registerSQLFiles(sqlFiles, "sql"); err != nil {
+		panic(err)
+	}
+}
+
+func registerSQLFiles(fsys fs.FS, dir string) error {
+	found, err := parseSQLFiles(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, m := range found {
+		Register(m)
+	}
+	return nil
+}
+
+// parseSQLFiles は dir の SQL ファイルをバージョン順のマイグレーションにする
+func parseSQLFiles(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	found := map[string]*Migration{}
+	for _, entry := range entries {
+		match := sqlFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, name, direction := match[1], match[2], match[3]
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := found[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			found[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration: %s has different names (%s, %s)", version, m.Name, name)
+		}
+		// 空のファイル（コメントのみを含む）は何もしない
+		var step Step = func(*gorm.DB) error { return nil }
+		if strings.TrimSpace(stripComments(string(body))) != "" {
+			step = SQL(string(body))
+		}
+		if direction == "up" {
+			m.Up = step
+		} else {
+			m.Down = step
+		}
+	}
+	parsed := make([]Migration, 0, len(found))
+	for version, m := range found {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration: %s has no up.sql", version)
+		}
+		parsed = append(parsed, *m)
+	}
+	sort.Slice(parsed, func(i, j int) bool { return parsed[i].Version < parsed[j].Version })
+	return parsed, nil
+}
+
+func stripComments(sql string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
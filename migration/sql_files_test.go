@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseSQLFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/20990102000000_add_b.up.sql":      {Data: []byte("ALTER TABLE a ADD COLUMN b int;")},
+		"sql/20990102000000_add_b.down.sql":    {Data: []byte("-- 戻せない変更はない\n\n")},
+		"sql/20990101000000_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+		"sql/20990101000000_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"sql/README.md":                        {Data: []byte("ignored")},
+		"sql/2099_short.up.sql":                {Data: []byte("ignored")},
+	}
+	parsed, err := parseSQLFiles(fsys, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0].Version != "20990101000000" || parsed[0].Name != "create_a" ||
+		parsed[1].Version != "20990102000000" || parsed[1].Name != "add_b" {
+		t.Fatalf("parsed = %+v, want create_a then add_b", parsed)
+	}
+
+	db, fake := newFakeDB(t, nil)
+	// コメントのみの down.sql は何も実行しない
+	if err := parsed[1].Down(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed[1].Up(db); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.executed(""); len(got) != 1 || got[0] != "ALTER TABLE a ADD COLUMN b int;" {
+		t.Fatalf("executed = %v, want only the up.sql", got)
+	}
+}
+
+func TestParseSQLFilesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{
+			name: "different names",
+			files: fstest.MapFS{
+				"sql/20990101000000_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+				"sql/20990101000000_create_b.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+			want: "different names",
+		},
+		{
+			name: "no up",
+			files: fstest.MapFS{
+				"sql/20990101000000_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+			want: "has no up.sql",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseSQLFiles(tt.files, "sql"); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
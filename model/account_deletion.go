@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// AccountDeletion はアカウント削除の予定と結果の記録。削除した後も監査のために残す
+type AccountDeletion struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index"`
+	RequestedAt time.Time  `json:"requested_at"`
+	PurgeAfter  time.Time  `json:"purge_after"` // この時刻を過ぎるとバックグラウンドで削除する
+	StartedAt   *time.Time `json:"started_at"`  // 削除を始めた時刻（始めた後は取り消せない）
+	CancelledAt *time.Time `json:"cancelled_at"`
+	PurgedAt    *time.Time `json:"purged_at"`
+	Diaries     int        `json:"diaries"` // 削除した件数
+	Musics      int        `json:"musics"`
+	Assets      int        `json:"assets"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type AccountDeletionRequest struct {
+	Password string `json:"password"` // 現在のパスワード
+}
+
+type AccountDeletionResponse struct {
+	PurgeAfter time.Time `json:"purge_after"` // この時刻までは DELETE /user/deletion で取り消せる
+}
@@ -6,17 +6,26 @@ type Diary struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	UserId    uint      `json:"user_id"`
 	Content   string    `json:"content"`
-	Music     []Music   `json:"music" gorm:"foreignKey:DiaryID"` // 一対一の関係
+	Music     []Music   `json:"music" gorm:"foreignKey:DiaryID"` // 一対多の関係
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type DiaryResponse struct {
-	ID        uint        `json:"id" gorm:"primaryKey"`
-	Content   string      `json:"content" gorm:"not null"`
-	MusicData []MusicData `json:"music_data" gorm:"foreignKey:DiaryID"` // 一対一の関係
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	Content     string      `json:"content" gorm:"not null"`
+	MusicData   []MusicData `json:"music_data" gorm:"foreignKey:DiaryID"` // 一対多の関係
+	MusicStatus string      `json:"music_status,omitempty"`               // 音楽生成ジョブの状態
+	MusicSkip   *MusicSkip  `json:"music_skipped,omitempty"`              // 日記のみ保存して音楽を生成しなかった場合の理由
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// MusicSkip は日記を保存したが音楽の生成ジョブを登録しなかった理由
+type MusicSkip struct {
+	Code     string     `json:"code"` // エラーレスポンスの code と同じ (quota_exceeded | email_not_verified)
+	Detail   string     `json:"detail"`
+	ResetsAt *time.Time `json:"resets_at,omitempty"` // 再び生成できる時刻
 }
 
 type DiaryDate struct {
@@ -0,0 +1,18 @@
+package model
+
+// SSEで配信するイベントの種類
+const (
+	EventMusicQueued  = "music.queued"
+	EventMusicPending = "music.pending"
+	EventMusicReady   = "music.ready"
+	EventMusicFailed  = "music.failed"
+)
+
+// MusicEvent は音楽生成に関するイベントの内容
+type MusicEvent struct {
+	DiaryID   uint        `json:"diary_id"`
+	JobID     uint        `json:"job_id"`
+	Status    string      `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	MusicData []MusicData `json:"music_data"`
+}
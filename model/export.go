@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// エクスポートの状態
+const (
+	ExportStatusQueued    = "queued"
+	ExportStatusRunning   = "running"
+	ExportStatusSucceeded = "succeeded"
+	ExportStatusFailed    = "failed"
+	ExportStatusExpired   = "expired" // 保存期間を過ぎてアーカイブを削除した
+)
+
+// UserExport は個人データのエクスポート（アーカイブの作成）1回分
+type UserExport struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index"`
+	Status     string     `json:"status" gorm:"index;not null;default:queued"`
+	StorageKey string     `json:"-"` // アーカイブのストレージのキー
+	Size       int64      `json:"size"`
+	Diaries    int        `json:"diaries"` // アーカイブに含めた件数
+	Musics     int        `json:"musics"`
+	Files      int        `json:"files"`
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error"`
+	ExpiresAt  *time.Time `json:"expires_at"` // この時刻を過ぎるとアーカイブを削除する
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type UserExportResponse struct {
+	ID          uint       `json:"id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	Size        int64      `json:"size"`
+	Diaries     int        `json:"diaries"`
+	Musics      int        `json:"musics"`
+	Files       int        `json:"files"`
+	DownloadURL string     `json:"download_url,omitempty"` // succeeded の場合のみ。期限付きの署名付きURL
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -17,28 +17,70 @@ type MusicResponse struct {
 }
 
 type MusicData struct {
+	ID        uint   `json:"id,omitempty"`     // 保存済みの曲のID（プロバイダーのレスポンスには含まれない）
+	JobID     uint   `json:"job_id,omitempty"` // 曲を生成したジョブ（生成パラメータ）のID
+	IsPrimary bool   `json:"is_primary"`
 	AudioFile string `json:"audio_file"`
 	ImageFile string `json:"image_file"`
 	ItemUUID  string `json:"item_uuid"`
 	Title     string `json:"title"`
 	Lyric     string `json:"lyric"`
 	Tags      string `json:"tags"`
+	AudioKey  string `json:"-"` // 署名付きURLに差し替えるためのストレージのキー
+	ImageKey  string `json:"-"`
 }
 
 type Music struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
 	UserID       uint      `json:"user_id"`
-	DiaryID      uint      `json:"diary_id" gorm:"uniqueIndex"` // 一対一の関係を保証
+	DiaryID      uint      `json:"diary_id" gorm:"index"` // 1つの日記に複数の曲（バリエーション）が紐づく。以前の UNIQUE は 20261018040000 のマイグレーションで作り直す
 	Diary        *Diary    `json:"diary" gorm:"foreignKey:DiaryID"`
+	JobID        uint      `json:"job_id" gorm:"index"` // この曲を生成したジョブ（パラメータはジョブに記録）
 	IsAuto       int       `json:"is_auto"`
-	Prompt       string    `json:"prompt"`
+	Prompt       string    `json:"prompt"`      // プロバイダーに送ったプロンプト
+	PromptTags   string    `json:"prompt_tags"` // 日記から導出した気分・ジャンル・キーワード
 	Lyrics       string    `json:"lyrics"`
 	Title        string    `json:"title"`
-	Tags         string    `json:"tags"`
+	Tags         string    `json:"tags"` // プロバイダーが返したタグ
 	Instrumental int       `json:"instrumental"`
-	AudioFile    string    `json:"audio_file"`
+	AudioFile    string    `json:"audio_file"` // プロバイダーが配信しているURL
 	ImageFile    string    `json:"image_file"`
+	AudioKey     string    `json:"-"` // 自前のストレージに保存した音声のキー
+	AudioSHA256  string    `json:"audio_sha256"`
+	AudioSize    int64     `json:"audio_size"`
+	ImageKey     string    `json:"-"` // 自前のストレージに保存したカバー画像のキー
+	ImageSHA256  string    `json:"image_sha256"`
+	ImageSize    int64     `json:"image_size"`
 	ItemUUID     string    `json:"item_uuid"`
+	IsPrimary    bool      `json:"is_primary" gorm:"not null;default:false"` // 日記の代表曲
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// ToMusicData は保存済みの曲をレスポンス用の形式に変換する
+func ToMusicData(music Music) MusicData {
+	return MusicData{
+		ID:        music.ID,
+		JobID:     music.JobID,
+		IsPrimary: music.IsPrimary,
+		AudioFile: music.AudioFile,
+		ImageFile: music.ImageFile,
+		ItemUUID:  music.ItemUUID,
+		Title:     music.Title,
+		Lyric:     music.Lyrics,
+		Tags:      music.Tags,
+		AudioKey:  music.AudioKey,
+		ImageKey:  music.ImageKey,
+	}
+}
+
+// MusicTakeResponse は1回の生成（テイク）のパラメータと生成された曲を表す
+type MusicTakeResponse struct {
+	JobID      uint         `json:"job_id"`
+	Status     string       `json:"status"`
+	Request    MusicRequest `json:"request"`
+	PromptTags string       `json:"prompt_tags,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	MusicData  []MusicData  `json:"music_data"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// 音楽生成ジョブの状態
+const (
+	MusicJobStatusQueued    = "queued"
+	MusicJobStatusPending   = "pending" // プロバイダー障害のため run_after まで待機中
+	MusicJobStatusRunning   = "running"
+	MusicJobStatusSucceeded = "succeeded"
+	MusicJobStatusFailed    = "failed"
+)
+
+type MusicJob struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"index"`
+	DiaryID      uint       `json:"diary_id" gorm:"index"`
+	Status       string     `json:"status" gorm:"index;not null;default:queued"`
+	IsAuto       int        `json:"is_auto"`
+	Prompt       string     `json:"prompt"`
+	PromptTags   string     `json:"prompt_tags"` // プロンプトを日記から導出した場合の解析結果
+	Lyrics       string     `json:"lyrics"`
+	Title        string     `json:"title"`
+	Instrumental int        `json:"instrumental"`
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error"`
+	RunAfter     *time.Time `json:"run_after"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type MusicJobStatusResponse struct {
+	JobID      uint        `json:"job_id"`
+	DiaryID    uint        `json:"diary_id"`
+	Status     string      `json:"status"`
+	Attempts   int         `json:"attempts"`
+	Error      string      `json:"error,omitempty"`
+	RunAfter   *time.Time  `json:"run_after,omitempty"`
+	MusicData  []MusicData `json:"music_data"`
+	StartedAt  *time.Time  `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
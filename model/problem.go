@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// ProblemTypePrefix にエラーのコードを付けたものを Problem.Type に使う
+const ProblemTypePrefix = "urn:diary-music:problem:"
+
+// Problem は RFC 7807 のエラーレスポンス (Content-Type: application/problem+json)
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`                 // フロントエンドが分岐に使うコード
+	RequestID string            `json:"request_id,omitempty"` // X-Request-ID と同じ値
+	Errors    map[string]string `json:"errors,omitempty"`     // 項目ごとの検証エラー
+	ResetsAt  *time.Time        `json:"resets_at,omitempty"`  // 音楽生成の上限が戻る時刻 (quota_exceeded のみ)
+}
@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+// セッションを無効にした理由
+const (
+	SessionRevokedLogout          = "logout"              // ログアウトした
+	SessionRevokedByUser          = "revoked"             // DELETE /sessions/:id で無効にした
+	SessionRevokedTokenReused     = "refresh_token_reuse" // 使用済みのリフレッシュトークンが再び使われた（漏洩の疑い）
+	SessionRevokedPasswordReset   = "password_reset"      // パスワードを再設定した
+	SessionRevokedPasswordChange  = "password_change"     // パスワードを変更した（変更した端末以外）
+	SessionRevokedAccountDeletion = "account_deletion"    // アカウントの削除を予定した
+)
+
+// Session はログイン1回分のセッション。リフレッシュトークンはセッションごとに1つの系列になる
+type Session struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"index"`
+	UserAgent     string     `json:"user_agent"`
+	IPAddress     string     `json:"ip_address"`
+	LastUsedAt    time.Time  `json:"last_used_at"` // 最後にトークンを更新した時刻
+	ExpiresAt     time.Time  `json:"expires_at"`   // 更新のたびに延長する
+	RevokedAt     *time.Time `json:"revoked_at"`
+	RevokedReason string     `json:"revoked_reason"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Active はセッションが無効にされておらず、期限内かを返す
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken はリフレッシュトークンのハッシュ。使用済みのトークンは再利用の検出のために残す
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	SessionID uint       `json:"session_id" gorm:"index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"` // 次のトークンに交換した時刻
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// SessionClient はログインした端末の情報
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// AuthTokens はログイン・トークンの更新で発行するトークン
+type AuthTokens struct {
+	SessionID        uint
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"` // リクエストしたセッション
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+// ヘルスチェックの結果
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusDegraded    = "degraded"    // 一部の機能が使えないがリクエストは受け付ける
+	HealthStatusUnavailable = "unavailable" // リクエストを受け付けられない
+)
+
+type HealthCheckResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type ReadinessResponse struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// PoolStats はワーカープールの統計
+type PoolStats struct {
+	Name      string `json:"name"`
+	Size      int    `json:"size"`
+	Busy      int64  `json:"busy"`      // 処理中のワーカー数
+	Processed int64  `json:"processed"` // 起動してから処理した件数
+	Errors    int64  `json:"errors"`
+}
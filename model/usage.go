@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// 使用量台帳の記録の結果
+const (
+	UsageOutcomeReserved  = "reserved"  // プロバイダーを呼び出し中
+	UsageOutcomeSucceeded = "succeeded" // 生成に成功（コストを消費）
+	UsageOutcomeFailed    = "failed"    // 生成に失敗（コストは消費しない）
+	UsageOutcomeRejected  = "rejected"  // 上限に達していたため呼び出さなかった
+)
+
+// UsageRecord は音楽生成の試行1回分の記録
+type UsageRecord struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index:idx_usage_user_created"`
+	DiaryID   uint      `json:"diary_id"`
+	JobID     uint      `json:"job_id" gorm:"index"`
+	Provider  string    `json:"provider"`
+	CostUnits int       `json:"cost_units"`
+	Outcome   string    `json:"outcome" gorm:"not null"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_usage_user_created"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UsagePeriod は期間ごとの使用量
+type UsagePeriod struct {
+	Used     int       `json:"used"`
+	Limit    *int      `json:"limit"` // null の場合は上限なし
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+type UsageResponse struct {
+	Plan    string      `json:"plan"`
+	Daily   UsagePeriod `json:"daily"`
+	Monthly UsagePeriod `json:"monthly"`
+	Queued  int         `json:"queued"` // 生成待ちのジョブ数（上限の判定に含める）
+}
@@ -3,16 +3,74 @@ package model
 import "time"
 
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserName  string    `json:"user_name"`
-	Email     string    `json:"email" gorm:"unique"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	UserName     string `json:"user_name"`
+	Email        string `json:"email" gorm:"unique"`
+	Password     string `json:"password"`
+	Plan         string `json:"plan" gorm:"not null;default:free"` // 音楽生成の上限を決めるプラン
+	DailyQuota   *int   `json:"-"`                                 // ユーザーごとの上限（nil の場合はプランの上限、0 は上限なし）
+	MonthlyQuota *int   `json:"-"`
+	// メールアドレスを確認した時刻（未確認の場合は音楽を生成できない）
+	EmailVerifiedAt *time.Time `json:"-"`
+	// アカウントの削除を予定している時刻（取り消すと nil に戻す）
+	DeletionScheduledAt *time.Time `json:"-"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type UserResponse struct {
-	ID       uint   `json:"id" gorm:"primaryKey"`
-	Email    string `json:"email" gorm:"unique"`
-	UserName string `json:"user_name" gorm:"unique"`
+	ID            uint   `json:"id" gorm:"primaryKey"`
+	Email         string `json:"email" gorm:"unique"`
+	UserName      string `json:"user_name" gorm:"unique"`
+	EmailVerified bool   `json:"email_verified"`
+	// 削除を予定している場合はその時刻
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+// ユーザーのワンタイムトークンの用途
+const (
+	UserTokenPasswordReset     = "password_reset"
+	UserTokenEmailVerification = "email_verification"
+	UserTokenEmailChange       = "email_change" // Email は変更後のメールアドレス
+)
+
+// UserToken はメールで送るワンタイムトークン。DB にはハッシュのみ保存する
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex"`
+	Email     string     `json:"email"` // 確認するメールアドレス（確認までに変更された場合は無効）
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type PasswordForgotRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type EmailVerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// UserProfileRequest は PATCH /user で変更できる項目
+type UserProfileRequest struct {
+	UserName string `json:"user_name"`
+}
+
+// EmailChangeRequest は変更後のメールアドレス。確認のメールを開くまで変更しない
+type EmailChangeRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"` // 現在のパスワード
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
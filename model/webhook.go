@@ -0,0 +1,84 @@
+package model
+
+import "time"
+
+// Webhookで通知するイベントの種類（音楽のイベントは event.go を参照）
+const (
+	EventDiaryCreated = "diary.created"
+	EventDiaryUpdated = "diary.updated"
+	EventDiaryDeleted = "diary.deleted"
+)
+
+// WebhookEvents はWebhookで購読できるイベント
+var WebhookEvents = []string{
+	EventMusicReady,
+	EventMusicFailed,
+	EventDiaryCreated,
+	EventDiaryUpdated,
+	EventDiaryDeleted,
+}
+
+// Webhookの配信状態
+const (
+	WebhookDeliveryPending    = "pending"
+	WebhookDeliveryDelivering = "delivering"
+	WebhookDeliverySucceeded  = "succeeded"
+	WebhookDeliveryFailed     = "failed"
+)
+
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"-" gorm:"not null"` // 署名用の共有シークレット
+	Events    string    `json:"events"`            // 購読するイベント（カンマ区切り）
+	Active    bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WebhookID      uint       `json:"webhook_id" gorm:"index"`
+	UserID         uint       `json:"user_id" gorm:"index"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload"` // 送信するJSON
+	Status         string     `json:"status" gorm:"index;not null;default:pending"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status"`
+	Error          string     `json:"error"`
+	RedeliveryOf   *uint      `json:"redelivery_of"` // 再送元の配信ID
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"` // 省略した場合はサーバーで生成する
+	Events []string `json:"events"` // 省略した場合はすべてのイベント
+}
+
+type WebhookResponse struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"` // 作成時のみ返す
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookPayload は配信するJSONの形式
+type WebhookPayload struct {
+	ID        string      `json:"id"` // イベントの識別子（再送しても変わらない）
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// DiaryEvent は日記に関するイベントの内容
+type DiaryEvent struct {
+	DiaryID uint           `json:"diary_id"`
+	Diary   *DiaryResponse `json:"diary,omitempty"` // 削除時は含まない
+}
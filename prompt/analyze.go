@@ -0,0 +1,161 @@
+package prompt
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const maxKeywords = 5
+
+// moodOrder は同点の場合に優先する気分の順番
+var moodOrder = []string{"sad", "angry", "anxious", "romantic", "nostalgic", "excited", "happy", "tired", "calm"}
+
+// DetectLanguage はかな・漢字の割合から日本語か英語かを判定する
+func DetectLanguage(content string, r *Result) {
+	var ja, latin int
+	for _, c := range content {
+		switch {
+		case unicode.In(c, unicode.Hiragana, unicode.Katakana, unicode.Han):
+			ja++
+		case c < unicode.MaxASCII && unicode.IsLetter(c):
+			latin++
+		}
+	}
+	// 英単語は1語あたりの文字数が多いので日本語側を重く見る
+	if ja*3 >= latin && ja > 0 {
+		r.Language = "ja"
+	} else {
+		r.Language = "en"
+	}
+}
+
+// ExtractKeywords は話題を表すキーワードを取り出す。
+// 人名などを送らないよう、日本語は辞書にある語のみ、英語は大文字で始まる語を除外する。
+func ExtractKeywords(content string, r *Result) {
+	seen := map[string]bool{}
+	add := func(k string) {
+		if !seen[k] && len(r.Keywords) < maxKeywords {
+			seen[k] = true
+			r.Keywords = append(r.Keywords, k)
+		}
+	}
+
+	// 日本語の話題語（本文に出てくる順）。「花火」の中の「花」のように
+	// より長い語の一部として現れたものは数えない
+	type hit struct {
+		start, end int
+		keyword    string
+	}
+	var hits []hit
+	for _, t := range topicWords {
+		for offset := 0; ; {
+			i := strings.Index(content[offset:], t.Word)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			hits = append(hits, hit{start, start + len(t.Word), t.Keyword})
+			offset = start + len(t.Word)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].start < hits[j].start })
+	for _, h := range hits {
+		covered := false
+		for _, other := range hits {
+			if other.end-other.start > h.end-h.start && other.start <= h.start && h.end <= other.end {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			add(h.keyword)
+		}
+	}
+
+	// 英語は出現回数の多い順
+	counts := map[string]int{}
+	var order []string
+	for _, word := range englishWords(content) {
+		if len(word) < 4 || unicode.IsUpper(rune(word[0])) {
+			continue
+		}
+		if stopWords[word] || isMoodWord(word) {
+			continue
+		}
+		if counts[word] == 0 {
+			order = append(order, word)
+		}
+		counts[word]++
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+	for _, word := range order {
+		add(word)
+	}
+}
+
+// ClassifyMood は辞書の語が最も多く含まれる気分を選ぶ
+func ClassifyMood(content string, r *Result) {
+	lower := strings.ToLower(content)
+	words := map[string]int{}
+	for _, word := range englishWords(lower) {
+		words[word]++
+	}
+
+	scores := map[string]int{}
+	for mood, cues := range moodWords {
+		for _, cue := range cues {
+			if isASCII(cue) {
+				scores[mood] += words[cue]
+			} else {
+				scores[mood] += strings.Count(content, cue)
+			}
+		}
+	}
+
+	r.Mood = "neutral"
+	best := 0
+	for _, mood := range moodOrder {
+		if scores[mood] > best {
+			best = scores[mood]
+			r.Mood = mood
+		}
+	}
+}
+
+// MapGenre は気分からジャンルとテンポを決める
+func MapGenre(content string, r *Result) {
+	style, ok := moodStyles[r.Mood]
+	if !ok {
+		style = moodStyles["neutral"]
+	}
+	r.Genre = style.Genre
+	r.Tempo = style.Tempo
+}
+
+// englishWords は英字とアポストロフィからなる語を取り出す（大文字・小文字は保持する）
+func englishWords(content string) []string {
+	return strings.FieldsFunc(content, func(c rune) bool {
+		return !(c < unicode.MaxASCII && (unicode.IsLetter(c) || c == '\''))
+	})
+}
+
+func isMoodWord(word string) bool {
+	for _, cues := range moodWords {
+		for _, cue := range cues {
+			if cue == word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for _, c := range s {
+		if c >= unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxLength はプロバイダーに送るプロンプトの最大文字数のデフォルト値
+const DefaultMaxLength = 200
+
+// Composer は解析結果から最終的なプロンプトを組み立てる。
+// MaxLength に収まらない場合はキーワードを後ろから減らす。
+type Composer struct {
+	MaxLength int
+}
+
+func (c Composer) Process(content string, r *Result) {
+	maxLength := c.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxLength
+	}
+
+	for n := len(r.Keywords); n >= 0; n-- {
+		text := compose(r, r.Keywords[:n])
+		if len([]rune(text)) <= maxLength {
+			r.Keywords = r.Keywords[:n]
+			r.Text = text
+			return
+		}
+	}
+	r.Text = string([]rune(compose(r, nil))[:maxLength])
+}
+
+func compose(r *Result, keywords []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s song", article(r.Tempo), r.Tempo, r.Genre)
+	if r.Mood != "" && r.Mood != "neutral" {
+		fmt.Fprintf(&b, " with a %s feeling", r.Mood)
+	}
+	if len(keywords) > 0 {
+		fmt.Fprintf(&b, " about %s", joinWords(keywords))
+	}
+	b.WriteString(".")
+	if r.Language == "ja" {
+		b.WriteString(" Japanese lyrics.")
+	}
+	return b.String()
+}
+
+// joinWords は "a, b and c" の形に連結する
+func joinWords(words []string) string {
+	if len(words) == 1 {
+		return words[0]
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
+
+func article(word string) string {
+	if word != "" && strings.ContainsRune("aeiou", rune(word[0])) {
+		return "An"
+	}
+	return "A"
+}
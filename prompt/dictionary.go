@@ -0,0 +1,83 @@
+package prompt
+
+// moodWords は気分ごとの手がかりになる語（日本語は部分一致、英語は単語一致）
+var moodWords = map[string][]string{
+	"happy": {
+		"嬉し", "うれし", "楽し", "たのし", "幸せ", "しあわせ", "最高", "笑", "良かった", "よかった", "ありがと",
+		"happy", "glad", "fun", "great", "joy", "smile", "laughed", "wonderful", "thankful",
+	},
+	"excited": {
+		"ワクワク", "わくわく", "楽しみ", "興奮", "やった", "ドキドキ", "テンション",
+		"excited", "exciting", "thrilled", "amazing", "awesome", "finally",
+	},
+	"sad": {
+		"悲し", "かなし", "寂し", "さみし", "さびし", "泣", "辛い", "つらい", "落ち込", "失恋", "別れ",
+		"sad", "lonely", "cried", "cry", "miss", "lost", "heartbroken", "depressed",
+	},
+	"angry": {
+		"怒", "イライラ", "いらいら", "ムカ", "むか", "腹が立", "許せな",
+		"angry", "mad", "annoyed", "furious", "hate", "frustrated",
+	},
+	"anxious": {
+		"不安", "心配", "怖", "こわ", "緊張", "焦", "あせ",
+		"anxious", "worried", "nervous", "scared", "afraid", "stress", "stressed",
+	},
+	"tired": {
+		"疲れ", "つかれ", "眠い", "ねむい", "だるい", "しんど", "寝不足",
+		"tired", "exhausted", "sleepy", "drained", "weary",
+	},
+	"calm": {
+		"穏やか", "のんびり", "ゆっくり", "落ち着", "ほっと", "癒", "静か",
+		"calm", "relaxed", "peaceful", "quiet", "cozy", "chill",
+	},
+	"nostalgic": {
+		"懐かし", "なつかし", "思い出", "昔", "あの頃",
+		"nostalgic", "memories", "memory", "remember", "remembered", "childhood",
+	},
+	"romantic": {
+		"恋", "好き", "デート", "愛", "キス",
+		"love", "date", "romantic", "crush", "kiss",
+	},
+}
+
+// moodStyles は気分からジャンルとテンポを決める
+var moodStyles = map[string]struct{ Genre, Tempo string }{
+	"happy":     {"pop", "upbeat"},
+	"excited":   {"dance pop", "fast"},
+	"sad":       {"ballad", "slow"},
+	"angry":     {"rock", "fast"},
+	"anxious":   {"ambient", "mid-tempo"},
+	"tired":     {"lo-fi", "slow"},
+	"calm":      {"acoustic", "slow"},
+	"nostalgic": {"folk", "mid-tempo"},
+	"romantic":  {"r&b", "mid-tempo"},
+	"neutral":   {"pop", "mid-tempo"},
+}
+
+// topicWords は日本語の話題語と、プロンプトに使う英語のキーワード
+var topicWords = []struct{ Word, Keyword string }{
+	{"海", "ocean"}, {"山", "mountains"}, {"川", "river"}, {"空", "sky"}, {"星", "stars"}, {"月", "moon"},
+	{"雨", "rain"}, {"雪", "snow"}, {"晴れ", "sunshine"}, {"風", "wind"}, {"花", "flowers"}, {"桜", "cherry blossoms"},
+	{"春", "spring"}, {"夏", "summer"}, {"秋", "autumn"}, {"冬", "winter"},
+	{"朝", "morning"}, {"夜", "night"}, {"夕焼け", "sunset"},
+	{"猫", "cat"}, {"犬", "dog"}, {"友達", "friends"}, {"友人", "friends"}, {"家族", "family"}, {"子ども", "children"}, {"子供", "children"},
+	{"仕事", "work"}, {"会社", "office"}, {"学校", "school"}, {"試験", "exam"}, {"テスト", "exam"}, {"部活", "club activities"},
+	{"旅行", "travel"}, {"旅", "journey"}, {"電車", "train"}, {"ライブ", "live concert"}, {"映画", "movie"}, {"カフェ", "cafe"},
+	{"料理", "cooking"}, {"ご飯", "dinner"}, {"誕生日", "birthday"}, {"お祭り", "festival"}, {"祭り", "festival"}, {"花火", "fireworks"},
+	{"卒業", "graduation"}, {"入学", "new beginnings"}, {"引っ越し", "moving"}, {"散歩", "walk"}, {"運動", "exercise"}, {"ゲーム", "games"},
+}
+
+// stopWords は英語のキーワード抽出で無視する語
+var stopWords = map[string]bool{
+	"the": true, "and": true, "was": true, "were": true, "that": true, "this": true, "with": true, "have": true,
+	"had": true, "has": true, "for": true, "but": true, "not": true, "are": true, "you": true, "your": true,
+	"they": true, "them": true, "then": true, "there": true, "their": true, "what": true, "when": true,
+	"where": true, "which": true, "who": true, "will": true, "would": true, "could": true, "should": true,
+	"just": true, "really": true, "very": true, "today": true, "yesterday": true, "tomorrow": true,
+	"from": true, "into": true, "about": true, "after": true, "before": true, "been": true, "being": true,
+	"some": true, "much": true, "more": true, "most": true, "also": true, "even": true, "still": true,
+	"like": true, "felt": true, "feel": true, "feeling": true, "went": true, "going": true, "got": true,
+	"did": true, "does": true, "done": true, "our": true, "his": true, "her": true, "she": true, "him": true,
+	"all": true, "its": true, "it's": true, "i'm": true, "didn't": true, "don't": true, "can't": true,
+	"day": true, "time": true, "lot": true, "thing": true, "things": true, "because": true, "so": true,
+}
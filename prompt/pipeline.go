@@ -0,0 +1,82 @@
+// Package prompt は日記の本文から音楽生成用のプロンプトを組み立てる。
+// 本文そのものはプロバイダーに送らず、抽出したキーワードと気分から短いプロンプトを作る。
+package prompt
+
+import "strings"
+
+// Result はパイプラインの各ステージが書き込む解析結果
+type Result struct {
+	Language string   // "ja" または "en"
+	Keywords []string // プロンプトに含めるキーワード
+	Mood     string   // happy, sad など
+	Genre    string
+	Tempo    string
+	Text     string // 最終的なプロンプト
+}
+
+// Tags は解析結果をタグ文字列（"mood:happy, genre:pop, ..."）にする
+func (r *Result) Tags() string {
+	var tags []string
+	if r.Language != "" {
+		tags = append(tags, "lang:"+r.Language)
+	}
+	if r.Mood != "" {
+		tags = append(tags, "mood:"+r.Mood)
+	}
+	if r.Genre != "" {
+		tags = append(tags, "genre:"+r.Genre)
+	}
+	if r.Tempo != "" {
+		tags = append(tags, "tempo:"+r.Tempo)
+	}
+	for _, k := range r.Keywords {
+		tags = append(tags, "keyword:"+k)
+	}
+	return strings.Join(tags, ", ")
+}
+
+// IStage はパイプラインの1段階
+type IStage interface {
+	Process(content string, r *Result)
+}
+
+// StageFunc は関数を IStage として扱う
+type StageFunc func(content string, r *Result)
+
+func (f StageFunc) Process(content string, r *Result) {
+	f(content, r)
+}
+
+// IPromptBuilder は日記の本文からプロンプトを組み立てる
+type IPromptBuilder interface {
+	Build(content string) Result
+}
+
+type Pipeline struct {
+	stages []IStage
+}
+
+// NewPipeline はステージを順に実行するパイプラインを作る
+func NewPipeline(stages ...IStage) *Pipeline {
+	return &Pipeline{stages}
+}
+
+// NewDefaultPipeline は言語判定、キーワード抽出、気分の判定、ジャンルの決定、
+// プロンプトの生成を行うパイプラインを作る。maxLength はプロンプトの最大文字数。
+func NewDefaultPipeline(maxLength int) IPromptBuilder {
+	return NewPipeline(
+		StageFunc(DetectLanguage),
+		StageFunc(ExtractKeywords),
+		StageFunc(ClassifyMood),
+		StageFunc(MapGenre),
+		Composer{MaxLength: maxLength},
+	)
+}
+
+func (p *Pipeline) Build(content string) Result {
+	r := Result{}
+	for _, stage := range p.stages {
+		stage.Process(content, &r)
+	}
+	return r
+}
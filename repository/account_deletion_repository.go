@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAccountDeletionScheduled はアカウントの削除が予定されているため、日記・生成ジョブ・エクスポートを登録しなかったことを表す
+	ErrAccountDeletionScheduled = errors.New("account deletion is scheduled")
+	// ErrActiveWork は終了していない生成ジョブ・エクスポートがあるため、ユーザーのデータを削除しなかったことを表す
+	ErrActiveWork = errors.New("user has unfinished music jobs or exports")
+)
+
+// IAccountDeletionRepository はアカウント削除の予定と、ユーザーのデータの削除を扱う
+type IAccountDeletionRepository interface {
+	CreateDeletion(ctx context.Context, deletion *model.AccountDeletion) error
+	CancelDeletion(ctx context.Context, userId uint, now time.Time) error
+	ClaimNextDeletion(ctx context.Context, staleAfter time.Duration) (*model.AccountDeletion, error)
+	CountActiveJobs(ctx context.Context, userId uint) (int64, error)
+	GetAssetKeys(ctx context.Context, userId uint) ([]string, error)
+	PurgeUser(ctx context.Context, deletion *model.AccountDeletion, now time.Time) error
+	RecordFailure(ctx context.Context, deletion *model.AccountDeletion, cause error) error
+}
+
+type accountDeletionRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountDeletionRepository(db *gorm.DB) IAccountDeletionRepository {
+	return &accountDeletionRepository{db}
+}
+
+// CreateDeletion は削除の予定を記録してユーザーに予定の時刻を入れる。
+// 既に予定がある場合は gorm.ErrDuplicatedKey
+func (ar *accountDeletionRepository) CreateDeletion(ctx context.Context, deletion *model.AccountDeletion) error {
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(deletion).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.User{}).Where("id = ?", deletion.UserID).Update("deletion_scheduled_at", deletion.PurgeAfter).Error
+	})
+}
+
+// CancelDeletion は削除を始める前の予定を取り消す（予定がない、または始めた後は gorm.ErrRecordNotFound）
+func (ar *accountDeletionRepository) CancelDeletion(ctx context.Context, userId uint, now time.Time) error {
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.AccountDeletion{}).
+			Where("user_id = ? AND cancelled_at IS NULL AND purged_at IS NULL AND started_at IS NULL", userId).
+			Update("cancelled_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Model(&model.User{}).Where("id = ?", userId).Update("deletion_scheduled_at", nil).Error
+	})
+}
+
+// ClaimNextDeletion は猶予期間を過ぎた予定を1件取り出して開始済みにする。
+// staleAfter より前に始めて終わっていない予定（失敗・プロセス停止）も対象とする。
+// 取得できる予定がない場合は nil, nil を返す
+func (ar *accountDeletionRepository) ClaimNextDeletion(ctx context.Context, staleAfter time.Duration) (*model.AccountDeletion, error) {
+	var deletion model.AccountDeletion
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("cancelled_at IS NULL AND purged_at IS NULL AND purge_after <= ?", now).
+			Where("started_at IS NULL OR started_at < ?", now.Add(-staleAfter)).
+			Order("purge_after").
+			First(&deletion).Error
+		if err != nil {
+			return err
+		}
+		deletion.StartedAt = &now
+		return tx.Model(&deletion).Update("started_at", now).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &deletion, nil
+}
+
+// CountActiveJobs は終了していない音楽生成ジョブとエクスポートの数を返す（処理中に削除するとファイルが残るため）
+func (ar *accountDeletionRepository) CountActiveJobs(ctx context.Context, userId uint) (int64, error) {
+	var jobs, exports int64
+	if err := ar.db.WithContext(ctx).Model(&model.MusicJob{}).
+		Where("user_id = ? AND status IN ?", userId, []string{model.MusicJobStatusQueued, model.MusicJobStatusPending, model.MusicJobStatusRunning}).
+		Count(&jobs).Error; err != nil {
+		return 0, err
+	}
+	if err := ar.db.WithContext(ctx).Model(&model.UserExport{}).
+		Where("user_id = ? AND status IN ?", userId, []string{model.ExportStatusQueued, model.ExportStatusRunning}).
+		Count(&exports).Error; err != nil {
+		return 0, err
+	}
+	return jobs + exports, nil
+}
+
+// GetAssetKeys はユーザーの曲の音声・カバー画像とエクスポートのアーカイブのストレージのキーを返す
+func (ar *accountDeletionRepository) GetAssetKeys(ctx context.Context, userId uint) ([]string, error) {
+	var musics []model.Music
+	if err := userMusics(ar.db.WithContext(ctx), userId).
+		Select("audio_key", "image_key").
+		Find(&musics).Error; err != nil {
+		return nil, err
+	}
+	var exports []string
+	if err := ar.db.WithContext(ctx).Model(&model.UserExport{}).
+		Where("user_id = ? AND storage_key <> ''", userId).
+		Pluck("storage_key", &exports).Error; err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(musics)*2+len(exports))
+	keys = append(keys, exports...)
+	for _, music := range musics {
+		for _, key := range []string{music.AudioKey, music.ImageKey} {
+			if key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// PurgeUser はユーザーのデータをすべて削除し、件数を記録して削除済みにする。
+// セッション・リフレッシュトークン・ワンタイムトークン・エクスポートは外部キーの ON DELETE CASCADE で削除される。
+// 終了していない生成ジョブ・エクスポートがある場合は何も削除せずに ErrActiveWork を返す
+func (ar *accountDeletionRepository) PurgeUser(ctx context.Context, deletion *model.AccountDeletion, now time.Time) error {
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		userId := deletion.UserID
+		// ユーザーの行を排他ロックして、lockActiveUser で登録中の日記・ジョブ・エクスポートのコミットを待つ
+		var locked []uint
+		if err := tx.Model(&model.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userId).
+			Pluck("id", &locked).Error; err != nil {
+			return err
+		}
+		// ワーカーが同時に取り出さないよう、終了していないジョブ・エクスポートもロックして確認する
+		var jobs, exports []uint
+		if err := tx.Model(&model.MusicJob{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status IN ?", userId, []string{model.MusicJobStatusQueued, model.MusicJobStatusPending, model.MusicJobStatusRunning}).
+			Pluck("id", &jobs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.UserExport{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status IN ?", userId, []string{model.ExportStatusQueued, model.ExportStatusRunning}).
+			Pluck("id", &exports).Error; err != nil {
+			return err
+		}
+		if len(jobs)+len(exports) > 0 {
+			return ErrActiveWork
+		}
+
+		// 曲は日記に紐づくので先に削除する（user_id のない古い曲も日記から辿る）
+		musics := userMusics(tx, userId).Delete(&model.Music{})
+		if musics.Error != nil {
+			return musics.Error
+		}
+		if err := tx.Where("user_id = ?", userId).Delete(&model.MusicJob{}).Error; err != nil {
+			return err
+		}
+		diaries := tx.Where("user_id = ?", userId).Delete(&model.Diary{})
+		if diaries.Error != nil {
+			return diaries.Error
+		}
+		for _, m := range []interface{}{&model.UsageRecord{}, &model.WebhookDelivery{}, &model.Webhook{}} {
+			if err := tx.Where("user_id = ?", userId).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&model.User{}, userId).Error; err != nil {
+			return err
+		}
+
+		deletion.PurgedAt = &now
+		deletion.Diaries = int(diaries.RowsAffected)
+		deletion.Musics = int(musics.RowsAffected)
+		deletion.LastError = ""
+		return tx.Model(deletion).Updates(map[string]interface{}{
+			"purged_at":  deletion.PurgedAt,
+			"diaries":    deletion.Diaries,
+			"musics":     deletion.Musics,
+			"assets":     deletion.Assets,
+			"last_error": deletion.LastError,
+		}).Error
+	})
+}
+
+// RecordFailure は削除に失敗した理由を記録する（staleAfter を過ぎると再び取り出される）
+func (ar *accountDeletionRepository) RecordFailure(ctx context.Context, deletion *model.AccountDeletion, cause error) error {
+	deletion.LastError = cause.Error()
+	return ar.db.WithContext(ctx).Model(deletion).Update("last_error", deletion.LastError).Error
+}
+
+// lockActiveUser はユーザーの行を共有ロックし、削除が予定されている場合は ErrAccountDeletionScheduled を返す。
+// 日記・生成ジョブ・エクスポートを登録するトランザクションで最初に呼び出し、
+// 削除の予定 (CreateDeletion) や削除 (PurgeUser) と同時に登録されないようにする
+func lockActiveUser(tx *gorm.DB, userId uint) error {
+	var user model.User
+	if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+		Select("id", "deletion_scheduled_at").
+		First(&user, userId).Error; err != nil {
+		return err
+	}
+	if user.DeletionScheduledAt != nil {
+		return ErrAccountDeletionScheduled
+	}
+	return nil
+}
+
+// userMusics はユーザーの曲を絞り込む
+func userMusics(db *gorm.DB, userId uint) *gorm.DB {
+	return db.Where("user_id = ? OR diary_id IN (?)", userId,
+		db.Session(&gorm.Session{NewDB: true}).Model(&model.Diary{}).Select("id").Where("user_id = ?", userId))
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+)
+
+func TestPurgeUserPostponesActiveWork(t *testing.T) {
+	db, fake := newFakeDB(t,
+		fakeRows{Columns: []string{"id"}, Values: [][]driver.Value{{int64(1)}}},
+		// ワーカーが取り出した生成中のジョブ
+		fakeRows{Columns: []string{"id"}, Values: [][]driver.Value{{int64(9)}}},
+	)
+	deletion := &model.AccountDeletion{ID: 2, UserID: 1}
+
+	err := NewAccountDeletionRepository(db).PurgeUser(context.Background(), deletion, time.Now())
+	if !errors.Is(err, ErrActiveWork) {
+		t.Fatalf("err = %v, want ErrActiveWork", err)
+	}
+	lock := fake.find(t, `FROM "users"`)
+	if !strings.HasSuffix(lock.Query, "FOR UPDATE") {
+		t.Errorf("user lock query = %s", lock.Query)
+	}
+	if jobs := fake.find(t, `FROM "music_jobs"`); !strings.HasSuffix(jobs.Query, "FOR UPDATE") {
+		t.Errorf("active job query = %s", jobs.Query)
+	}
+	for _, s := range fake.statements {
+		if strings.HasPrefix(s.Query, "DELETE") || strings.HasPrefix(s.Query, "UPDATE") {
+			t.Errorf("unexpected write: %s", s.Query)
+		}
+	}
+	if deletion.PurgedAt != nil || fake.rollbacks != 1 {
+		t.Errorf("purged_at = %v, rollbacks = %d, want the purge rolled back", deletion.PurgedAt, fake.rollbacks)
+	}
+}
+
+func TestPurgeUser(t *testing.T) {
+	db, fake := newFakeDB(t, fakeRows{Columns: []string{"id"}, Values: [][]driver.Value{{int64(1)}}})
+	deletion := &model.AccountDeletion{ID: 2, UserID: 1}
+
+	if err := NewAccountDeletionRepository(db).PurgeUser(context.Background(), deletion, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	fake.find(t, `FROM "user_exports"`)
+	fake.find(t, `DELETE FROM "users"`)
+	if deletion.PurgedAt == nil || fake.commits != 1 {
+		t.Errorf("purged_at = %v, commits = %d, want the purge committed", deletion.PurgedAt, fake.commits)
+	}
+}
+
+func TestCreateBlockedWhileDeletionScheduled(t *testing.T) {
+	scheduled := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		table  string
+		create func(context.Context, *gorm.DB) error
+	}{
+		{"diary", "diaries", func(ctx context.Context, db *gorm.DB) error {
+			return NewDiaryRepository(db).CreateDiary(ctx, &model.Diary{UserId: 1, Content: "今日"})
+		}},
+		{"diary with music job", "diaries", func(ctx context.Context, db *gorm.DB) error {
+			_, err := NewDiaryRepository(db).CreateDiaryWithMusicJob(ctx, &model.Diary{UserId: 1, Content: "今日"}, &model.MusicJob{})
+			return err
+		}},
+		{"music job", "music_jobs", func(ctx context.Context, db *gorm.DB) error {
+			return NewMusicJobRepository(db).CreateJob(ctx, &model.MusicJob{UserID: 1, DiaryID: 3})
+		}},
+		{"export", "user_exports", func(ctx context.Context, db *gorm.DB) error {
+			return NewExportRepository(db).CreateExport(ctx, &model.UserExport{UserID: 1})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB(t, fakeRows{
+				Columns: []string{"id", "deletion_scheduled_at"},
+				Values:  [][]driver.Value{{int64(1), scheduled}},
+			})
+			err := tt.create(context.Background(), db)
+			if !errors.Is(err, ErrAccountDeletionScheduled) {
+				t.Fatalf("err = %v, want ErrAccountDeletionScheduled", err)
+			}
+			if lock := fake.find(t, `FROM "users"`); !strings.HasSuffix(lock.Query, "FOR SHARE") {
+				t.Errorf("user lock query = %s", lock.Query)
+			}
+			for _, s := range fake.statements {
+				if strings.Contains(s.Query, `INSERT INTO "`+tt.table+`"`) {
+					t.Errorf("unexpected insert: %s", s.Query)
+				}
+			}
+		})
+	}
+}
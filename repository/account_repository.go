@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IAccountRepository はパスワードの再設定・メールアドレスの確認に使うワンタイムトークンを扱う
+type IAccountRepository interface {
+	CreateToken(ctx context.Context, token *model.UserToken) error
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash string, now time.Time) (uint, error)
+	VerifyEmail(ctx context.Context, tokenHash string, now time.Time) (uint, error)
+}
+
+type accountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(db *gorm.DB) IAccountRepository {
+	return &accountRepository{db}
+}
+
+// CreateToken はトークンを保存する。同じ用途の未使用のトークンは無効にする（最後に送ったメールのみ使える）
+func (ar *accountRepository) CreateToken(ctx context.Context, token *model.UserToken) error {
+	return ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Delete(&model.UserToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ResetPassword はパスワード再設定のトークンを使用済みにしてパスワードを更新し、ユーザーIDを返す。
+// トークンが存在しない、使用済み、期限切れの場合は gorm.ErrRecordNotFound
+func (ar *accountRepository) ResetPassword(ctx context.Context, tokenHash string, passwordHash string, now time.Time) (uint, error) {
+	var userId uint
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := useToken(tx, tokenHash, now, model.UserTokenPasswordReset)
+		if err != nil {
+			return err
+		}
+		userId = token.UserID
+		return tx.Model(&model.User{}).Where("id = ?", token.UserID).Update("password", passwordHash).Error
+	})
+	return userId, err
+}
+
+// VerifyEmail はメールアドレス確認・変更のトークンを使用済みにしてユーザーを確認済みにし、ユーザーIDを返す。
+// 確認のトークンを送った後にメールアドレスが変更された場合も gorm.ErrRecordNotFound。
+// 変更のトークンの場合はメールアドレスを変更する（他のユーザーが登録済みなら gorm.ErrDuplicatedKey）
+func (ar *accountRepository) VerifyEmail(ctx context.Context, tokenHash string, now time.Time) (uint, error) {
+	var userId uint
+	err := ar.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		token, err := useToken(tx, tokenHash, now, model.UserTokenEmailVerification, model.UserTokenEmailChange)
+		if err != nil {
+			return err
+		}
+		userId = token.UserID
+		if token.Purpose == model.UserTokenEmailChange {
+			return changeEmail(tx, token, now)
+		}
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND email = ?", token.UserID, token.Email).
+			Update("email_verified_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	return userId, err
+}
+
+// changeEmail はメールアドレスを確認済みの新しいアドレスにし、古いアドレスに送った未使用のトークンを無効にする
+func changeEmail(tx *gorm.DB, token *model.UserToken, now time.Time) error {
+	result := tx.Model(&model.User{}).
+		Where("id = ?", token.UserID).
+		Updates(map[string]interface{}{
+			"email":             token.Email,
+			"email_verified_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return tx.Where("user_id = ? AND used_at IS NULL", token.UserID).Delete(&model.UserToken{}).Error
+}
+
+// useToken は有効なトークンをロックして使用済みにする（同時に使われた場合は後の方が失敗する）
+func useToken(tx *gorm.DB, tokenHash string, now time.Time, purposes ...string) (*model.UserToken, error) {
+	var token model.UserToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose IN ?", tokenHash, purposes).
+		First(&token).Error; err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
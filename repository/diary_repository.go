@@ -1,45 +1,44 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"math"
 
 	"github.com/kenta-kenta/diary-music/model"
-	"github.com/kenta-kenta/diary-music/service"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type IDiaryRepository interface {
-	GetAllDiaries(query *model.PaginationQuery, userId uint) (*model.PaginationResponse, error)
-	GetDiaryById(diary *model.Diary, userId uint, diaryId uint) error
-	CreateDiary(diary *model.Diary) error
-	UpdateDiary(diary *model.Diary, userId uint, diaryId uint) error
-	DeleteDiary(userId uint, diaryId uint) error
-	GetDiaryDates(userId uint, year, month int) ([]model.DiaryDateCount, error)
-	CreateDiaryWithMusic(diary *model.Diary, musicReq *model.MusicRequest) (*model.DiaryResponse, error)
+	GetAllDiaries(ctx context.Context, query *model.PaginationQuery, userId uint) (*model.PaginationResponse, error)
+	GetDiaryById(ctx context.Context, diary *model.Diary, userId uint, diaryId uint) error
+	CreateDiary(ctx context.Context, diary *model.Diary) error
+	UpdateDiary(ctx context.Context, diary *model.Diary, userId uint, diaryId uint) error
+	DeleteDiary(ctx context.Context, userId uint, diaryId uint) ([]string, error)
+	GetDiaryDates(ctx context.Context, userId uint, year, month int) ([]model.DiaryDateCount, error)
+	CreateDiaryWithMusicJob(ctx context.Context, diary *model.Diary, job *model.MusicJob) (*model.DiaryResponse, error)
 }
 
 type diaryRepository struct {
-	db           *gorm.DB
-	MusicService service.IMusicService
+	db *gorm.DB
 }
 
 func NewDiaryRepository(db *gorm.DB) IDiaryRepository {
-	return &diaryRepository{db, service.NewMusicService()}
+	return &diaryRepository{db}
 }
 
-func (dr *diaryRepository) GetAllDiaries(query *model.PaginationQuery, userId uint) (*model.PaginationResponse, error) {
+func (dr *diaryRepository) GetAllDiaries(ctx context.Context, query *model.PaginationQuery, userId uint) (*model.PaginationResponse, error) {
 	var diaries []model.Diary
 	var total int64
 	// ページ番号からオフセットを計算
 	offset := (query.Page - 1) * query.PageSize
 	// Countメソッドを使ってデータの総数を取得
-	if err := dr.db.Model(&model.Diary{}).Where("user_id = ?", userId).Count(&total).Error; err != nil {
+	if err := dr.db.WithContext(ctx).Model(&model.Diary{}).Where("user_id = ?", userId).Count(&total).Error; err != nil {
 		return nil, err
 	}
 	// Whereメソッドを使ってデータを取得
-	if err := dr.db.Preload("Music").Where("user_id = ?", userId).
+	if err := dr.db.WithContext(ctx).Preload("Music", orderMusic).Where("user_id = ?", userId).
 		Offset(offset).
 		Limit(query.PageSize).
 		Order("created_at DESC").
@@ -52,16 +51,9 @@ func (dr *diaryRepository) GetAllDiaries(query *model.PaginationQuery, userId ui
 	// DiaryResponseのスライスを作成
 	var diaryResponses []model.DiaryResponse
 	for _, diary := range diaries {
-		var musicData []model.MusicData
+		musicData := []model.MusicData{}
 		for _, music := range diary.Music {
-			musicData = append(musicData, model.MusicData{
-				AudioFile: music.AudioFile,
-				ImageFile: music.ImageFile,
-				ItemUUID:  music.ItemUUID,
-				Title:     music.Title,
-				Lyric:     music.Lyrics,
-				Tags:      music.Tags,
-			})
+			musicData = append(musicData, model.ToMusicData(music))
 		}
 		diaryResponses = append(diaryResponses, model.DiaryResponse{
 			ID:        diary.ID,
@@ -81,10 +73,15 @@ func (dr *diaryRepository) GetAllDiaries(query *model.PaginationQuery, userId ui
 	}, nil
 }
 
-func (dr *diaryRepository) GetDiaryById(diary *model.Diary, userId uint, diaryId uint) error {
+// orderMusic は日記に紐づく曲を代表曲、作成順の順に並べる
+func orderMusic(db *gorm.DB) *gorm.DB {
+	return db.Order("is_primary DESC").Order("id")
+}
+
+func (dr *diaryRepository) GetDiaryById(ctx context.Context, diary *model.Diary, userId uint, diaryId uint) error {
 	// Joinメソッドを使ってUserテーブルと結合し、Preloadメソッドを使ってMusicデータを事前にロード
-	if err := dr.db.Joins("JOIN users ON users.id = diaries.user_id").
-		Preload("Music").
+	if err := dr.db.WithContext(ctx).Joins("JOIN users ON users.id = diaries.user_id").
+		Preload("Music", orderMusic).
 		Where("diaries.user_id = ? AND diaries.id = ?", userId, diaryId).
 		First(diary).Error; err != nil {
 		return err
@@ -92,10 +89,10 @@ func (dr *diaryRepository) GetDiaryById(diary *model.Diary, userId uint, diaryId
 	return nil
 }
 
-func (dr *diaryRepository) GetDiaryDates(userId uint, year, month int) ([]model.DiaryDateCount, error) {
+func (dr *diaryRepository) GetDiaryDates(ctx context.Context, userId uint, year, month int) ([]model.DiaryDateCount, error) {
 	var results []model.DiaryDateCount
 
-	err := dr.db.Model(&model.Diary{}).
+	err := dr.db.WithContext(ctx).Model(&model.Diary{}).
 		Select("TO_CHAR(DATE(created_at), 'YYYY-MM-DD') as date, COUNT(*) as count").
 		Where("user_id = ? AND EXTRACT(YEAR FROM created_at) = ? AND EXTRACT(MONTH FROM created_at) = ?",
 			userId, year, month).
@@ -111,50 +108,46 @@ func (dr *diaryRepository) GetDiaryDates(userId uint, year, month int) ([]model.
 	return results, nil
 }
 
-func (dr *diaryRepository) CreateDiary(diary *model.Diary) error {
-	// Createメソッドを使ってデータを作成
-	if err := dr.db.Create(diary).Error; err != nil {
-		return err
-	}
-	return nil
+// CreateDiary は日記を保存する。アカウントの削除が予定されている場合は ErrAccountDeletionScheduled
+func (dr *diaryRepository) CreateDiary(ctx context.Context, diary *model.Diary) error {
+	return dr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockActiveUser(tx, diary.UserId); err != nil {
+			return err
+		}
+		return tx.Create(diary).Error
+	})
 }
 
-func (dr *diaryRepository) CreateDiaryWithMusic(diary *model.Diary, musicReq *model.MusicRequest) (*model.DiaryResponse, error) {
+// CreateDiaryWithMusicJob は日記と音楽生成ジョブを同一トランザクションで保存する。
+// 音楽の生成自体はワーカーが非同期に行うため、ここでは外部APIを呼び出さない。
+// アカウントの削除が予定されている場合は ErrAccountDeletionScheduled
+func (dr *diaryRepository) CreateDiaryWithMusicJob(ctx context.Context, diary *model.Diary, job *model.MusicJob) (*model.DiaryResponse, error) {
 	var diaryRes *model.DiaryResponse
-	err := dr.db.Transaction(func(tx *gorm.DB) error {
-		// 1. 日記を保存
-		if err := tx.Create(diary).Error; err != nil {
+	err := dr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockActiveUser(tx, diary.UserId); err != nil {
 			return err
 		}
 
-		// 2. 音楽を生成・保存
-		musicReq.Prompt = diary.Content
-		music, err := dr.MusicService.CreateMusic(musicReq.Prompt)
-		if err != nil {
+		// 1. 日記を保存
+		if err := tx.Create(diary).Error; err != nil {
 			return err
 		}
 
-		music.DiaryID = diary.ID
-		music.UserID = diary.UserId
-		if err := tx.Create(music).Error; err != nil {
+		// 2. 音楽生成ジョブを登録
+		job.UserID = diary.UserId
+		job.DiaryID = diary.ID
+		job.Status = model.MusicJobStatusQueued
+		if err := tx.Create(job).Error; err != nil {
 			return err
 		}
 
 		diaryRes = &model.DiaryResponse{
-			ID:      diary.ID,
-			Content: diary.Content,
-			MusicData: []model.MusicData{
-				{
-					AudioFile: music.AudioFile,
-					ImageFile: music.ImageFile,
-					ItemUUID:  music.ItemUUID,
-					Title:     music.Title,
-					Lyric:     music.Lyrics,
-					Tags:      music.Tags,
-				},
-			},
-			CreatedAt: diary.CreatedAt,
-			UpdatedAt: diary.UpdatedAt,
+			ID:          diary.ID,
+			Content:     diary.Content,
+			MusicData:   []model.MusicData{},
+			MusicStatus: job.Status,
+			CreatedAt:   diary.CreatedAt,
+			UpdatedAt:   diary.UpdatedAt,
 		}
 
 		return nil
@@ -163,34 +156,53 @@ func (dr *diaryRepository) CreateDiaryWithMusic(diary *model.Diary, musicReq *mo
 	return diaryRes, err
 }
 
-func (dr *diaryRepository) UpdateDiary(diary *model.Diary, userId uint, diaryId uint) error {
+func (dr *diaryRepository) UpdateDiary(ctx context.Context, diary *model.Diary, userId uint, diaryId uint) error {
 	// Returningメソッドを使って更新後のデータを取得
-	result := dr.db.Model(diary).Clauses(clause.Returning{}).Where("user_id = ? AND id = ?", userId, diaryId).Update("content", diary.Content)
+	result := dr.db.WithContext(ctx).Model(diary).Clauses(clause.Returning{}).Where("user_id = ? AND id = ?", userId, diaryId).Update("content", diary.Content)
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected < 1 {
-		return fmt.Errorf("object does not exist")
+		return gorm.ErrRecordNotFound
 	}
 	return nil
 }
 
-func (dr *diaryRepository) DeleteDiary(userId uint, diaryId uint) error {
-	return dr.db.Transaction(func(tx *gorm.DB) error {
-		// 1. まず関連するMusicレコードを削除
-		if err := tx.Where("diary_id = ?", diaryId).Delete(&model.Music{}).Error; err != nil {
+// DeleteDiary は日記と曲・生成ジョブを削除し、曲の音声・カバー画像のストレージのキーを返す
+func (dr *diaryRepository) DeleteDiary(ctx context.Context, userId uint, diaryId uint) ([]string, error) {
+	var keys []string
+	err := dr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 他のユーザーの日記の曲を削除しないよう、先に日記の所有者を確認する
+		var diary model.Diary
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND id = ?", userId, diaryId).
+			First(&diary).Error; err != nil {
 			return err
 		}
 
-		// 2. 次にDiaryレコードを削除
-		result := tx.Where("user_id = ? AND id = ?", userId, diaryId).Delete(&model.Diary{})
-		if result.Error != nil {
-			return result.Error
+		// 2. 関連するMusicレコードと生成ジョブを削除
+		var musics []model.Music
+		if err := tx.Clauses(clause.Returning{Columns: []clause.Column{{Name: "audio_key"}, {Name: "image_key"}}}).
+			Where("diary_id = ?", diaryId).
+			Delete(&musics).Error; err != nil {
+			return err
+		}
+		for _, music := range musics {
+			for _, key := range []string{music.AudioKey, music.ImageKey} {
+				if key != "" {
+					keys = append(keys, key)
+				}
+			}
 		}
-		if result.RowsAffected < 1 {
-			return fmt.Errorf("日記が見つかりません")
+		if err := tx.Where("user_id = ? AND diary_id = ?", userId, diaryId).Delete(&model.MusicJob{}).Error; err != nil {
+			return err
 		}
 
-		return nil
+		// 3. 次にDiaryレコードを削除
+		return tx.Delete(&diary).Error
 	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
 }
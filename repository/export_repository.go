@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IExportRepository は個人データのエクスポートのキューと、アーカイブに含めるデータを扱う
+type IExportRepository interface {
+	// CreateExport は作成中のエクスポートがある場合は gorm.ErrDuplicatedKey を返す
+	CreateExport(ctx context.Context, export *model.UserExport) error
+	GetActiveExport(ctx context.Context, export *model.UserExport, userId uint) error
+	GetExports(ctx context.Context, userId uint, limit int) ([]model.UserExport, error)
+	GetExportById(ctx context.Context, export *model.UserExport, userId uint, exportId uint) error
+	ClaimNextExport(ctx context.Context, staleAfter time.Duration) (*model.UserExport, error)
+	GetExportDiaries(ctx context.Context, userId uint) ([]model.Diary, error)
+	UpdateExport(ctx context.Context, export *model.UserExport) error
+	// RequeueExport は中断したエクスポートを queued に戻す。中断した試行は回数に数えない
+	RequeueExport(ctx context.Context, export *model.UserExport) error
+	// TouchExport は running のエクスポートの updated_at を進め、作成中であることを記録する
+	TouchExport(ctx context.Context, export *model.UserExport) error
+	GetExpiredExport(ctx context.Context, now time.Time) (*model.UserExport, error)
+}
+
+type exportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) IExportRepository {
+	return &exportRepository{db}
+}
+
+// CreateExport はエクスポートを登録する。アカウントの削除が予定されている場合は ErrAccountDeletionScheduled
+func (er *exportRepository) CreateExport(ctx context.Context, export *model.UserExport) error {
+	return er.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockActiveUser(tx, export.UserID); err != nil {
+			return err
+		}
+		return tx.Create(export).Error
+	})
+}
+
+// GetActiveExport は作成待ち・作成中のエクスポートを返す
+func (er *exportRepository) GetActiveExport(ctx context.Context, export *model.UserExport, userId uint) error {
+	return er.db.WithContext(ctx).
+		Where("user_id = ? AND status IN ?", userId, []string{model.ExportStatusQueued, model.ExportStatusRunning}).
+		First(export).Error
+}
+
+// GetExports は新しい順に limit 件返す
+func (er *exportRepository) GetExports(ctx context.Context, userId uint, limit int) ([]model.UserExport, error) {
+	var exports []model.UserExport
+	if err := er.db.WithContext(ctx).
+		Where("user_id = ?", userId).
+		Order("id DESC").
+		Limit(limit).
+		Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+func (er *exportRepository) GetExportById(ctx context.Context, export *model.UserExport, userId uint, exportId uint) error {
+	return er.db.WithContext(ctx).Where("user_id = ? AND id = ?", userId, exportId).First(export).Error
+}
+
+// ClaimNextExport は作成待ちのエクスポートを1件取り出し running に遷移させる。
+// updated_at が staleAfter より古い running のもの（プロセス停止で取り残されたもの）も対象とする。
+// 取得できるものがない場合は nil, nil を返す
+func (er *exportRepository) ClaimNextExport(ctx context.Context, staleAfter time.Duration) (*model.UserExport, error) {
+	var export model.UserExport
+	err := er.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND updated_at < ?)",
+				model.ExportStatusQueued,
+				model.ExportStatusRunning, now.Add(-staleAfter)).
+			Order("id").
+			First(&export).Error
+		if err != nil {
+			return err
+		}
+		export.Status = model.ExportStatusRunning
+		export.Attempts++
+		export.StartedAt = &now
+		return tx.Model(&export).Updates(map[string]interface{}{
+			"status":     export.Status,
+			"attempts":   export.Attempts,
+			"started_at": export.StartedAt,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetExportDiaries はユーザーの日記を曲と一緒に古い順に返す
+func (er *exportRepository) GetExportDiaries(ctx context.Context, userId uint) ([]model.Diary, error) {
+	var diaries []model.Diary
+	if err := er.db.WithContext(ctx).
+		Preload("Music", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("user_id = ?", userId).
+		Order("created_at, id").
+		Find(&diaries).Error; err != nil {
+		return nil, err
+	}
+	return diaries, nil
+}
+
+// UpdateExport は状態と結果を保存する
+func (er *exportRepository) RequeueExport(ctx context.Context, export *model.UserExport) error {
+	export.Status = model.ExportStatusQueued
+	export.Attempts--
+	export.StartedAt = nil
+	return er.db.WithContext(ctx).Model(export).Updates(map[string]interface{}{
+		"status":     export.Status,
+		"attempts":   export.Attempts,
+		"started_at": export.StartedAt,
+	}).Error
+}
+
+func (er *exportRepository) UpdateExport(ctx context.Context, export *model.UserExport) error {
+	return er.db.WithContext(ctx).Model(export).Updates(map[string]interface{}{
+		"status":      export.Status,
+		"storage_key": export.StorageKey,
+		"size":        export.Size,
+		"diaries":     export.Diaries,
+		"musics":      export.Musics,
+		"files":       export.Files,
+		"error":       export.Error,
+		"expires_at":  export.ExpiresAt,
+		"finished_at": export.FinishedAt,
+	}).Error
+}
+
+func (er *exportRepository) TouchExport(ctx context.Context, export *model.UserExport) error {
+	return er.db.WithContext(ctx).Model(&model.UserExport{}).
+		Where("id = ? AND status = ?", export.ID, model.ExportStatusRunning).
+		Update("updated_at", time.Now()).Error
+}
+
+// GetExpiredExport は保存期間を過ぎたアーカイブを1件返す（ない場合は nil, nil）
+func (er *exportRepository) GetExpiredExport(ctx context.Context, now time.Time) (*model.UserExport, error) {
+	var export model.UserExport
+	err := er.db.WithContext(ctx).
+		Where("status = ? AND expires_at <= ?", model.ExportStatusSucceeded, now).
+		Order("expires_at").
+		First(&export).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+func TestClaimNextExport(t *testing.T) {
+	db, fake := newFakeDB(t, fakeRows{
+		Columns: []string{"id", "user_id", "status", "attempts"},
+		Values:  [][]driver.Value{{int64(4), int64(1), model.ExportStatusRunning, int64(1)}},
+	})
+	staleAfter := 10 * time.Minute
+	before := time.Now()
+
+	export, err := NewExportRepository(db).ClaimNextExport(context.Background(), staleAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export == nil || export.ID != 4 || export.Status != model.ExportStatusRunning || export.Attempts != 2 || export.StartedAt == nil {
+		t.Fatalf("export = %+v, want export 4 running on its second attempt", export)
+	}
+
+	// queued と updated_at が staleAfter より古い running を SKIP LOCKED で取る
+	claim := fake.find(t, `FROM "user_exports"`)
+	if !strings.Contains(claim.Query, "FOR UPDATE SKIP LOCKED") || !strings.Contains(claim.Query, "updated_at <") {
+		t.Errorf("claim query = %s", claim.Query)
+	}
+	if len(claim.Args) < 3 || claim.Args[0] != model.ExportStatusQueued || claim.Args[1] != model.ExportStatusRunning {
+		t.Fatalf("claim args = %v", claim.Args)
+	}
+	if staleBefore, _ := claim.Args[2].(time.Time); staleBefore.After(before.Add(-staleAfter).Add(time.Second)) || staleBefore.Before(before.Add(-staleAfter)) {
+		t.Errorf("updated_at < %v, want about %v", staleBefore, before.Add(-staleAfter))
+	}
+
+	update := fake.find(t, `UPDATE "user_exports"`)
+	if !containsValues(update.Args, model.ExportStatusRunning, int64(2)) {
+		t.Errorf("update args = %v, want status running and attempts 2", update.Args)
+	}
+	if fake.commits != 1 || fake.rollbacks != 0 {
+		t.Errorf("commits = %d, rollbacks = %d, want one commit", fake.commits, fake.rollbacks)
+	}
+}
+
+func TestClaimNextExportEmpty(t *testing.T) {
+	db, fake := newFakeDB(t)
+
+	export, err := NewExportRepository(db).ClaimNextExport(context.Background(), time.Minute)
+	if export != nil || err != nil {
+		t.Fatalf("ClaimNextExport() = %+v, %v, want nil, nil", export, err)
+	}
+	if fake.commits != 0 || fake.rollbacks != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want one rollback", fake.commits, fake.rollbacks)
+	}
+}
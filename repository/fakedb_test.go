@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeStatement は実行された SQL と引数
+type fakeStatement struct {
+	Query string
+	Args  []driver.Value
+}
+
+// fakeRows は SELECT に返す結果
+type fakeRows struct {
+	Columns []string
+	Values  [][]driver.Value
+}
+
+// fakeDB は実行された SQL を記録し、SELECT には rows を、それ以外には affected（尽きたら1行）を順に返す
+// database/sql のドライバー。
+// Postgres を起動せずにリポジトリが組み立てる SQL とトランザクションを検証するために使う
+type fakeDB struct {
+	mu         sync.Mutex
+	rows       []fakeRows
+	affected   []int64
+	statements []fakeStatement
+	commits    int
+	rollbacks  int
+}
+
+// newFakeDB は fakeDB に接続した gorm.DB を返す。rows は SELECT の実行順に返される
+func newFakeDB(t *testing.T, rows ...fakeRows) (*gorm.DB, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{rows: rows}
+	sqlDB := sql.OpenDB(fake)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:               logger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, fake
+}
+
+// find は query を含む最初の SQL を返す
+func (f *fakeDB) find(t *testing.T, query string) fakeStatement {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, s := range f.statements {
+		if strings.Contains(s.Query, query) {
+			return s
+		}
+	}
+	t.Fatalf("no statement contains %q: %+v", query, f.statements)
+	return fakeStatement{}
+}
+
+func (f *fakeDB) record(query string, args []driver.NamedValue) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements = append(f.statements, fakeStatement{query, values})
+}
+
+// driver.Connector
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakeDB: use sql.OpenDB")
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB: prepared statements are not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{c.db}, nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query, args)
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if len(c.db.rows) == 0 {
+		return &fakeResultRows{}, nil
+	}
+	rows := c.db.rows[0]
+	c.db.rows = c.db.rows[1:]
+	return &fakeResultRows{rows: rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query, args)
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	if len(c.db.affected) == 0 {
+		return driver.RowsAffected(1), nil
+	}
+	n := c.db.affected[0]
+	c.db.affected = c.db.affected[1:]
+	return driver.RowsAffected(n), nil
+}
+
+type fakeTx struct{ db *fakeDB }
+
+func (tx *fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rollbacks++
+	return nil
+}
+
+type fakeResultRows struct {
+	rows fakeRows
+	next int
+}
+
+func (r *fakeResultRows) Columns() []string { return r.rows.Columns }
+func (r *fakeResultRows) Close() error      { return nil }
+
+func (r *fakeResultRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.Values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.Values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"io"
+
+	"github.com/kenta-kenta/diary-music/migration"
+	"gorm.io/gorm"
+)
+
+type IHealthRepository interface {
+	Ping(ctx context.Context) error
+	DBStats() (sql.DBStats, error)
+	// SchemaVersion は適用済みの最新のマイグレーションと未適用のマイグレーションの数を返す
+	SchemaVersion(ctx context.Context) (string, int, error)
+}
+
+type healthRepository struct {
+	db *gorm.DB
+}
+
+func NewHealthRepository(db *gorm.DB) IHealthRepository {
+	return &healthRepository{db}
+}
+
+func (hr *healthRepository) Ping(ctx context.Context) error {
+	sqlDB, err := hr.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (hr *healthRepository) DBStats() (sql.DBStats, error) {
+	sqlDB, err := hr.db.DB()
+	if err != nil {
+		return sql.DBStats{}, err
+	}
+	return sqlDB.Stats(), nil
+}
+
+func (hr *healthRepository) SchemaVersion(ctx context.Context) (string, int, error) {
+	statuses, err := migration.New(hr.db.WithContext(ctx), io.Discard).Status()
+	if err != nil {
+		return "", 0, err
+	}
+	version, pending := "", 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		} else {
+			version = status.Version
+		}
+	}
+	return version, pending, nil
+}
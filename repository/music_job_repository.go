@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrJobNotRunning は完了しようとしたジョブが既に running でない（削除された・他の状態になった）ことを表す
+var ErrJobNotRunning = errors.New("music job is no longer running")
+
+type IMusicJobRepository interface {
+	CreateJob(ctx context.Context, job *model.MusicJob) error
+	ClaimNextJob(ctx context.Context, staleAfter time.Duration) (*model.MusicJob, error)
+	CompleteJob(ctx context.Context, job *model.MusicJob, musics []model.Music) error
+	// DeferJob / FailJob の reason はクライアントに返す失敗の理由のコード（エラーの内容そのものは保存しない）
+	DeferJob(ctx context.Context, job *model.MusicJob, runAfter time.Time, reason string) error
+	FailJob(ctx context.Context, job *model.MusicJob, reason string) error
+	// TouchJob は running のジョブの updated_at を進め、処理中であることを記録する
+	TouchJob(ctx context.Context, job *model.MusicJob) error
+	RequeueJob(ctx context.Context, job *model.MusicJob) error
+	GetLatestJobByDiary(ctx context.Context, job *model.MusicJob, userId uint, diaryId uint) error
+	GetJobsByDiary(ctx context.Context, userId uint, diaryId uint) ([]model.MusicJob, error)
+	CountWaitingJobs(ctx context.Context, userId uint) (int64, error)
+	CountActiveJobs(ctx context.Context) (map[string]int64, error)
+}
+
+type musicJobRepository struct {
+	db *gorm.DB
+}
+
+func NewMusicJobRepository(db *gorm.DB) IMusicJobRepository {
+	return &musicJobRepository{db}
+}
+
+// CreateJob はジョブを登録する。アカウントの削除が予定されている場合は ErrAccountDeletionScheduled
+func (jr *musicJobRepository) CreateJob(ctx context.Context, job *model.MusicJob) error {
+	if job.Status == "" {
+		job.Status = model.MusicJobStatusQueued
+	}
+	return jr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockActiveUser(tx, job.UserID); err != nil {
+			return err
+		}
+		return tx.Create(job).Error
+	})
+}
+
+// ClaimNextJob は待機中のジョブを1件取り出し running に遷移させる。
+// run_after を過ぎた pending のジョブと、staleAfter より長く running のままのジョブ
+// （プロセス停止で取り残されたもの）も取得の対象とする。
+// 取得できるジョブがない場合は nil, nil を返す。
+func (jr *musicJobRepository) ClaimNextJob(ctx context.Context, staleAfter time.Duration) (*model.MusicJob, error) {
+	var job model.MusicJob
+	err := jr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED で複数ワーカーが同じジョブを取らないようにする
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND run_after <= ?) OR (status = ? AND updated_at < ?)",
+				model.MusicJobStatusQueued,
+				model.MusicJobStatusPending, now,
+				model.MusicJobStatusRunning, now.Add(-staleAfter)).
+			Order("id").
+			First(&job).Error
+		if err != nil {
+			return err
+		}
+
+		job.Status = model.MusicJobStatusRunning
+		job.Attempts++
+		job.StartedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":     job.Status,
+			"attempts":   job.Attempts,
+			"started_at": job.StartedAt,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CompleteJob は生成された音楽の保存とジョブの完了を同一トランザクションで行う。
+// 生成中に日記が削除されるなどしてジョブが running でなくなっていた場合は、音楽を保存せずに ErrJobNotRunning を返す
+func (jr *musicJobRepository) CompleteJob(ctx context.Context, job *model.MusicJob, musics []model.Music) error {
+	return jr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&model.MusicJob{}).
+			Where("id = ? AND status = ?", job.ID, model.MusicJobStatusRunning).
+			Updates(map[string]interface{}{
+				"status":      model.MusicJobStatusSucceeded,
+				"error":       "",
+				"finished_at": &now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrJobNotRunning
+		}
+
+		for i := range musics {
+			musics[i].DiaryID = job.DiaryID
+			musics[i].UserID = job.UserID
+			musics[i].JobID = job.ID
+			musics[i].PromptTags = job.PromptTags
+		}
+		if err := createMusics(tx, musics); err != nil {
+			return err
+		}
+
+		job.Status = model.MusicJobStatusSucceeded
+		job.Error = ""
+		job.FinishedAt = &now
+		return nil
+	})
+}
+
+// DeferJob はジョブを pending に戻し、runAfter 以降に再実行されるようにする
+func (jr *musicJobRepository) DeferJob(ctx context.Context, job *model.MusicJob, runAfter time.Time, reason string) error {
+	job.Status = model.MusicJobStatusPending
+	job.Error = reason
+	job.RunAfter = &runAfter
+	return jr.db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+		"status":    job.Status,
+		"error":     job.Error,
+		"run_after": job.RunAfter,
+	}).Error
+}
+
+func (jr *musicJobRepository) FailJob(ctx context.Context, job *model.MusicJob, reason string) error {
+	now := time.Now()
+	job.Status = model.MusicJobStatusFailed
+	job.Error = reason
+	job.FinishedAt = &now
+	return jr.db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+		"status":      job.Status,
+		"error":       job.Error,
+		"finished_at": job.FinishedAt,
+	}).Error
+}
+
+func (jr *musicJobRepository) TouchJob(ctx context.Context, job *model.MusicJob) error {
+	return jr.db.WithContext(ctx).Model(&model.MusicJob{}).
+		Where("id = ? AND status = ?", job.ID, model.MusicJobStatusRunning).
+		Update("updated_at", time.Now()).Error
+}
+
+// RequeueJob は中断したジョブを queued に戻す。中断した試行は回数に数えない
+func (jr *musicJobRepository) RequeueJob(ctx context.Context, job *model.MusicJob) error {
+	job.Status = model.MusicJobStatusQueued
+	job.Attempts--
+	job.StartedAt = nil
+	return jr.db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+		"status":     job.Status,
+		"attempts":   job.Attempts,
+		"started_at": job.StartedAt,
+	}).Error
+}
+
+func (jr *musicJobRepository) GetLatestJobByDiary(ctx context.Context, job *model.MusicJob, userId uint, diaryId uint) error {
+	if err := jr.db.WithContext(ctx).Where("user_id = ? AND diary_id = ?", userId, diaryId).
+		Order("id DESC").
+		First(job).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (jr *musicJobRepository) GetJobsByDiary(ctx context.Context, userId uint, diaryId uint) ([]model.MusicJob, error) {
+	var jobs []model.MusicJob
+	if err := jr.db.WithContext(ctx).Where("user_id = ? AND diary_id = ?", userId, diaryId).
+		Order("id DESC").
+		Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// CountWaitingJobs はまだプロバイダーを呼び出していない（queued / pending の）ジョブの数を返す
+func (jr *musicJobRepository) CountWaitingJobs(ctx context.Context, userId uint) (int64, error) {
+	var count int64
+	if err := jr.db.WithContext(ctx).Model(&model.MusicJob{}).
+		Where("user_id = ? AND status IN ?", userId, []string{model.MusicJobStatusQueued, model.MusicJobStatusPending}).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountActiveJobs は終了していない（queued / pending / running の）ジョブの数を状態ごとに返す
+func (jr *musicJobRepository) CountActiveJobs(ctx context.Context) (map[string]int64, error) {
+	return countByStatus(jr.db.WithContext(ctx).Model(&model.MusicJob{}),
+		model.MusicJobStatusQueued, model.MusicJobStatusPending, model.MusicJobStatusRunning)
+}
+
+// countByStatus は statuses のそれぞれに該当する行数を返す（0件の状態も含める）
+func countByStatus(query *gorm.DB, statuses ...string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := query.Select("status, COUNT(*) AS count").
+		Where("status IN ?", statuses).
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(statuses))
+	for _, status := range statuses {
+		counts[status] = 0
+	}
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
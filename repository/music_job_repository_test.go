@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+func TestClaimNextJob(t *testing.T) {
+	db, fake := newFakeDB(t, fakeRows{
+		Columns: []string{"id", "user_id", "diary_id", "status", "attempts"},
+		Values:  [][]driver.Value{{int64(7), int64(1), int64(3), model.MusicJobStatusPending, int64(1)}},
+	})
+	staleAfter := 10 * time.Minute
+	before := time.Now()
+
+	job, err := NewMusicJobRepository(db).ClaimNextJob(context.Background(), staleAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil || job.ID != 7 || job.Status != model.MusicJobStatusRunning || job.Attempts != 2 || job.StartedAt == nil {
+		t.Fatalf("job = %+v, want job 7 running on its second attempt", job)
+	}
+
+	// queued, run_after を過ぎた pending, staleAfter より古い running を SKIP LOCKED で取る
+	claim := fake.find(t, `FROM "music_jobs"`)
+	if !strings.Contains(claim.Query, "FOR UPDATE SKIP LOCKED") {
+		t.Errorf("claim query does not skip locked rows: %s", claim.Query)
+	}
+	if len(claim.Args) < 5 ||
+		claim.Args[0] != model.MusicJobStatusQueued ||
+		claim.Args[1] != model.MusicJobStatusPending ||
+		claim.Args[3] != model.MusicJobStatusRunning {
+		t.Fatalf("claim args = %v", claim.Args)
+	}
+	runAfter, _ := claim.Args[2].(time.Time)
+	staleBefore, _ := claim.Args[4].(time.Time)
+	if runAfter.Before(before) || !staleBefore.Equal(runAfter.Add(-staleAfter)) {
+		t.Errorf("run_after <= %v, updated_at < %v, want now and now-%v", runAfter, staleBefore, staleAfter)
+	}
+
+	update := fake.find(t, `UPDATE "music_jobs"`)
+	if !containsValues(update.Args, model.MusicJobStatusRunning, int64(2)) {
+		t.Errorf("update args = %v, want status running and attempts 2", update.Args)
+	}
+	if fake.commits != 1 || fake.rollbacks != 0 {
+		t.Errorf("commits = %d, rollbacks = %d, want one commit", fake.commits, fake.rollbacks)
+	}
+}
+
+func TestClaimNextJobEmpty(t *testing.T) {
+	db, fake := newFakeDB(t)
+
+	job, err := NewMusicJobRepository(db).ClaimNextJob(context.Background(), time.Minute)
+	if job != nil || err != nil {
+		t.Fatalf("ClaimNextJob() = %+v, %v, want nil, nil", job, err)
+	}
+	for _, s := range fake.statements {
+		if strings.HasPrefix(s.Query, "UPDATE") {
+			t.Errorf("unexpected update: %s", s.Query)
+		}
+	}
+	if fake.commits != 0 || fake.rollbacks != 1 {
+		t.Errorf("commits = %d, rollbacks = %d, want one rollback", fake.commits, fake.rollbacks)
+	}
+}
+
+// containsValues は args に want がすべて含まれるかを返す
+func containsValues(args []driver.Value, want ...driver.Value) bool {
+	for _, w := range want {
+		found := false
+		for _, a := range args {
+			if a == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompleteJob(t *testing.T) {
+	db, fake := newFakeDB(t)
+	job := &model.MusicJob{ID: 7, UserID: 1, DiaryID: 3, Status: model.MusicJobStatusRunning}
+
+	err := NewMusicJobRepository(db).CompleteJob(context.Background(), job, []model.Music{{Title: "song"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != model.MusicJobStatusSucceeded || job.FinishedAt == nil {
+		t.Fatalf("job = %+v, want succeeded", job)
+	}
+	update := fake.find(t, `UPDATE "music_jobs"`)
+	if !strings.Contains(update.Query, "status = $") || !containsValues(update.Args, int64(7), model.MusicJobStatusRunning, model.MusicJobStatusSucceeded) {
+		t.Errorf("update = %s %v, want a conditional update of running job 7", update.Query, update.Args)
+	}
+	fake.find(t, `INSERT INTO "musics"`)
+	if fake.commits != 1 {
+		t.Errorf("commits = %d, want 1", fake.commits)
+	}
+}
+
+func TestCompleteJobNotRunning(t *testing.T) {
+	db, fake := newFakeDB(t)
+	// 生成中に日記と一緒にジョブが削除された
+	fake.affected = []int64{0}
+	job := &model.MusicJob{ID: 7, UserID: 1, DiaryID: 3, Status: model.MusicJobStatusRunning}
+
+	err := NewMusicJobRepository(db).CompleteJob(context.Background(), job, []model.Music{{Title: "song"}})
+	if !errors.Is(err, ErrJobNotRunning) {
+		t.Fatalf("err = %v, want ErrJobNotRunning", err)
+	}
+	for _, s := range fake.statements {
+		if strings.Contains(s.Query, `"musics"`) {
+			t.Errorf("unexpected query on musics: %s", s.Query)
+		}
+	}
+	if job.Status != model.MusicJobStatusRunning || fake.rollbacks != 1 {
+		t.Errorf("job status = %s, rollbacks = %d, want the job untouched and rolled back", job.Status, fake.rollbacks)
+	}
+}
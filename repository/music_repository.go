@@ -1,149 +1,72 @@
 package repository
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
-	"os"
+	"time"
 
 	"github.com/kenta-kenta/diary-music/model"
 	"gorm.io/gorm"
 )
 
 type IMusicRepository interface {
-	CreateMusic(req *model.MusicRequest, diaryID uint) (*model.MusicResponse, error)
-	SaveMusic(music *model.Music) error
-	CreateMusicWithDiary(diary *model.Diary, musicReq *model.MusicRequest) (*model.MusicResponse, error)
-	GetMusicsList(page int, limit int, userId uint) ([]model.Music, error)
+	SetPrimaryMusic(ctx context.Context, userId uint, diaryId uint, musicId uint) error
+	GetMusicsList(ctx context.Context, page int, limit int, userId uint) ([]model.Music, error)
+	GetMusicsByDiary(ctx context.Context, userId uint, diaryId uint) ([]model.Music, error)
+	GetMusicById(ctx context.Context, music *model.Music, userId uint, musicId uint) error
 }
 
 type musicRepository struct {
-	baseURL    string
-	httpClient *http.Client
-	db         *gorm.DB
+	db *gorm.DB
 }
 
 func NewMusicRepository(db *gorm.DB) IMusicRepository {
-	return &musicRepository{
-		baseURL:    "https://api.topmediai.com/v1",
-		httpClient: &http.Client{},
-		db:         db,
-	}
+	return &musicRepository{db}
 }
 
-func (mr *musicRepository) CreateMusic(req *model.MusicRequest, diaryID uint) (*model.MusicResponse, error) {
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
-
-	httpReq, err := http.NewRequest("POST", mr.baseURL+"/music", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", os.Getenv("API_KEY"))
-
-	response, err := mr.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, err
-	}
-	defer response.Body.Close()
-
-	var result model.MusicResponse
-	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
-		return nil, err
-	}
-
-	music := model.Music{
-		DiaryID:      diaryID,
-		Title:        result.Data[0].Title,
-		AudioFile:    result.Data[0].AudioFile,
-		ImageFile:    result.Data[0].ImageFile,
-		ItemUUID:     result.Data[0].ItemUUID,
-		Lyrics:       result.Data[0].Lyric,
-		IsAuto:       req.IsAuto,
-		Prompt:       req.Prompt,
-		Instrumental: req.Instrumental,
+// createMusics は同じ日記の曲をまとめて保存する。
+// 日記にまだ代表曲がない場合は最初の曲を代表曲にする。
+func createMusics(tx *gorm.DB, musics []model.Music) error {
+	if len(musics) == 0 {
+		return nil
 	}
-
-	if err := mr.SaveMusic(&music); err != nil {
-		return nil, fmt.Errorf("failed to save music: %w", err)
+	var primaries int64
+	if err := tx.Model(&model.Music{}).
+		Where("diary_id = ? AND is_primary = ?", musics[0].DiaryID, true).
+		Count(&primaries).Error; err != nil {
+		return err
 	}
+	musics[0].IsPrimary = primaries == 0
 
-	return &result, nil
-}
-
-func (mr *musicRepository) SaveMusic(music *model.Music) error {
-	if err := mr.db.Create(music).Error; err != nil {
+	if err := tx.Create(&musics).Error; err != nil {
 		return fmt.Errorf("failed to create music record: %w", err)
 	}
 	return nil
 }
 
-func (mr *musicRepository) CreateMusicWithDiary(diary *model.Diary, musicReq *model.MusicRequest) (*model.MusicResponse, error) {
-	var result model.MusicResponse
-
-	err := mr.db.Transaction(func(tx *gorm.DB) error {
-		// 1. まずDiaryを保存
-		if err := tx.Create(diary).Error; err != nil {
-			return err
-		}
-
-		// 2. API呼び出し
-		jsonData, err := json.Marshal(musicReq)
-		if err != nil {
-			return err
-		}
-
-		httpReq, err := http.NewRequest("POST", mr.baseURL+"/music", bytes.NewBuffer(jsonData))
-		if err != nil {
+// SetPrimaryMusic は日記の代表曲を切り替える
+func (mr *musicRepository) SetPrimaryMusic(ctx context.Context, userId uint, diaryId uint, musicId uint) error {
+	return mr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 対象の曲がユーザーの日記に属していることを確認
+		var music model.Music
+		if err := tx.Where("id = ? AND user_id = ? AND diary_id = ?", musicId, userId, diaryId).
+			First(&music).Error; err != nil {
 			return err
 		}
-		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("x-api-key", os.Getenv("API_KEY"))
 
-		response, err := mr.httpClient.Do(httpReq)
-		if err != nil {
+		now := time.Now()
+		if err := tx.Model(&model.Music{}).
+			Where("diary_id = ? AND id <> ? AND is_primary = ?", diaryId, musicId, true).
+			Updates(map[string]interface{}{"is_primary": false, "updated_at": now}).Error; err != nil {
 			return err
 		}
-		defer response.Body.Close()
-
-		if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
-			return err
-		}
-
-		// 3. Music情報をDBに保存
-		music := model.Music{
-			DiaryID:      diary.ID, // 保存されたDiaryのIDを使用
-			Title:        result.Data[0].Title,
-			AudioFile:    result.Data[0].AudioFile,
-			ImageFile:    result.Data[0].ImageFile,
-			ItemUUID:     result.Data[0].ItemUUID,
-			Lyrics:       result.Data[0].Lyric,
-			IsAuto:       musicReq.IsAuto,
-			Prompt:       musicReq.Prompt,
-			Instrumental: musicReq.Instrumental,
-		}
-
-		if err := tx.Create(&music).Error; err != nil {
-			return err
-		}
-
-		return nil
+		return tx.Model(&music).Updates(map[string]interface{}{"is_primary": true, "updated_at": now}).Error
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &result, nil
 }
 
-func (mr *musicRepository) GetMusicsList(page int, limit int, userId uint) ([]model.Music, error) {
+func (mr *musicRepository) GetMusicsList(ctx context.Context, page int, limit int, userId uint) ([]model.Music, error) {
 	var musics []model.Music
-	if err := mr.db.Where("user_id = ?", userId).
+	if err := mr.db.WithContext(ctx).Where("user_id = ?", userId).
 		Order("created_at DESC").
 		Offset((page - 1) * limit).
 		Limit(limit).
@@ -152,3 +75,22 @@ func (mr *musicRepository) GetMusicsList(page int, limit int, userId uint) ([]mo
 	}
 	return musics, nil
 }
+
+func (mr *musicRepository) GetMusicsByDiary(ctx context.Context, userId uint, diaryId uint) ([]model.Music, error) {
+	var musics []model.Music
+	if err := mr.db.WithContext(ctx).Where("user_id = ? AND diary_id = ?", userId, diaryId).
+		Order("is_primary DESC").
+		Order("id").
+		Find(&musics).Error; err != nil {
+		return nil, err
+	}
+	return musics, nil
+}
+
+// GetMusicById はユーザー自身の曲のみ取得する（他のユーザーの曲は見つからない扱い）
+func (mr *musicRepository) GetMusicById(ctx context.Context, music *model.Music, userId uint, musicId uint) error {
+	if err := mr.db.WithContext(ctx).Where("user_id = ? AND id = ?", userId, musicId).First(music).Error; err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrRefreshTokenReused は既に次のトークンに交換したリフレッシュトークンが使われたことを表す
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
+
+type ISessionRepository interface {
+	CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error
+	GetSessions(ctx context.Context, userId uint, now time.Time) ([]model.Session, error)
+	GetSessionById(ctx context.Context, session *model.Session, sessionId uint) error
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken, session *model.Session, now time.Time) error
+	RevokeSession(ctx context.Context, userId uint, sessionId uint, reason string, now time.Time) error
+	RevokeSessionByRefreshToken(ctx context.Context, tokenHash string, reason string, now time.Time) error
+	RevokeUserSessions(ctx context.Context, userId uint, exceptSessionId uint, reason string, now time.Time) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) ISessionRepository {
+	return &sessionRepository{db}
+}
+
+// CreateSession はセッションと最初のリフレッシュトークンを保存する
+func (sr *sessionRepository) CreateSession(ctx context.Context, session *model.Session, token *model.RefreshToken) error {
+	return sr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		token.SessionID = session.ID
+		return tx.Create(token).Error
+	})
+}
+
+// GetSessions は有効なセッションを最後に使った順に返す
+func (sr *sessionRepository) GetSessions(ctx context.Context, userId uint, now time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := sr.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (sr *sessionRepository) GetSessionById(ctx context.Context, session *model.Session, sessionId uint) error {
+	if err := sr.db.WithContext(ctx).First(session, sessionId).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken はリフレッシュトークンを使用済みにして次のトークンを保存し、セッションの期限を延長する。
+// 同時に同じトークンで更新された場合は後の方が使用済みのトークンを見ることになる。
+// 使用済みのトークンだった場合は session にトークンのセッションを入れて ErrRefreshTokenReused を返す
+func (sr *sessionRepository) RotateRefreshToken(ctx context.Context, tokenHash string, next *model.RefreshToken, session *model.Session, now time.Time) error {
+	return sr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token model.RefreshToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", tokenHash).
+			First(&token).Error; err != nil {
+			return err
+		}
+		if err := tx.First(session, token.SessionID).Error; err != nil {
+			return err
+		}
+		if token.UsedAt != nil {
+			return ErrRefreshTokenReused
+		}
+		if !session.Active(now) || !now.Before(token.ExpiresAt) {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+			return err
+		}
+		next.SessionID = session.ID
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		session.LastUsedAt = now
+		session.ExpiresAt = next.ExpiresAt
+		return tx.Model(session).Updates(map[string]interface{}{
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+		}).Error
+	})
+}
+
+// RevokeSession はユーザーのセッションを無効にする（既に無効な場合は gorm.ErrRecordNotFound）
+func (sr *sessionRepository) RevokeSession(ctx context.Context, userId uint, sessionId uint, reason string, now time.Time) error {
+	result := sr.db.WithContext(ctx).Model(&model.Session{}).
+		Where("user_id = ? AND id = ? AND revoked_at IS NULL", userId, sessionId).
+		Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeSessionByRefreshToken はリフレッシュトークンを発行したセッションを無効にする
+func (sr *sessionRepository) RevokeSessionByRefreshToken(ctx context.Context, tokenHash string, reason string, now time.Time) error {
+	result := sr.db.WithContext(ctx).Model(&model.Session{}).
+		Where("id = (?) AND revoked_at IS NULL",
+			sr.db.WithContext(ctx).Model(&model.RefreshToken{}).Select("session_id").Where("token_hash = ?", tokenHash)).
+		Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"revoked_reason": reason,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions はユーザーの有効なセッションを exceptSessionId 以外すべて無効にする（0 の場合はすべて）
+func (sr *sessionRepository) RevokeUserSessions(ctx context.Context, userId uint, exceptSessionId uint, reason string, now time.Time) error {
+	return sr.db.WithContext(ctx).Model(&model.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userId, exceptSessionId).
+		Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"revoked_reason": reason,
+		}).Error
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+)
+
+// 呼び出し中の記録をこの時間を過ぎても使用量に含める（プロセス停止で取り残された記録を除外する）
+const usageReservationTTL = 15 * time.Minute
+
+// 同じユーザーの枠の確保を直列化するアドバイザリロックのキー
+const usageLockKey = 7201
+
+type IUsageRepository interface {
+	// SumCostUnits は since 以降に消費したコストの合計を返す（呼び出し中のものを含む）
+	SumCostUnits(ctx context.Context, userId uint, since time.Time) (int, error)
+	// ReserveUsage は check が nil を返した場合のみ record を reserved として記録する。
+	// check がエラーを返した場合は rejected として記録し、そのエラーを返す。
+	// check には daySince, monthSince 以降の使用量が渡される。
+	ReserveUsage(ctx context.Context, record *model.UsageRecord, daySince time.Time, monthSince time.Time, check func(daily int, monthly int) error) error
+	UpdateUsage(ctx context.Context, record *model.UsageRecord) error
+}
+
+type usageRepository struct {
+	db *gorm.DB
+}
+
+func NewUsageRepository(db *gorm.DB) IUsageRepository {
+	return &usageRepository{db}
+}
+
+func (ur *usageRepository) SumCostUnits(ctx context.Context, userId uint, since time.Time) (int, error) {
+	return sumCostUnits(ur.db.WithContext(ctx), userId, since)
+}
+
+func (ur *usageRepository) ReserveUsage(ctx context.Context, record *model.UsageRecord, daySince time.Time, monthSince time.Time, check func(daily int, monthly int) error) error {
+	var rejected error
+	err := ur.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 複数のワーカーが同時に枠を確保して上限を超えないようにする
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", usageLockKey, int32(record.UserID)).Error; err != nil {
+			return err
+		}
+		daily, err := sumCostUnits(tx, record.UserID, daySince)
+		if err != nil {
+			return err
+		}
+		monthly, err := sumCostUnits(tx, record.UserID, monthSince)
+		if err != nil {
+			return err
+		}
+
+		record.Outcome = model.UsageOutcomeReserved
+		if rejected = check(daily, monthly); rejected != nil {
+			record.Outcome = model.UsageOutcomeRejected
+			record.CostUnits = 0
+			record.Error = rejected.Error()
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return err
+	}
+	return rejected
+}
+
+func (ur *usageRepository) UpdateUsage(ctx context.Context, record *model.UsageRecord) error {
+	return ur.db.WithContext(ctx).Model(record).Updates(map[string]interface{}{
+		"outcome":    record.Outcome,
+		"cost_units": record.CostUnits,
+		"error":      record.Error,
+	}).Error
+}
+
+func sumCostUnits(db *gorm.DB, userId uint, since time.Time) (int, error) {
+	var total int
+	err := db.Model(&model.UsageRecord{}).
+		Select("COALESCE(SUM(cost_units), 0)").
+		Where("user_id = ? AND created_at >= ?", userId, since).
+		Where("outcome = ? OR (outcome = ? AND created_at >= ?)",
+			model.UsageOutcomeSucceeded,
+			model.UsageOutcomeReserved, time.Now().Add(-usageReservationTTL)).
+		Scan(&total).Error
+	return total, err
+}
@@ -1,14 +1,19 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/kenta-kenta/diary-music/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IUserRepository interface {
-	GetUserByEmail(user *model.User, email string) error
-	CreateUser(user *model.User) error
-	GetUserById(user *model.User, userId uint) error
+	GetUserByEmail(ctx context.Context, user *model.User, email string) error
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUserById(ctx context.Context, user *model.User, userId uint) error
+	UpdateUserName(ctx context.Context, user *model.User, userId uint) error
+	UpdatePassword(ctx context.Context, userId uint, passwordHash string) error
 }
 type UserRepository struct {
 	db *gorm.DB
@@ -20,24 +25,47 @@ func NewUserRepository(db *gorm.DB) IUserRepository {
 }
 
 // ユーザー情報の取得
-func (ur *UserRepository) GetUserByEmail(user *model.User, email string) error {
-	if err := ur.db.Where("email = ?", email).First(user).Error; err != nil {
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, user *model.User, email string) error {
+	if err := ur.db.WithContext(ctx).Where("email = ?", email).First(user).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
 // ユーザー情報の作成
-func (ur *UserRepository) CreateUser(user *model.User) error {
-	if err := ur.db.Create(user).Error; err != nil {
+func (ur *UserRepository) CreateUser(ctx context.Context, user *model.User) error {
+	if err := ur.db.WithContext(ctx).Create(user).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (ur *UserRepository) GetUserById(user *model.User, userId uint) error {
-	if err := ur.db.First(user, userId).Error; err != nil {
+func (ur *UserRepository) GetUserById(ctx context.Context, user *model.User, userId uint) error {
+	if err := ur.db.WithContext(ctx).First(user, userId).Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+// UpdateUserName はユーザー名を変更し、user に更新後のユーザー情報を入れる
+func (ur *UserRepository) UpdateUserName(ctx context.Context, user *model.User, userId uint) error {
+	result := ur.db.WithContext(ctx).Model(user).Clauses(clause.Returning{}).Where("id = ?", userId).Update("user_name", user.UserName)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (ur *UserRepository) UpdatePassword(ctx context.Context, userId uint, passwordHash string) error {
+	result := ur.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", userId).Update("password", passwordHash)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected < 1 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IWebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	GetWebhooks(ctx context.Context, userId uint) ([]model.Webhook, error)
+	GetWebhookById(ctx context.Context, webhook *model.Webhook, userId uint, webhookId uint) error
+	GetActiveWebhooks(ctx context.Context, userId uint) ([]model.Webhook, error)
+	DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error
+	CreateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, userId uint, webhookId uint, limit int) ([]model.WebhookDelivery, error)
+	GetDeliveryById(ctx context.Context, delivery *model.WebhookDelivery, userId uint, webhookId uint, deliveryId uint) error
+	ClaimNextDelivery(ctx context.Context, staleAfter time.Duration) (*model.WebhookDelivery, *model.Webhook, error)
+	UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	CountActiveDeliveries(ctx context.Context) (map[string]int64, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) IWebhookRepository {
+	return &webhookRepository{db}
+}
+
+func (wr *webhookRepository) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return wr.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (wr *webhookRepository) GetWebhooks(ctx context.Context, userId uint) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	if err := wr.db.WithContext(ctx).Where("user_id = ?", userId).Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (wr *webhookRepository) GetWebhookById(ctx context.Context, webhook *model.Webhook, userId uint, webhookId uint) error {
+	if err := wr.db.WithContext(ctx).Where("user_id = ? AND id = ?", userId, webhookId).First(webhook).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (wr *webhookRepository) GetActiveWebhooks(ctx context.Context, userId uint) ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	if err := wr.db.WithContext(ctx).Where("user_id = ? AND active = ?", userId, true).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook はWebhookと配信履歴を削除する
+func (wr *webhookRepository) DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error {
+	return wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND id = ?", userId, webhookId).Delete(&model.Webhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected < 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("webhook_id = ?", webhookId).Delete(&model.WebhookDelivery{}).Error
+	})
+}
+
+func (wr *webhookRepository) CreateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return wr.db.WithContext(ctx).Create(delivery).Error
+}
+
+func (wr *webhookRepository) GetDeliveries(ctx context.Context, userId uint, webhookId uint, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	if err := wr.db.WithContext(ctx).Where("user_id = ? AND webhook_id = ?", userId, webhookId).
+		Order("id DESC").
+		Limit(limit).
+		Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (wr *webhookRepository) GetDeliveryById(ctx context.Context, delivery *model.WebhookDelivery, userId uint, webhookId uint, deliveryId uint) error {
+	if err := wr.db.WithContext(ctx).Where("user_id = ? AND webhook_id = ? AND id = ?", userId, webhookId, deliveryId).
+		First(delivery).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ClaimNextDelivery は送信時刻を過ぎた配信を1件取り出し delivering に遷移させる。
+// staleAfter より長く delivering のままの配信も再取得の対象とする。
+// 取得できる配信がない場合は nil, nil, nil を返す。
+func (wr *webhookRepository) ClaimNextDelivery(ctx context.Context, staleAfter time.Duration) (*model.WebhookDelivery, *model.Webhook, error) {
+	var delivery model.WebhookDelivery
+	var webhook model.Webhook
+	err := wr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND next_attempt_at <= ?) OR (status = ? AND updated_at < ?)",
+				model.WebhookDeliveryPending, now,
+				model.WebhookDeliveryDelivering, now.Add(-staleAfter)).
+			Order("next_attempt_at").
+			First(&delivery).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&webhook, delivery.WebhookID).Error; err != nil {
+			return err
+		}
+
+		delivery.Status = model.WebhookDeliveryDelivering
+		delivery.Attempts++
+		return tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":   delivery.Status,
+			"attempts": delivery.Attempts,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &delivery, &webhook, nil
+}
+
+func (wr *webhookRepository) UpdateDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return wr.db.WithContext(ctx).Model(delivery).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"response_status": delivery.ResponseStatus,
+		"error":           delivery.Error,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"delivered_at":    delivery.DeliveredAt,
+	}).Error
+}
+
+// CountActiveDeliveries は送信待ち・送信中の配信の数を状態ごとに返す
+func (wr *webhookRepository) CountActiveDeliveries(ctx context.Context) (map[string]int64, error) {
+	return countByStatus(wr.db.WithContext(ctx).Model(&model.WebhookDelivery{}),
+		model.WebhookDeliveryPending, model.WebhookDeliveryDelivering)
+}
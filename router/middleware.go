@@ -0,0 +1,171 @@
+package router
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/logging"
+	"github.com/kenta-kenta/diary-music/metrics"
+	"github.com/kenta-kenta/diary-music/tracing"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestID はリクエストごとにIDを発行して X-Request-ID ヘッダーで返し、ログ用に context に入れる。
+// クライアントが X-Request-ID を付けて送ってきた場合はその値を使う
+func requestID() echo.MiddlewareFunc {
+	return middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		RequestIDHandler: func(c echo.Context, id string) {
+			req := c.Request()
+			c.SetRequest(req.WithContext(logging.WithRequestID(req.Context(), id)))
+		},
+	})
+}
+
+// requestTracing はリクエストごとにサーバーのスパンを記録する。
+// 呼び出し元から traceparent ヘッダーを受け取った場合はそのトレースの続きにする
+func requestTracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := tracing.Tracer().Start(ctx, req.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(req.Method),
+					semconv.HTTPRoute(route),
+					semconv.URLPath(req.URL.Path),
+					attribute.String("http.request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+				),
+			)
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil && !c.Response().Committed {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if err != nil {
+				span.RecordError(err)
+			}
+			if status >= 500 {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+			return err
+		}
+	}
+}
+
+// userID は JWT の user_id をログ用に context に入れる（JWT の検証より後に使う）
+func userID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if user, ok := c.Get("user").(*jwt.Token); ok {
+			if claims, ok := user.Claims.(jwt.MapClaims); ok {
+				if id, ok := claims["user_id"].(float64); ok {
+					req := c.Request()
+					c.SetRequest(req.WithContext(logging.WithUserID(req.Context(), uint(id))))
+					trace.SpanFromContext(req.Context()).SetAttributes(semconv.EnduserID(strconv.FormatUint(uint64(id), 10)))
+				}
+			}
+		}
+		return next(c)
+	}
+}
+
+// requestLogger はリクエストごとにメソッド・パス・ステータス・所要時間を出力する。
+// クエリ文字列やボディは出力しない
+func requestLogger() echo.MiddlewareFunc {
+	return middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
+		LogMethod:    true,
+		LogURIPath:   true,
+		LogRoutePath: true,
+		LogStatus:    true,
+		LogLatency:   true,
+		LogRemoteIP:  true,
+		LogError:     true,
+		HandleError:  true,
+		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
+			level := slog.LevelInfo
+			switch {
+			case v.Status >= 500:
+				level = slog.LevelError
+			case v.Status >= 400:
+				level = slog.LevelWarn
+			}
+			attrs := []slog.Attr{
+				slog.String("method", v.Method),
+				slog.String("path", v.URIPath),
+				slog.String("route", v.RoutePath),
+				slog.Int("status", v.Status),
+				slog.Int64("latency_ms", v.Latency.Milliseconds()),
+				slog.String("remote_ip", v.RemoteIP),
+			}
+			if v.Error != nil {
+				attrs = append(attrs, slog.String("error", v.Error.Error()))
+			}
+			// user_id は JWT の検証後に context に入るため、ハンドラーが受け取ったリクエストから取る
+			slog.LogAttrs(c.Request().Context(), level, "request", attrs...)
+			return nil
+		},
+	})
+}
+
+// requestMetrics はルートごとのリクエスト数と処理時間を記録する。
+// ルートはパスではなく登録したパターン（/diaries/:diaryId など）を使う
+func requestMetrics(activeUsers *metrics.ActiveUsers) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			begin := time.Now()
+			err := next(c)
+			if err != nil && !c.Response().Committed {
+				// ステータスコードを確定させるためここでエラーを処理する
+				c.Error(err)
+			}
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			method := c.Request().Method
+			metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().Status)).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(begin).Seconds())
+			if userId, ok := logging.UserID(c.Request().Context()); ok {
+				activeUsers.Seen(userId)
+			}
+			return err
+		}
+	}
+}
+
+// metricsHandler は Prometheus のメトリクスを返す。METRICS_TOKEN を設定した場合は Bearer トークンを要求する
+func metricsHandler(cfg config.MetricsConfig) echo.HandlerFunc {
+	handler := echo.WrapHandler(promhttp.Handler())
+	return func(c echo.Context) error {
+		if cfg.Token != "" {
+			expected := "Bearer " + cfg.Token
+			got := c.Request().Header.Get(echo.HeaderAuthorization)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(expected)) != 1 {
+				return apperror.Unauthorized("unauthorized", "Invalid metrics token")
+			}
+		}
+		return handler(c)
+	}
+}
@@ -2,43 +2,92 @@ package router
 
 import (
 	"net/http"
-	"os"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/config"
 	"github.com/kenta-kenta/diary-music/controller"
+	"github.com/kenta-kenta/diary-music/metrics"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
-func NewRouter(uc controller.IUserController, dc controller.IDiaryController, mc controller.IMusicController) *echo.Echo {
+// sc はスタブプロバイダー使用時のみ渡す（nil の場合はスタブ用のルートを登録しない）
+func NewRouter(cfg *config.Config, uc controller.IUserController, dc controller.IDiaryController, mc controller.IMusicController, ac controller.IAssetController, ec controller.IEventController, sc controller.IStubController, ic controller.IInternalController, wc controller.IWebhookController, hc controller.IHealthController, sec controller.ISessionController, acc controller.IAccountController, xc controller.IExportController, activeUsers *metrics.ActiveUsers) *echo.Echo {
 	e := echo.New()
+	e.HideBanner = true // 起動時のログは main で出力する
+	e.HidePort = true
+	// エラーは RFC 7807 の problem+json で返す
+	e.HTTPErrorHandler = controller.HTTPErrorHandler
+	// リクエストIDの発行，トレース，メトリクスとリクエストログ
+	e.Use(requestID())
+	e.Use(requestTracing())
+	e.Use(requestMetrics(activeUsers))
+	e.Use(requestLogger())
 	// CORS
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins:     []string{"http://localhost:3000", os.Getenv("FE_URL")},                                                                              // 許可するオリジン
+		AllowOrigins:     []string{"http://localhost:3000", cfg.HTTP.FrontendURL},                                                                             // 許可するオリジン
 		AllowHeaders:     []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAccessControlAllowHeaders, echo.HeaderXCSRFToken}, // 許可するヘッダー
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},                                                                                            // 許可するメソッド
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},                                                                                   // 許可するメソッド
+		ExposeHeaders:    []string{echo.HeaderXRequestID},                                                                                                     // JavaScriptから読めるヘッダー
 		AllowCredentials: true,                                                                                                                                // クレデンシャル情報（Cookieなど）の送信を許可
 	}))
 	// CSRF
 	e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
-		CookiePath:     "/",                     // Cookieのパス
-		CookieDomain:   os.Getenv("API_DOMAIN"), // Cookieのドメイン
+		CookiePath:     "/",                // Cookieのパス
+		CookieDomain:   cfg.HTTP.APIDomain, // Cookieのドメイン
 		CookieHTTPOnly: true,
 		CookieSecure:   true,
 		CookieSameSite: http.SameSiteNoneMode,
 	}))
 
+	e.GET("/healthz", hc.Healthz)                  // プロセスの死活監視
+	e.GET("/readyz", hc.Readyz)                    // DB・マイグレーション・プロバイダーの状態（受け付けられない場合は503）
+	e.GET("/metrics", metricsHandler(cfg.Metrics)) // Prometheus
+
 	e.POST("/signup", uc.SignUp)
 	e.POST("/login", uc.Login)
 	e.POST("/logout", uc.Logout)
+	e.POST("/refresh", sec.Refresh) // リフレッシュトークンの Cookie でアクセストークンを更新する
 	e.GET("/csrf", uc.CsrfToken)
+	e.GET("/assets/*", ac.GetAsset) // 署名付きURLで認証する
+
+	// パスワードの再設定とメールアドレスの確認（トークンはメールで送る）
+	e.POST("/password/forgot", acc.ForgotPassword)
+	e.POST("/password/reset", acc.ResetPassword)
+	e.POST("/email/verify", acc.VerifyEmail)
+
+	if sc != nil {
+		stub := e.Group("/stub/musics")
+		stub.GET("/:seed/audio.wav", sc.GetAudio)
+		stub.GET("/:seed/cover.png", sc.GetCover)
+	}
 
 	auth := e.Group("")
 	auth.Use(echojwt.WithConfig(echojwt.Config{
-		SigningKey:  []byte(os.Getenv("SECRET")),
+		SigningKey:  []byte(cfg.Auth.Secret),
 		TokenLookup: "cookie:token",
 	}))
+	auth.Use(userID)             // ログに user_id を付ける
+	auth.Use(sec.RequireSession) // ログアウト・無効にしたセッションのトークンを拒否する
 	auth.GET("/user", uc.GetUser)
+	auth.PATCH("/user", uc.UpdateProfile)
+	auth.PUT("/user/email", uc.ChangeEmail)         // 確認のメールを開くまで変更しない
+	auth.PUT("/user/password", uc.ChangePassword)   // 現在のパスワードが必要
+	auth.GET("/user/usage", uc.GetUsage)            // 音楽生成の使用量と上限
+	auth.GET("/sessions", sec.GetSessions)          // ログイン中の端末
+	auth.DELETE("/sessions/:id", sec.DeleteSession) // セッションを無効にする
+	auth.GET("/events", ec.Stream)                  // Server-Sent Events (music.queued, music.ready など)
+	// 音楽生成プロバイダーのブレーカーの状態（エラーの内容を含むので管理者のみ）
+	auth.GET("/internal/status", ic.GetStatus, adminOnly(cfg.Admin))
+	auth.GET("/debug/status", hc.GetDebugStatus, adminOnly(cfg.Admin))
+	auth.POST("/email/verify/resend", acc.ResendVerification) // 確認メールの再送
+	auth.DELETE("/user", acc.DeleteAccount)                   // 猶予期間の後に削除する（パスワードが必要）
+	auth.DELETE("/user/deletion", acc.CancelDeletion)         // 削除の取り消し
+	auth.POST("/user/export", xc.CreateExport)                // 個人データのアーカイブの作成（バックグラウンド）
+	auth.GET("/user/exports", xc.GetExports)                  // 直近のエクスポートとダウンロードのリンク
+	auth.GET("/user/exports/:id", xc.GetExportById)
 
 	diaries := auth.Group("/diaries")
 	diaries.GET("", dc.GetAllDiaries) // クエリパラメータが必要(?page=1&page_size=10)
@@ -47,11 +96,37 @@ func NewRouter(uc controller.IUserController, dc controller.IDiaryController, mc
 	diaries.POST("", dc.CreateDiary)
 	diaries.PUT("/:diaryId", dc.UpdateDiary)
 	diaries.DELETE("/:diaryId", dc.DeleteDiary)
+	diaries.GET("/:diaryId/music/status", mc.GetMusicStatus)
+	diaries.GET("/:diaryId/musics", mc.GetMusicTakes)                    // 生成履歴（テイクごとのパラメータと曲）
+	diaries.POST("/:diaryId/musics", mc.CreateMusic)                     // パラメータを指定して再生成
+	diaries.PUT("/:diaryId/musics/:musicId/primary", mc.SetPrimaryMusic) // 代表曲の指定
 
-	musics := auth.Group("/musics")
-	musics.GET("", mc.GetMusicsList) // クエリパラメータが必要(?page=1&limit=10)
+	webhooks := auth.Group("/webhooks")
+	webhooks.GET("", wc.GetWebhooks)
+	webhooks.POST("", wc.CreateWebhook) // シークレットは作成時のみ返す
+	webhooks.DELETE("/:id", wc.DeleteWebhook)
+	webhooks.GET("/:id/deliveries", wc.GetDeliveries)                    // 直近の配信履歴
+	webhooks.POST("/:id/deliveries/:deliveryId/redeliver", wc.Redeliver) // 同じ内容を再送
 
-	// diaries.POST("/:diaryId/musics", mc.CreateMusic)
+	musics := auth.Group("/musics")
+	musics.GET("", mc.GetMusicsList)           // クエリパラメータが必要(?page=1&limit=10)
+	musics.GET("/:id/audio", mc.GetMusicAudio) // Rangeリクエスト対応
+	musics.GET("/:id/cover", mc.GetMusicCover)
 
 	return e
 }
+
+// adminOnly は ADMIN_USER_IDS に含まれるユーザー以外のリクエストを 403 にする
+func adminOnly(cfg config.AdminConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := c.Get("user").(*jwt.Token)
+			claims := user.Claims.(jwt.MapClaims)
+			userId := uint(claims["user_id"].(float64))
+			if !cfg.IsAdmin(userId) {
+				return apperror.Forbidden("forbidden", "Administrator only")
+			}
+			return next(c)
+		}
+	}
+}
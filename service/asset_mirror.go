@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/storage"
+)
+
+const (
+	maxAudioSize = 50 << 20
+	maxImageSize = 10 << 20
+)
+
+// IAssetMirror はプロバイダーが配信している音声・カバー画像を自前のストレージに複製する
+type IAssetMirror interface {
+	// MirrorMusic は music の AudioFile / ImageFile をダウンロードして保存し、
+	// 保存先のキーとチェックサムを music に設定する
+	MirrorMusic(ctx context.Context, music *model.Music) error
+	// DeleteMusic は MirrorMusic で保存したファイルを削除する
+	DeleteMusic(ctx context.Context, music *model.Music) error
+}
+
+type assetMirror struct {
+	st         storage.IStorage
+	httpClient *http.Client
+}
+
+func NewAssetMirror(st storage.IStorage) IAssetMirror {
+	return &assetMirror{st, &http.Client{Timeout: 2 * time.Minute, Transport: tracedTransport()}}
+}
+
+func (m *assetMirror) MirrorMusic(ctx context.Context, music *model.Music) error {
+	prefix := fmt.Sprintf("users/%d/diaries/%d", music.UserID, music.DiaryID)
+
+	if music.AudioFile != "" && music.AudioKey == "" {
+		key, sum, size, err := m.mirror(ctx, music.AudioFile, prefix, maxAudioSize)
+		if err != nil {
+			return fmt.Errorf("failed to mirror audio: %w", err)
+		}
+		music.AudioKey, music.AudioSHA256, music.AudioSize = key, sum, size
+	}
+	if music.ImageFile != "" && music.ImageKey == "" {
+		key, sum, size, err := m.mirror(ctx, music.ImageFile, prefix, maxImageSize)
+		if err != nil {
+			return fmt.Errorf("failed to mirror image: %w", err)
+		}
+		music.ImageKey, music.ImageSHA256, music.ImageSize = key, sum, size
+	}
+	return nil
+}
+
+func (m *assetMirror) DeleteMusic(ctx context.Context, music *model.Music) error {
+	var errs []error
+	for _, key := range []string{music.AudioKey, music.ImageKey} {
+		if key == "" {
+			continue
+		}
+		if err := m.st.Delete(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mirror はURLの内容を一時ファイルにダウンロードしてSHA-256を計算し、
+// チェックサムをファイル名にしてストレージに保存する
+func (m *assetMirror) mirror(ctx context.Context, url, prefix string, maxSize int64) (string, string, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", "", 0, err
+	}
+	res, err := m.httpClient.Do(req)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", "", 0, fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+
+	tmp, err := os.CreateTemp("", "asset-*")
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var h hash.Hash = sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(res.Body, maxSize+1))
+	if err != nil {
+		return "", "", 0, err
+	}
+	if size > maxSize {
+		return "", "", 0, fmt.Errorf("asset exceeds %d bytes", maxSize)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", "", 0, err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	contentType := res.Header.Get("Content-Type")
+	key := fmt.Sprintf("%s/%s%s", prefix, sum, assetExt(url, contentType))
+	if err := m.st.Put(ctx, key, tmp, size, contentType); err != nil {
+		return "", "", 0, err
+	}
+	return key, sum, size, nil
+}
+
+// assetExt はURLのパスまたはContent-Typeから拡張子を決める
+func assetExt(url, contentType string) string {
+	p := url
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	if ext := path.Ext(p); ext != "" && len(ext) <= 5 {
+		return strings.ToLower(ext)
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// ErrCircuitOpen はブレーカーが開いているため呼び出しを行わなかったことを表す。
+// ErrProviderUnavailable としても扱える。
+var ErrCircuitOpen = fmt.Errorf("circuit breaker open: %w", ErrProviderUnavailable)
+
+// ブレーカーの状態
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreakerStatus はブレーカーの現在の状態
+type CircuitBreakerStatus struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailureThreshold    int        `json:"failure_threshold"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// CircuitBreaker はプロバイダーの連続した失敗を検知して呼び出しを一時的に止める
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	openTimeout      time.Duration
+	state            string
+	failures         int
+	openedAt         time.Time
+	probing          bool
+	lastError        string
+}
+
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            CircuitClosed,
+	}
+}
+
+// allow は呼び出しを許可するかを判定する。
+// 開いてから openTimeout 経過後は1件だけ試行を許可する（half-open）。
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// 呼び出し元のキャンセル・期限切れでは結果が分からないため、試行前の状態に戻す
+		if b.state == CircuitHalfOpen {
+			b.state = CircuitOpen
+		}
+		return
+	case err == nil || !errors.Is(err, ErrProviderUnavailable):
+		// プロバイダー自体の障害以外（プロンプトの問題など）は成功として扱う
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == CircuitHalfOpen || b.failures >= b.failureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// RetryAt はブレーカーが開いている場合に次に試行できる時刻を返す
+func (b *CircuitBreaker) RetryAt() (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != CircuitOpen {
+		return time.Time{}, false
+	}
+	return b.openedAt.Add(b.openTimeout), true
+}
+
+func (b *CircuitBreaker) Status(provider string) CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := CircuitBreakerStatus{
+		Provider:            provider,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		FailureThreshold:    b.failureThreshold,
+		LastError:           b.lastError,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(b.openTimeout)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+type circuitBreakerProvider struct {
+	next    IMusicProvider
+	breaker *CircuitBreaker
+}
+
+// NewCircuitBreakerProvider はブレーカーを通してプロバイダーを呼び出すプロバイダーを返す
+func NewCircuitBreakerProvider(next IMusicProvider, breaker *CircuitBreaker) IMusicProvider {
+	return &circuitBreakerProvider{next, breaker}
+}
+
+func (p *circuitBreakerProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *circuitBreakerProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	if !p.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+	res, err := p.next.Generate(ctx, req)
+	p.breaker.record(err)
+	return res, err
+}
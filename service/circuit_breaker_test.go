@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// fakeProvider は呼び出し回数を数え、errs の先頭から順にエラーを返す（尽きたら成功）
+type fakeProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	p.calls++
+	if len(p.errs) == 0 {
+		return &model.MusicResponse{}, nil
+	}
+	err := p.errs[0]
+	p.errs = p.errs[1:]
+	return nil, err
+}
+
+var errUnavailable = &ProviderError{Provider: "fake", StatusCode: 503, Kind: ErrProviderUnavailable}
+
+// expire は開いてから openTimeout が経過した状態にする
+func expire(b *CircuitBreaker) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.openedAt = time.Now().Add(-b.openTimeout - time.Millisecond)
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Minute)
+	b.record(errUnavailable)
+	b.record(errUnavailable)
+	if s := b.Status("fake"); s.State != CircuitClosed || s.ConsecutiveFailures != 2 {
+		t.Fatalf("status = %+v, want closed with 2 failures", s)
+	}
+	// プロバイダー自体の障害以外は失敗として数えない
+	b.record(ErrProviderBadPrompt)
+	if s := b.Status("fake"); s.State != CircuitClosed || s.ConsecutiveFailures != 0 {
+		t.Fatalf("status = %+v, want the count reset", s)
+	}
+	for i := 0; i < 3; i++ {
+		if !b.allow() {
+			t.Fatalf("call %d rejected while closed", i+1)
+		}
+		b.record(errUnavailable)
+	}
+	if b.allow() {
+		t.Fatal("open breaker allowed a call")
+	}
+	retryAt, ok := b.RetryAt()
+	if !ok || time.Until(retryAt) <= 0 || time.Until(retryAt) > time.Minute {
+		t.Fatalf("RetryAt() = %v, %v, want about a minute from now", retryAt, ok)
+	}
+	s := b.Status("fake")
+	if s.State != CircuitOpen || s.OpenedAt == nil || s.RetryAt == nil || s.LastError != errUnavailable.Error() {
+		t.Fatalf("status = %+v", s)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe error
+		want  string
+	}{
+		{"probe succeeds", nil, CircuitClosed},
+		{"probe fails", errUnavailable, CircuitOpen},
+		{"probe canceled", fmt.Errorf("generate: %w", context.Canceled), CircuitOpen},
+		{"probe deadline exceeded", fmt.Errorf("generate: %w", context.DeadlineExceeded), CircuitOpen},
+		{"probe rejected prompt", ErrProviderBadPrompt, CircuitClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(1, time.Minute)
+			b.record(errUnavailable)
+			expire(b)
+
+			if !b.allow() {
+				t.Fatal("probe rejected after openTimeout")
+			}
+			if s := b.Status("fake"); s.State != CircuitHalfOpen {
+				t.Fatalf("state = %s, want half_open", s.State)
+			}
+			// 試行中は他の呼び出しを止める
+			if b.allow() {
+				t.Fatal("second call allowed while probing")
+			}
+			b.record(tt.probe)
+			if s := b.Status("fake"); s.State != tt.want {
+				t.Fatalf("state = %s, want %s", s.State, tt.want)
+			}
+			if _, ok := b.RetryAt(); ok != (tt.want == CircuitOpen) {
+				t.Fatalf("RetryAt() ok = %v in state %s", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHalfOpenFailureRestartsTimeout(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	b.record(errUnavailable)
+	expire(b)
+	b.allow()
+	b.record(errUnavailable)
+	if b.allow() {
+		t.Fatal("breaker allowed a call right after a failed probe")
+	}
+	if retryAt, _ := b.RetryAt(); time.Until(retryAt) < 59*time.Second {
+		t.Fatalf("RetryAt() = %v, want the timeout restarted", retryAt)
+	}
+}
+
+func TestCircuitBreakerProvider(t *testing.T) {
+	next := &fakeProvider{errs: []error{errUnavailable}}
+	p := NewCircuitBreakerProvider(next, NewCircuitBreaker(1, time.Minute))
+
+	if _, err := p.Generate(context.Background(), &model.MusicRequest{}); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("err = %v, want the provider error", err)
+	}
+	_, err := p.Generate(context.Background(), &model.MusicRequest{})
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if next.calls != 1 {
+		t.Fatalf("provider called %d times, want 1", next.calls)
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerDeadline(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Minute)
+	b.record(errUnavailable)
+	b.record(fmt.Errorf("generate: %w", context.DeadlineExceeded))
+	// 期限切れは成功としても失敗としても数えない
+	if s := b.Status("fake"); s.State != CircuitClosed || s.ConsecutiveFailures != 1 {
+		t.Fatalf("status = %+v, want closed with 1 failure", s)
+	}
+}
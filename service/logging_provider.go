@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+type loggingProvider struct {
+	next IMusicProvider
+}
+
+// NewLoggingProvider は呼び出しごとに所要時間と結果をログに出力するプロバイダーを返す。
+// リトライのたびに出力されるよう、リトライやブレーカーより内側で使う
+func NewLoggingProvider(next IMusicProvider) IMusicProvider {
+	return &loggingProvider{next}
+}
+
+func (p *loggingProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *loggingProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	begin := time.Now()
+	res, err := p.next.Generate(ctx, req)
+	// プロンプトや歌詞は日記の内容を含むので出力しない
+	attrs := []any{
+		slog.String("provider", p.next.Name()),
+		slog.Int64("latency_ms", time.Since(begin).Milliseconds()),
+		slog.Int("instrumental", req.Instrumental),
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "music provider call failed", append(attrs, slog.Any("error", err))...)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "music provider call succeeded", append(attrs, slog.Int("songs", len(res.Data)))...)
+	return res, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/metrics"
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+type metricsProvider struct {
+	next IMusicProvider
+}
+
+// NewMetricsProvider はプロバイダーの呼び出し1回ごとに回数と所要時間を記録するプロバイダーを返す。
+// リトライのたびに記録されるよう、リトライやブレーカーより内側で使う
+func NewMetricsProvider(next IMusicProvider) IMusicProvider {
+	return &metricsProvider{next}
+}
+
+func (p *metricsProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *metricsProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	begin := time.Now()
+	res, err := p.next.Generate(ctx, req)
+	metrics.MusicProviderDuration.WithLabelValues(p.next.Name()).Observe(time.Since(begin).Seconds())
+	metrics.MusicProviderAttempts.WithLabelValues(p.next.Name(), metrics.Result(err)).Inc()
+	return res, err
+}
+
+type metricsMusicService struct {
+	next     IMusicService
+	provider string
+}
+
+// NewMetricsMusicService はリトライを含めた音楽生成の結果と所要時間を記録する
+func NewMetricsMusicService(next IMusicService, provider string) IMusicService {
+	return &metricsMusicService{next, provider}
+}
+
+func (s *metricsMusicService) CreateMusic(ctx context.Context, req *model.MusicRequest) ([]model.Music, error) {
+	begin := time.Now()
+	musics, err := s.next.CreateMusic(ctx, req)
+	metrics.MusicGenerationDuration.WithLabelValues(s.provider).Observe(time.Since(begin).Seconds())
+	metrics.MusicGenerations.WithLabelValues(s.provider, metrics.Result(err)).Inc()
+	return musics, err
+}
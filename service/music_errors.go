@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// 音楽生成プロバイダーのエラー種別
+var (
+	ErrProviderQuotaExceeded = errors.New("music provider quota exceeded")
+	ErrProviderAuthFailed    = errors.New("music provider authentication failed")
+	ErrProviderBadPrompt     = errors.New("music provider rejected the prompt")
+	ErrProviderUnavailable   = errors.New("music provider unavailable")
+)
+
+// ProviderError はプロバイダーから返されたエラーの詳細を保持する。
+// errors.Is で上記のエラー種別と比較できる。
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	Kind       error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %v (status %d)", e.Provider, e.Kind, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: %v (status %d): %s", e.Provider, e.Kind, e.StatusCode, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Kind
+}
+
+// classifyStatus はHTTPステータスコードをエラー種別に変換する
+func classifyStatus(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrProviderAuthFailed
+	case status == http.StatusPaymentRequired || status == http.StatusTooManyRequests:
+		return ErrProviderQuotaExceeded
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return ErrProviderBadPrompt
+	default:
+		return ErrProviderUnavailable
+	}
+}
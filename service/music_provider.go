@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// DefaultMusicProvider は MUSIC_PROVIDER が未設定の場合に使用するプロバイダー
+const DefaultMusicProvider = "topmediai"
+
+// IMusicProvider は音楽生成APIの実装を表す
+type IMusicProvider interface {
+	Name() string
+	Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error)
+}
+
+// MusicProviderFactory はプロバイダーを生成する関数
+type MusicProviderFactory func(cfg config.MusicConfig) (IMusicProvider, error)
+
+var musicProviders = map[string]MusicProviderFactory{}
+
+// RegisterMusicProvider はプロバイダーを名前で登録する
+func RegisterMusicProvider(name string, factory MusicProviderFactory) {
+	musicProviders[strings.ToLower(name)] = factory
+}
+
+// NewMusicProvider は cfg.Provider の名前で登録されたプロバイダーを生成する
+func NewMusicProvider(cfg config.MusicConfig) (IMusicProvider, error) {
+	name := cfg.Provider
+	if name == "" {
+		name = DefaultMusicProvider
+	}
+	factory, ok := musicProviders[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown music provider %q (available: %s)", name, strings.Join(MusicProviderNames(), ", "))
+	}
+	return factory(cfg)
+}
+
+// MusicProviderNames は登録済みのプロバイダー名を返す
+func MusicProviderNames() []string {
+	names := make([]string, 0, len(musicProviders))
+	for name := range musicProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// RetryPolicy は一時的な失敗に対するリトライの設定
+type RetryPolicy struct {
+	MaxAttempts int           // 最初の呼び出しを含む最大試行回数
+	BaseDelay   time.Duration // 1回目のリトライまでの待ち時間の上限
+	MaxDelay    time.Duration // 待ち時間の上限
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+}
+
+// Backoff は attempt 回目（1始まり）のリトライまでの待ち時間を返す。
+// 指数的に増える上限値の範囲でランダムに選ぶ（full jitter）。
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	ceiling := p.BaseDelay << (attempt - 1)
+	if ceiling > p.MaxDelay || ceiling <= 0 {
+		ceiling = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+type retryProvider struct {
+	next   IMusicProvider
+	policy RetryPolicy
+}
+
+// NewRetryProvider はプロバイダーが利用できない場合にリトライするプロバイダーを返す。
+// プロンプトや認証の問題など、再試行しても結果が変わらないエラーはリトライしない。
+func NewRetryProvider(next IMusicProvider, policy RetryPolicy) IMusicProvider {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &retryProvider{next, policy}
+}
+
+func (p *retryProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *retryProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var res *model.MusicResponse
+		res, err = p.next.Generate(ctx, req)
+		if err == nil {
+			return res, nil
+		}
+		if !isRetryable(err) || attempt >= p.policy.MaxAttempts {
+			return nil, err
+		}
+
+		timer := time.NewTimer(p.policy.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+func isRetryable(err error) bool {
+	// ブレーカーが開いている間はすぐに失敗させる
+	if errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	return errors.Is(err, ErrProviderUnavailable)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{1, 10 * time.Millisecond},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+		{4, 50 * time.Millisecond},
+		// シフトで溢れても MaxDelay を上限にする
+		{100, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.Backoff(tt.attempt); d < 0 || d > tt.ceiling {
+				t.Fatalf("Backoff(%d) = %v, want within [0, %v]", tt.attempt, d, tt.ceiling)
+			}
+		}
+	}
+}
+
+func TestRetryProvider(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"success", policy, nil, nil, 1},
+		{"recovers after unavailable", policy, []error{errUnavailable, errUnavailable}, nil, 3},
+		{"gives up after max attempts", policy, []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}, ErrProviderUnavailable, 3},
+		{"bad prompt is not retried", policy, []error{ErrProviderBadPrompt}, ErrProviderBadPrompt, 1},
+		{"auth failure is not retried", policy, []error{ErrProviderAuthFailed}, ErrProviderAuthFailed, 1},
+		{"open circuit is not retried", policy, []error{ErrCircuitOpen}, ErrCircuitOpen, 1},
+		{"max attempts below one calls once", RetryPolicy{}, []error{errUnavailable}, ErrProviderUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &fakeProvider{errs: tt.errs}
+			res, err := NewRetryProvider(next, tt.policy).Generate(context.Background(), &model.MusicRequest{})
+			if tt.wantErr == nil && (err != nil || res == nil) {
+				t.Fatalf("Generate() = %v, %v, want a response", res, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if next.calls != tt.wantCalls {
+				t.Fatalf("provider called %d times, want %d", next.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryProviderStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next := &fakeProvider{errs: []error{errUnavailable, errUnavailable}}
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+
+	_, err := NewRetryProvider(next, policy).Generate(ctx, &model.MusicRequest{})
+	if !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("err = %v, want the last provider error", err)
+	}
+	if next.calls != 1 {
+		t.Fatalf("provider called %d times, want 1", next.calls)
+	}
+}
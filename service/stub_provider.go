@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// stubVariants はスタブが1リクエストあたりに返す曲数（TopMediaiと同じく複数返す）
+const stubVariants = 2
+
+var stubTags = []string{
+	"calm, piano, ambient",
+	"uplifting, danceable, pop",
+	"nostalgic, acoustic, folk",
+	"melancholic, strings, ballad",
+	"energetic, rock, guitar",
+	"dreamy, lo-fi, chill",
+}
+
+func init() {
+	// ベースURLのデフォルトは config (STUB_BASE_URL) で決める
+	RegisterMusicProvider("stub", func(cfg config.MusicConfig) (IMusicProvider, error) {
+		return NewStubProvider(cfg.StubBaseURL), nil
+	})
+}
+
+// stubProvider はネットワークを使わずに決定的な結果を返すプロバイダー。
+// 音声と画像はサーバー自身の /stub/musics/:seed/... から配信する。
+type stubProvider struct {
+	baseURL string
+}
+
+func NewStubProvider(baseURL string) IMusicProvider {
+	return &stubProvider{strings.TrimRight(baseURL, "/")}
+}
+
+func (p *stubProvider) Name() string {
+	return "stub"
+}
+
+func (p *stubProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	data := make([]model.MusicData, 0, stubVariants)
+	for i := 0; i < stubVariants; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%s\x00%s\x00%d", i, req.Prompt, req.Lyrics, req.Title, req.Instrumental)))
+		seed := hex.EncodeToString(sum[:16])
+
+		title := req.Title
+		if title == "" {
+			title = "Stub Song " + seed[:6]
+		}
+		lyric := req.Lyrics
+		if lyric == "" && req.Instrumental == 0 {
+			lyric = "[Verse]\n" + firstLine(req.Prompt)
+		}
+
+		data = append(data, model.MusicData{
+			AudioFile: fmt.Sprintf("%s/stub/musics/%s/audio.wav", p.baseURL, seed),
+			ImageFile: fmt.Sprintf("%s/stub/musics/%s/cover.png", p.baseURL, seed),
+			ItemUUID:  fmt.Sprintf("%s-%s-%s-%s-%s", seed[0:8], seed[8:12], seed[12:16], seed[16:20], seed[20:32]),
+			Title:     title,
+			Lyric:     lyric,
+			Tags:      stubTags[int(sum[16])%len(stubTags)],
+		})
+	}
+
+	return &model.MusicResponse{
+		Status:  200,
+		Message: "Success",
+		Data:    data,
+	}, nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if r := []rune(s); len(r) > 40 {
+		s = string(r[:40])
+	}
+	return s
+}
+
+// IsStubSeed はスタブのシード値として正しい形式かを判定する
+func IsStubSeed(seed string) bool {
+	if len(seed) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(seed)
+	return err == nil
+}
+
+// StubAudio はシード値から決まる短いメロディのWAV（8kHz, 8bit, モノラル）を生成する
+func StubAudio(seed string) []byte {
+	const sampleRate = 8000
+	const noteSamples = sampleRate / 4
+	sum := sha256.Sum256([]byte(seed))
+
+	// 4音のメロディをペンタトニックスケールから選ぶ
+	scale := []float64{261.63, 293.66, 329.63, 392.00, 440.00, 523.25}
+	samples := make([]byte, 0, noteSamples*4)
+	for n := 0; n < 4; n++ {
+		freq := scale[int(sum[n])%len(scale)]
+		for i := 0; i < noteSamples; i++ {
+			// 音の切れ目が目立たないように減衰させる
+			envelope := 1 - float64(i)/noteSamples
+			v := math.Sin(2*math.Pi*freq*float64(i)/sampleRate) * envelope
+			samples = append(samples, byte(128+v*100))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(samples)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))         // fmtチャンクのサイズ
+	binary.Write(&buf, binary.LittleEndian, uint16(1))          // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(1))          // チャンネル数
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate)) // サンプルレート
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate)) // バイトレート
+	binary.Write(&buf, binary.LittleEndian, uint16(1))          // ブロックサイズ
+	binary.Write(&buf, binary.LittleEndian, uint16(8))          // ビット深度
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(samples)))
+	buf.Write(samples)
+	return buf.Bytes()
+}
+
+// StubCover はシード値から決まるグラデーションのPNG画像を生成する
+func StubCover(seed string) []byte {
+	const size = 64
+	sum := sha256.Sum256([]byte(seed))
+	from := color.RGBA{sum[0], sum[1], sum[2], 255}
+	to := color.RGBA{sum[3], sum[4], sum[5], 255}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			t := float64(x+y) / (2 * (size - 1))
+			img.Set(x, y, color.RGBA{
+				R: uint8(float64(from.R)*(1-t) + float64(to.R)*t),
+				G: uint8(float64(from.G)*(1-t) + float64(to.G)*t),
+				B: uint8(float64(from.B)*(1-t) + float64(to.B)*t),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+const (
+	// 音楽生成は1分以上かかることがあるため長めに設定する
+	topMediaiTimeout = 3 * time.Minute
+	// レスポンスボディの読み込み上限
+	topMediaiMaxBody = 1 << 20
+)
+
+func init() {
+	// ベースURLのデフォルトは config (TOPMEDIAI_BASE_URL) で決める
+	RegisterMusicProvider("topmediai", func(cfg config.MusicConfig) (IMusicProvider, error) {
+		return NewTopMediaiProvider(cfg.TopMediaiBaseURL, cfg.TopMediaiAPIKey, topMediaiTimeout), nil
+	})
+}
+
+// topMediaiProvider はTopMediai APIのクライアント。
+// TopMediaiへのリクエストはすべてこの実装を経由する。
+type topMediaiProvider struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewTopMediaiProvider(baseURL, apiKey string, timeout time.Duration) IMusicProvider {
+	return &topMediaiProvider{
+		baseURL:    baseURL,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: timeout, Transport: tracedTransport()},
+	}
+}
+
+func (p *topMediaiProvider) Name() string {
+	return "topmediai"
+}
+
+func (p *topMediaiProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// HTTPリクエストの作成
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/music", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+
+	// リクエストの実行
+	response, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		// 呼び出し元のキャンセル・期限切れはプロバイダーの障害ではないのでそのまま返す
+		// （httpClient のタイムアウトは ctx.Err() が nil なので障害として扱う）
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &ProviderError{Provider: p.Name(), Message: err.Error(), Kind: ErrProviderUnavailable}
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, topMediaiMaxBody))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &ProviderError{Provider: p.Name(), StatusCode: response.StatusCode, Message: err.Error(), Kind: ErrProviderUnavailable}
+	}
+
+	// HTTPステータスの確認
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, &ProviderError{
+			Provider:   p.Name(),
+			StatusCode: response.StatusCode,
+			Message:    errorMessage(body),
+			Kind:       classifyStatus(response.StatusCode),
+		}
+	}
+
+	// レスポンスの解析
+	var result model.MusicResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, &ProviderError{Provider: p.Name(), StatusCode: response.StatusCode, Message: "failed to decode response: " + err.Error(), Kind: ErrProviderUnavailable}
+	}
+
+	// TopMediaiはHTTP 200でもボディのstatusでエラーを返すことがある
+	if result.Status != 0 && result.Status != http.StatusOK {
+		return nil, &ProviderError{Provider: p.Name(), StatusCode: result.Status, Message: result.Message, Kind: classifyStatus(result.Status)}
+	}
+	if len(result.Data) == 0 {
+		return nil, &ProviderError{Provider: p.Name(), StatusCode: response.StatusCode, Message: "response contains no music", Kind: ErrProviderUnavailable}
+	}
+	return &result, nil
+}
+
+// errorMessage はエラーレスポンスのボディからメッセージを取り出す
+func errorMessage(body []byte) string {
+	var res struct {
+		Message string `json:"message"`
+		Detail  string `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &res); err == nil {
+		if res.Message != "" {
+			return res.Message
+		}
+		if res.Detail != "" {
+			return res.Detail
+		}
+	}
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	return string(body)
+}
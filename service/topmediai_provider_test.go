@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+func TestTopMediaiProviderStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"unauthorized", http.StatusUnauthorized, `{"message":"bad key"}`, ErrProviderAuthFailed},
+		{"rate limited", http.StatusTooManyRequests, `{"message":"slow down"}`, ErrProviderQuotaExceeded},
+		{"bad prompt", http.StatusUnprocessableEntity, `{"detail":"prompt"}`, ErrProviderBadPrompt},
+		{"server error", http.StatusBadGateway, `oops`, ErrProviderUnavailable},
+		{"error in body", http.StatusOK, `{"status":400,"message":"prompt"}`, ErrProviderBadPrompt},
+		{"no music", http.StatusOK, `{"status":200,"data":[]}`, ErrProviderUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := NewTopMediaiProvider(srv.URL, "key", time.Second).Generate(context.Background(), &model.MusicRequest{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopMediaiProviderCallerContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		ctx         func() (context.Context, context.CancelFunc)
+		timeout     time.Duration
+		want        error
+		unavailable bool
+	}{
+		{"caller deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 20*time.Millisecond)
+		}, time.Minute, context.DeadlineExceeded, false},
+		{"caller cancel", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return ctx, cancel
+		}, time.Minute, context.Canceled, false},
+		// プロバイダーの応答が遅いのは障害として扱う
+		{"client timeout", func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}, 20 * time.Millisecond, ErrProviderUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			_, err := NewTopMediaiProvider(srv.URL, "key", tt.timeout).Generate(ctx, &model.MusicRequest{})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if got := errors.Is(err, ErrProviderUnavailable); got != tt.unavailable {
+				t.Fatalf("errors.Is(err, ErrProviderUnavailable) = %v, want %v", got, tt.unavailable)
+			}
+		})
+	}
+}
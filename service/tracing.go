@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type tracingProvider struct {
+	next IMusicProvider
+}
+
+// NewTracingProvider はプロバイダーの呼び出し1回ごとにスパンを記録するプロバイダーを返す
+func NewTracingProvider(next IMusicProvider) IMusicProvider {
+	return &tracingProvider{next}
+}
+
+func (p *tracingProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *tracingProvider) Generate(ctx context.Context, req *model.MusicRequest) (*model.MusicResponse, error) {
+	ctx, span := tracing.Start(ctx, "MusicProvider.Generate", attribute.String("music.provider", p.next.Name()))
+	res, err := p.next.Generate(ctx, req)
+	tracing.End(span, err)
+	return res, err
+}
+
+// NewInstrumentedProvider はプロバイダーの呼び出し1回ごとにスパン・メトリクス・ログを記録する。
+// リトライのたびに記録されるよう、リトライやブレーカーより内側で使う
+func NewInstrumentedProvider(next IMusicProvider) IMusicProvider {
+	return NewTracingProvider(NewMetricsProvider(NewLoggingProvider(next)))
+}
+
+// tracedTransport は外部へのリクエストごとにスパンを記録し、トレースコンテキストを伝播する
+func tracedTransport() http.RoundTripper {
+	return otelhttp.NewTransport(http.DefaultTransport)
+}
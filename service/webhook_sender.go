@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookPrivateAddress はWebhookの送信先が内部ネットワークのアドレスだったことを表す
+var ErrWebhookPrivateAddress = errors.New("webhook destination resolves to a private address")
+
+// IWebhookSender は署名付きのWebhookを送信する
+type IWebhookSender interface {
+	// Send は body をPOSTし、レスポンスのステータスコードを返す
+	Send(ctx context.Context, url, secret, eventType, deliveryId string, body []byte) (int, error)
+}
+
+type webhookSender struct {
+	httpClient *http.Client
+}
+
+// allowPrivate が false の場合、ループバックやプライベートアドレスへの送信を拒否する
+// （DNSの解決後に判定するので、名前を使った回避もできない）
+func NewWebhookSender(allowPrivate bool) IWebhookSender {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if allowPrivate {
+				return nil
+			}
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddress(addrPort.Addr()) {
+				return ErrWebhookPrivateAddress
+			}
+			return nil
+		},
+	}
+	return &webhookSender{&http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+		// リダイレクト先は検証していないので追わない
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// deniedPrefixes はWebhookの送信を許可しないアドレスの範囲
+var deniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // このネットワーク
+	netip.MustParsePrefix("10.0.0.0/8"),      // プライベート
+	netip.MustParsePrefix("100.64.0.0/10"),   // CGNAT（クラウドの内部ネットワークで使われる）
+	netip.MustParsePrefix("127.0.0.0/8"),     // ループバック
+	netip.MustParsePrefix("169.254.0.0/16"),  // リンクローカル（メタデータサーバーを含む）
+	netip.MustParsePrefix("172.16.0.0/12"),   // プライベート
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF プロトコル割り当て
+	netip.MustParsePrefix("192.0.2.0/24"),    // ドキュメント用
+	netip.MustParsePrefix("192.168.0.0/16"),  // プライベート
+	netip.MustParsePrefix("198.18.0.0/15"),   // ベンチマーク用
+	netip.MustParsePrefix("198.51.100.0/24"), // ドキュメント用
+	netip.MustParsePrefix("203.0.113.0/24"),  // ドキュメント用
+	netip.MustParsePrefix("224.0.0.0/4"),     // マルチキャスト
+	netip.MustParsePrefix("240.0.0.0/4"),     // 予約済み・ブロードキャスト
+	netip.MustParsePrefix("::/128"),          // 未指定
+	netip.MustParsePrefix("::1/128"),         // ループバック
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64（IPv4 のアドレスを埋め込む）
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // ローカルの NAT64
+	netip.MustParsePrefix("100::/64"),        // 破棄用
+	netip.MustParsePrefix("2001:db8::/32"),   // ドキュメント用
+	netip.MustParsePrefix("fc00::/7"),        // ユニークローカル
+	netip.MustParsePrefix("fe80::/10"),       // リンクローカル
+	netip.MustParsePrefix("ff00::/8"),        // マルチキャスト
+}
+
+// isPublicAddress は addr がWebhookを送信してよいアドレスかを返す。
+// IPv4 射影アドレス (::ffff:10.0.0.1 など) は IPv4 として判定する
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.Zone() != "" {
+		return false
+	}
+	for _, prefix := range deniedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// SignWebhook は "<timestamp>.<body>" のHMAC-SHA256を返す
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookSender) Send(ctx context.Context, url, secret, eventType, deliveryId string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "diary-music-webhook/1.0")
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set("X-Webhook-Delivery", deliveryId)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(secret, timestamp, body))
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("webhook endpoint returned status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		// IPv4 射影アドレスは IPv4 として判定する
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:93.184.216.34", true},
+		// NAT64 で IPv4 の内部アドレスに届く
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b::5db8:d822", false},
+		{"64:ff9b:1::a00:1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSenderRejectsPrivateAddress(t *testing.T) {
+	sender := NewWebhookSender(false)
+	for _, url := range []string{"http://127.0.0.1:1/hook", "http://[::ffff:169.254.169.254]:1/hook", "http://100.64.0.1:1/hook"} {
+		_, err := sender.Send(context.Background(), url, "secret", "test", "1", []byte("{}"))
+		if !errors.Is(err, ErrWebhookPrivateAddress) {
+			t.Errorf("Send(%s) error = %v, want ErrWebhookPrivateAddress", url, err)
+		}
+	}
+}
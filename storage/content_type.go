@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"mime"
+	"path"
+	"strings"
+)
+
+// 環境によって mime.TypeByExtension が知らない音声形式
+var audioTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".wav": "audio/wav",
+	".m4a": "audio/mp4",
+	".aac": "audio/aac",
+	".ogg": "audio/ogg",
+	".oga": "audio/ogg",
+}
+
+// ContentType はキーの拡張子からContent-Typeを決める。判別できない場合は fallback を返す
+func ContentType(key, fallback string) string {
+	ext := strings.ToLower(path.Ext(key))
+	if t, ok := audioTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	if fallback == "" {
+		return "application/octet-stream"
+	}
+	return fallback
+}
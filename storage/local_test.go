@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorageDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	st, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{
+		"users/1/diaries/1/a.mp3",
+		"users/1/diaries/2/b.png",
+		"users/1/exports/3/export.zip",
+		"users/10/diaries/4/c.mp3",
+	} {
+		if err := st.Put(ctx, key, strings.NewReader("data"), 4, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := st.DeletePrefix(ctx, "users/1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("DeletePrefix() = %d, want 3", count)
+	}
+	if _, _, err := st.Open(ctx, "users/1/diaries/1/a.mp3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after DeletePrefix error = %v, want ErrNotFound", err)
+	}
+	obj, _, err := st.Open(ctx, "users/10/diaries/4/c.mp3")
+	if err != nil {
+		t.Fatalf("other user's asset was deleted: %v", err)
+	}
+	obj.Close()
+
+	// 存在しないディレクトリは 0 件で成功する
+	if count, err := st.DeletePrefix(ctx, "users/2/"); err != nil || count != 0 {
+		t.Errorf("DeletePrefix() on missing prefix = %d, %v", count, err)
+	}
+	if _, err := st.DeletePrefix(ctx, "users/1"); err == nil {
+		t.Error("DeletePrefix() without trailing slash should fail")
+	}
+}
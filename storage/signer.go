@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSignatureExpired = errors.New("signed url expired")
+	ErrSignatureInvalid = errors.New("signed url signature is invalid")
+)
+
+// URLSigner は保存したファイルを期限付きでダウンロードするためのURLを作る
+type URLSigner struct {
+	secret  []byte
+	baseURL string
+	ttl     time.Duration
+}
+
+// baseURL はAPIサーバーのURL（例: https://api.example.com）
+func NewURLSigner(secret, baseURL string, ttl time.Duration) *URLSigner {
+	return &URLSigner{[]byte(secret), strings.TrimRight(baseURL, "/"), ttl}
+}
+
+// SignedURL は /assets/<key>?expires=...&signature=... の形式のURLを返す
+func (s *URLSigner) SignedURL(key string) string {
+	expires := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("signature", s.sign(key, expires))
+	return fmt.Sprintf("%s/assets/%s?%s", s.baseURL, key, q.Encode())
+}
+
+// Verify は SignedURL で作ったURLのキー・有効期限・署名を検証する
+func (s *URLSigner) Verify(key, expires, signature string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+		return ErrSignatureInvalid
+	}
+	if time.Now().Unix() > exp {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
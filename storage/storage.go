@@ -0,0 +1,56 @@
+// Package storage は生成された音声やカバー画像などのファイルを保存する
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+)
+
+// ErrNotFound は指定したキーのオブジェクトが存在しないことを表す
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo は保存されたオブジェクトのメタデータ
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// Object は読み込み中のオブジェクト。Range リクエストに応えられるよう Seek できる
+type Object interface {
+	io.ReadSeekCloser
+}
+
+type IStorage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (Object, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix は prefix で始まるキーのオブジェクトをすべて削除し、削除した件数を返す
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// NewStorage は cfg.Backend (local | s3) に応じたストレージを作る
+func NewStorage(cfg config.StorageConfig) (IStorage, error) {
+	switch backend := cfg.Backend; backend {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalDir)
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			UseSSL:    cfg.S3.UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
@@ -0,0 +1,171 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/logging"
+	"github.com/kenta-kenta/diary-music/mail"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/storage"
+	"github.com/kenta-kenta/diary-music/tracing"
+	"github.com/kenta-kenta/diary-music/validator"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// 削除を始めてから終わらなかった場合（失敗・プロセス停止・生成中のジョブ）に再び取り出すまでの時間
+const accountPurgeRetryAfter = 10 * time.Minute
+
+// IAccountDeletionUsecase はアカウントの削除の予定・取り消しと、猶予期間を過ぎたアカウントの削除を行う
+type IAccountDeletionUsecase interface {
+	// RequestDeletion はパスワードを確認して削除を予定し、すべてのセッションを無効にする
+	RequestDeletion(ctx context.Context, userId uint, req model.AccountDeletionRequest) (model.AccountDeletionResponse, error)
+	// CancelDeletion は削除を始める前の予定を取り消す
+	CancelDeletion(ctx context.Context, userId uint) error
+	// PurgeNextAccount は猶予期間を過ぎたアカウントを1件削除する（ワーカーから呼び出す）
+	PurgeNextAccount(ctx context.Context) (bool, error)
+}
+
+type accountDeletionUsecase struct {
+	ur     repository.IUserRepository
+	dr     repository.IAccountDeletionRepository
+	sr     repository.ISessionRepository
+	uv     validator.IUserValidator
+	st     storage.IStorage
+	mailer mail.IMailer
+	cfg    config.AuthConfig
+}
+
+func NewAccountDeletionUsecase(ur repository.IUserRepository, dr repository.IAccountDeletionRepository, sr repository.ISessionRepository, uv validator.IUserValidator, st storage.IStorage, mailer mail.IMailer, cfg config.AuthConfig) IAccountDeletionUsecase {
+	return &accountDeletionUsecase{ur, dr, sr, uv, st, mailer, cfg}
+}
+
+func (du *accountDeletionUsecase) RequestDeletion(ctx context.Context, userId uint, req model.AccountDeletionRequest) (model.AccountDeletionResponse, error) {
+	if err := du.uv.DeletionValidate(req); err != nil {
+		return model.AccountDeletionResponse{}, apperror.Validation(err)
+	}
+	user := model.User{}
+	if err := du.ur.GetUserById(ctx, &user, userId); err != nil {
+		return model.AccountDeletionResponse{}, notFound(err, ErrUserNotFound)
+	}
+	if err := checkPassword(user, req.Password); err != nil {
+		return model.AccountDeletionResponse{}, err
+	}
+	now := time.Now()
+	deletion := model.AccountDeletion{
+		UserID:      userId,
+		RequestedAt: now,
+		PurgeAfter:  now.Add(du.cfg.DeletionGracePeriod),
+	}
+	if err := du.dr.CreateDeletion(ctx, &deletion); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return model.AccountDeletionResponse{}, ErrDeletionAlreadyRequested.Wrap(err)
+		}
+		return model.AccountDeletionResponse{}, err
+	}
+	// すべての端末からログアウトさせる（取り消す場合はログインし直す）
+	if err := du.sr.RevokeUserSessions(ctx, userId, 0, model.SessionRevokedAccountDeletion, now); err != nil {
+		return model.AccountDeletionResponse{}, err
+	}
+	slog.InfoContext(ctx, "account deletion requested", "deletion_id", deletion.ID, "purge_after", deletion.PurgeAfter)
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "アカウントの削除を受け付けました",
+		Text: fmt.Sprintf("%s さん\n\n"+
+			"アカウントの削除を受け付けました。%s を過ぎると日記・曲・音声などのデータをすべて削除します。\n"+
+			"それまでにログインして取り消すと、アカウントはそのまま使えます。\n"+
+			"心当たりがない場合はすぐにログインして削除を取り消し、パスワードを変更してください。\n",
+			user.UserName, deletion.PurgeAfter.Format("2006-01-02 15:04 MST")),
+	}
+	if err := du.mailer.Send(ctx, msg); err != nil {
+		slog.WarnContext(ctx, "failed to send account deletion mail", "error", err)
+	}
+	return model.AccountDeletionResponse{PurgeAfter: deletion.PurgeAfter}, nil
+}
+
+func (du *accountDeletionUsecase) CancelDeletion(ctx context.Context, userId uint) error {
+	if err := du.dr.CancelDeletion(ctx, userId, time.Now()); err != nil {
+		return notFound(err, ErrDeletionNotFound)
+	}
+	slog.InfoContext(ctx, "account deletion cancelled")
+	return nil
+}
+
+func (du *accountDeletionUsecase) PurgeNextAccount(ctx context.Context) (bool, error) {
+	deletion, err := du.dr.ClaimNextDeletion(ctx, accountPurgeRetryAfter)
+	if err != nil {
+		return false, err
+	}
+	if deletion == nil {
+		return false, nil
+	}
+	ctx = logging.WithUserID(ctx, deletion.UserID)
+	ctx, span := tracing.Start(ctx, "AccountDeletionUsecase.PurgeAccount",
+		attribute.Int64("account_deletion.id", int64(deletion.ID)),
+	)
+	postponed, err := du.purge(ctx, deletion)
+	tracing.End(span, err)
+	if err != nil {
+		// 途中まで削除した場合も、次に取り出したときに残りを削除する
+		if rerr := du.dr.RecordFailure(context.WithoutCancel(ctx), deletion, err); rerr != nil {
+			slog.ErrorContext(ctx, "failed to record account purge failure", "deletion_id", deletion.ID, "error", rerr)
+		}
+		return true, fmt.Errorf("purge account deletion %d: %w", deletion.ID, err)
+	}
+	// 延期した予定はしばらく取り出されないので、他の予定がなければ待機する
+	return !postponed, nil
+}
+
+// purge はストレージのファイルを削除してからDBのデータを削除する。
+// 生成中のジョブがある場合は曲が後から保存されないよう延期する。
+// 削除を予定した後は新しい日記・ジョブ・エクスポートを登録できないので、終わるのを待てばよい
+func (du *accountDeletionUsecase) purge(ctx context.Context, deletion *model.AccountDeletion) (bool, error) {
+	// ファイルを消す前に確認する（ワーカーが同時に取り出すことがあるので PurgeUser でも確認する）
+	active, err := du.dr.CountActiveJobs(ctx, deletion.UserID)
+	if err != nil {
+		return false, err
+	}
+	if active > 0 {
+		slog.InfoContext(ctx, "account purge postponed until music jobs finish", "deletion_id", deletion.ID, "active_jobs", active)
+		return true, nil
+	}
+
+	keys, err := du.dr.GetAssetKeys(ctx, deletion.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		if err := du.st.Delete(ctx, key); err != nil {
+			return false, fmt.Errorf("delete asset %s: %w", key, err)
+		}
+	}
+	// 以前に削除した日記のファイルなど、DBから辿れないファイルもユーザーのディレクトリごと削除する
+	orphans, err := du.st.DeletePrefix(ctx, fmt.Sprintf("users/%d/", deletion.UserID))
+	if err != nil {
+		return false, fmt.Errorf("delete user assets: %w", err)
+	}
+	deletion.Assets = len(keys) + orphans
+
+	if err := du.dr.PurgeUser(context.WithoutCancel(ctx), deletion, time.Now()); err != nil {
+		if errors.Is(err, repository.ErrActiveWork) {
+			slog.InfoContext(ctx, "account purge postponed until music jobs and exports finish", "deletion_id", deletion.ID)
+			return true, nil
+		}
+		return false, err
+	}
+	slog.InfoContext(ctx, "account purged",
+		"deletion_id", deletion.ID,
+		"diaries", deletion.Diaries,
+		"musics", deletion.Musics,
+		"assets", deletion.Assets,
+	)
+	return false, nil
+}
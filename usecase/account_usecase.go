@@ -0,0 +1,232 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/mail"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/validator"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// IAccountUsecase はパスワードの再設定とメールアドレスの確認を行う
+type IAccountUsecase interface {
+	// ForgotPassword はパスワード再設定のメールを送る（登録されていないメールアドレスでも成功する）
+	ForgotPassword(ctx context.Context, email string) error
+	// ResetPassword はトークンを使ってパスワードを変更し、すべてのセッションを無効にする
+	ResetPassword(ctx context.Context, req model.PasswordResetRequest) error
+	// SendVerification はメールアドレス確認のメールを送る
+	SendVerification(ctx context.Context, userId uint) error
+	// SendEmailChange は変更後のメールアドレスに確認のメールを送る（確認されるまでメールアドレスは変わらない）
+	SendEmailChange(ctx context.Context, user model.User, email string) error
+	// VerifyEmail はメールアドレスを確認済みにする（変更のトークンの場合はメールアドレスを変更する）
+	VerifyEmail(ctx context.Context, token string) error
+}
+
+// パスワード再設定のメールをバックグラウンドで送る際の制限時間
+const passwordResetMailTimeout = time.Minute
+
+type accountUsecase struct {
+	ur      repository.IUserRepository
+	ar      repository.IAccountRepository
+	sr      repository.ISessionRepository
+	uv      validator.IUserValidator
+	mailer  mail.IMailer
+	cfg     config.AuthConfig
+	linkURL string
+	// background はバックグラウンドで送信中のパスワード再設定のメール
+	background sync.WaitGroup
+}
+
+// linkURL はメールに載せるリンクのフロントエンドのURL
+func NewAccountUsecase(ur repository.IUserRepository, ar repository.IAccountRepository, sr repository.ISessionRepository, uv validator.IUserValidator, mailer mail.IMailer, cfg config.AuthConfig, linkURL string) IAccountUsecase {
+	return &accountUsecase{ur: ur, ar: ar, sr: sr, uv: uv, mailer: mailer, cfg: cfg, linkURL: linkURL}
+}
+
+// ForgotPassword はメールアドレスの検証のみ行って返し、ユーザーの検索・トークンの作成・送信はバックグラウンドで行う。
+// 登録されているかどうかで応答時間が変わらないようにする
+func (au *accountUsecase) ForgotPassword(ctx context.Context, email string) error {
+	if err := au.uv.EmailValidate(email); err != nil {
+		return apperror.Validation(err)
+	}
+	au.background.Add(1)
+	go func() {
+		defer au.background.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), passwordResetMailTimeout)
+		defer cancel()
+		if err := au.sendPasswordReset(ctx, email); err != nil {
+			slog.ErrorContext(ctx, "failed to send password reset mail", "error", err)
+		}
+	}()
+	return nil
+}
+
+// sendPasswordReset は登録されているメールアドレスであれば再設定のメールを送る
+func (au *accountUsecase) sendPasswordReset(ctx context.Context, email string) error {
+	user := model.User{}
+	err := au.ur.GetUserByEmail(ctx, &user, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	token, err := au.createToken(ctx, user.ID, user.Email, model.UserTokenPasswordReset, au.cfg.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "パスワードの再設定",
+		Text: fmt.Sprintf("%s さん\n\n"+
+			"パスワードの再設定を受け付けました。次のリンクから新しいパスワードを設定してください。\n\n%s\n\n"+
+			"リンクの有効期限は %s です。\n"+
+			"心当たりがない場合はこのメールを無視してください。パスワードは変更されません。\n",
+			user.UserName, au.link("/password/reset", token), formatTTL(au.cfg.PasswordResetTTL)),
+	}
+	if err := au.mailer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("send to user %d: %w", user.ID, err)
+	}
+	return nil
+}
+
+func (au *accountUsecase) ResetPassword(ctx context.Context, req model.PasswordResetRequest) error {
+	if req.Token == "" {
+		return ErrInvalidToken
+	}
+	if err := au.uv.PasswordValidate(req.Password); err != nil {
+		return apperror.Validation(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	userId, err := au.ar.ResetPassword(ctx, hashToken(req.Token), string(hash), now)
+	if err != nil {
+		return notFound(err, ErrInvalidToken)
+	}
+	// 古いパスワードでログインしていた端末をすべてログアウトさせる
+	if err := au.sr.RevokeUserSessions(ctx, userId, 0, model.SessionRevokedPasswordReset, now); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "password reset", "user_id", userId)
+	return nil
+}
+
+func (au *accountUsecase) SendVerification(ctx context.Context, userId uint) error {
+	user := model.User{}
+	if err := au.ur.GetUserById(ctx, &user, userId); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	token, err := au.createToken(ctx, user.ID, user.Email, model.UserTokenEmailVerification, au.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "メールアドレスの確認",
+		Text: fmt.Sprintf("%s さん\n\n"+
+			"diary-music への登録ありがとうございます。次のリンクからメールアドレスを確認してください。\n"+
+			"確認が終わると日記から音楽を生成できるようになります。\n\n%s\n\n"+
+			"リンクの有効期限は %s です。\n",
+			user.UserName, au.link("/verify-email", token), formatTTL(au.cfg.EmailVerificationTTL)),
+	}
+	if err := au.mailer.Send(ctx, msg); err != nil {
+		return apperror.UpstreamFailure("mail_unavailable", "Failed to send mail", err)
+	}
+	return nil
+}
+
+func (au *accountUsecase) SendEmailChange(ctx context.Context, user model.User, email string) error {
+	token, err := au.createToken(ctx, user.ID, email, model.UserTokenEmailChange, au.cfg.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	msg := mail.Message{
+		To:      email,
+		Subject: "メールアドレスの変更",
+		Text: fmt.Sprintf("%s さん\n\n"+
+			"メールアドレスをこのアドレスに変更します。次のリンクから変更を完了してください。\n\n%s\n\n"+
+			"リンクの有効期限は %s です。\n"+
+			"心当たりがない場合はこのメールを無視してください。\n",
+			user.UserName, au.link("/verify-email", token), formatTTL(au.cfg.EmailVerificationTTL)),
+	}
+	if err := au.mailer.Send(ctx, msg); err != nil {
+		return apperror.UpstreamFailure("mail_unavailable", "Failed to send mail", err)
+	}
+	// 本人以外が変更しようとした場合に気付けるよう、変更前のアドレスにも知らせる
+	notice := mail.Message{
+		To:      user.Email,
+		Subject: "メールアドレスの変更のお知らせ",
+		Text: fmt.Sprintf("%s さん\n\n"+
+			"アカウントのメールアドレスを %s に変更する手続きが行われました。\n"+
+			"変更後のアドレスで確認が終わると、このアドレスには通知が届かなくなります。\n"+
+			"心当たりがない場合はすぐにパスワードを変更してください。\n",
+			user.UserName, email),
+	}
+	if err := au.mailer.Send(ctx, notice); err != nil {
+		slog.WarnContext(ctx, "failed to send email change notice", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
+
+func (au *accountUsecase) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	userId, err := au.ar.VerifyEmail(ctx, hashToken(token), time.Now())
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 確認を待っている間に他のユーザーが同じメールアドレスで登録した
+		return ErrEmailTaken.Wrap(err)
+	}
+	if err != nil {
+		return notFound(err, ErrInvalidToken)
+	}
+	slog.InfoContext(ctx, "email verified", "user_id", userId)
+	return nil
+}
+
+// createToken はワンタイムトークンを保存してメールに載せる値を返す（email はメールを送るアドレス）
+func (au *accountUsecase) createToken(ctx context.Context, userId uint, email string, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	if err := au.ar.CreateToken(ctx, &model.UserToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		Email:     email,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// link はフロントエンドのページにトークンを付けたURLを返す
+func (au *accountUsecase) link(path string, token string) string {
+	return au.linkURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+// formatTTL は有効期限をメールに載せる形にする（"1時間"、"48時間"、"30分"）
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d時間", d/time.Hour)
+	}
+	return fmt.Sprintf("%d分", d/time.Minute)
+}
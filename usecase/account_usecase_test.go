@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/mail"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/validator"
+	"gorm.io/gorm"
+)
+
+type fakeEmailUserRepository struct {
+	repository.IUserRepository
+	users map[string]model.User
+}
+
+func (r *fakeEmailUserRepository) GetUserByEmail(ctx context.Context, user *model.User, email string) error {
+	u, ok := r.users[email]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	*user = u
+	return nil
+}
+
+type fakeTokenRepository struct {
+	repository.IAccountRepository
+	tokens []model.UserToken
+}
+
+func (r *fakeTokenRepository) CreateToken(ctx context.Context, token *model.UserToken) error {
+	r.tokens = append(r.tokens, *token)
+	return nil
+}
+
+// blockingMailer は release が閉じられるまで送信を止める
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mail.Message
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg mail.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+func TestForgotPasswordDoesNotWaitForMail(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantMail bool
+	}{
+		{"registered", "taro@example.com", true},
+		{"not registered", "hanako@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &fakeTokenRepository{}
+			mailer := &blockingMailer{release: make(chan struct{}), sent: make(chan mail.Message, 1)}
+			au := NewAccountUsecase(
+				&fakeEmailUserRepository{users: map[string]model.User{"taro@example.com": {ID: 1, UserName: "taro", Email: "taro@example.com"}}},
+				ar, nil, validator.NewUserValidator(), mailer,
+				config.AuthConfig{PasswordResetTTL: time.Hour}, "http://localhost:3000",
+			).(*accountUsecase)
+
+			// 送信が終わる前に返る
+			if err := au.ForgotPassword(context.Background(), tt.email); err != nil {
+				t.Fatal(err)
+			}
+			close(mailer.release)
+			au.background.Wait()
+
+			select {
+			case msg := <-mailer.sent:
+				if !tt.wantMail {
+					t.Fatalf("unexpected mail to %s", msg.To)
+				}
+				if msg.To != tt.email || !strings.Contains(msg.Text, "/password/reset?token=") {
+					t.Errorf("mail = %+v", msg)
+				}
+				if len(ar.tokens) != 1 || ar.tokens[0].Purpose != model.UserTokenPasswordReset {
+					t.Errorf("tokens = %+v", ar.tokens)
+				}
+			default:
+				if tt.wantMail {
+					t.Fatal("no mail sent")
+				}
+				if len(ar.tokens) != 0 {
+					t.Errorf("tokens = %+v, want none", ar.tokens)
+				}
+			}
+		})
+	}
+}
+
+func TestForgotPasswordValidatesEmail(t *testing.T) {
+	au := NewAccountUsecase(nil, nil, nil, validator.NewUserValidator(), nil, config.AuthConfig{}, "").(*accountUsecase)
+	if err := au.ForgotPassword(context.Background(), "not-an-email"); err == nil {
+		t.Fatal("invalid email accepted")
+	}
+	au.background.Wait()
+}
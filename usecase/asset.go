@@ -0,0 +1,32 @@
+package usecase
+
+import "github.com/kenta-kenta/diary-music/model"
+
+// IAssetURLSigner は自前のストレージに保存したファイルの署名付きURLを作る
+type IAssetURLSigner interface {
+	SignedURL(key string) string
+}
+
+// linkMusicData は保存済みの音声・カバー画像のURLを署名付きURLに差し替える。
+// 複製に失敗した曲はプロバイダーのURLのまま返す。
+func linkMusicData(as IAssetURLSigner, data []model.MusicData) {
+	for i := range data {
+		if data[i].AudioKey != "" {
+			data[i].AudioFile = as.SignedURL(data[i].AudioKey)
+		}
+		if data[i].ImageKey != "" {
+			data[i].ImageFile = as.SignedURL(data[i].ImageKey)
+		}
+	}
+}
+
+func linkMusics(as IAssetURLSigner, musics []model.Music) {
+	for i := range musics {
+		if musics[i].AudioKey != "" {
+			musics[i].AudioFile = as.SignedURL(musics[i].AudioKey)
+		}
+		if musics[i].ImageKey != "" {
+			musics[i].ImageFile = as.SignedURL(musics[i].ImageKey)
+		}
+	}
+}
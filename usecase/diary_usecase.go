@@ -1,71 +1,87 @@
 package usecase
 
 import (
+	"context"
+	"errors"
+	"log/slog"
 	"strconv"
 
+	"github.com/kenta-kenta/diary-music/apperror"
 	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/prompt"
 	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/storage"
 	"github.com/kenta-kenta/diary-music/validator"
 )
 
 type IDiaryUsecase interface {
-	GetAllDiaries(userId uint, page int, pageSize int) (*model.PaginationResponse, error)
-	GetDiaryById(userId uint, diaryId uint) (model.DiaryResponse, error)
-	CreateDiary(diary model.Diary) (model.DiaryResponse, error)
-	CreateDiaryWithMusic(diary *model.Diary) (*model.DiaryResponse, error)
-	UpdateDiary(userId uint, diaryId uint, diary model.Diary) (model.DiaryResponse, error)
-	DeleteDiary(userId uint, diaryId uint) error
-	GetDiaryDates(userId uint, year, month string) (*model.DiaryDateCountResponse, error)
+	GetAllDiaries(ctx context.Context, userId uint, page int, pageSize int) (*model.PaginationResponse, error)
+	GetDiaryById(ctx context.Context, userId uint, diaryId uint) (model.DiaryResponse, error)
+	CreateDiary(ctx context.Context, diary model.Diary) (model.DiaryResponse, error)
+	CreateDiaryWithMusic(ctx context.Context, diary *model.Diary) (*model.DiaryResponse, error)
+	UpdateDiary(ctx context.Context, userId uint, diaryId uint, diary model.Diary) (model.DiaryResponse, error)
+	DeleteDiary(ctx context.Context, userId uint, diaryId uint) error
+	GetDiaryDates(ctx context.Context, userId uint, year, month string) (*model.DiaryDateCountResponse, error)
 }
 
 type diaryUsecase struct {
 	dr repository.IDiaryRepository
 	dv validator.IDiaryValidator
+	pb prompt.IPromptBuilder
+	jn IJobNotifier
+	qu IQuotaUsecase
+	as IAssetURLSigner
+	ep IEventPublisher
+	st storage.IStorage
 }
 
-func NewDiaryUsecase(dr repository.IDiaryRepository, dv validator.IDiaryValidator) IDiaryUsecase {
-	return &diaryUsecase{dr, dv}
+func NewDiaryUsecase(dr repository.IDiaryRepository, dv validator.IDiaryValidator, pb prompt.IPromptBuilder, jn IJobNotifier, qu IQuotaUsecase, as IAssetURLSigner, ep IEventPublisher, st storage.IStorage) IDiaryUsecase {
+	return &diaryUsecase{dr, dv, pb, jn, qu, as, ep, st}
 }
 
-func (du *diaryUsecase) GetAllDiaries(userId uint, page, pageSize int) (*model.PaginationResponse, error) {
+func (du *diaryUsecase) GetAllDiaries(ctx context.Context, userId uint, page, pageSize int) (*model.PaginationResponse, error) {
 	// PaginationQueryを作成
 	query := &model.PaginationQuery{
 		Page:     page,
 		PageSize: pageSize,
 	}
 
-	return du.dr.GetAllDiaries(query, userId)
+	res, err := du.dr.GetAllDiaries(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res.DiaryResponse {
+		linkMusicData(du.as, res.DiaryResponse[i].MusicData)
+	}
+	return res, nil
 }
 
-func (du *diaryUsecase) GetDiaryById(userId uint, diaryId uint) (model.DiaryResponse, error) {
+func (du *diaryUsecase) GetDiaryById(ctx context.Context, userId uint, diaryId uint) (model.DiaryResponse, error) {
 	diary := model.Diary{}
-	if err := du.dr.GetDiaryById(&diary, userId, diaryId); err != nil {
-		return model.DiaryResponse{}, err
+	if err := du.dr.GetDiaryById(ctx, &diary, userId, diaryId); err != nil {
+		return model.DiaryResponse{}, notFound(err, ErrDiaryNotFound)
 	}
+	// 音楽は非同期に生成されるため、まだ存在しない場合がある
+	musicData := []model.MusicData{}
+	for _, music := range diary.Music {
+		musicData = append(musicData, model.ToMusicData(music))
+	}
+	linkMusicData(du.as, musicData)
 	resDiary := model.DiaryResponse{
-		ID:      diary.ID,
-		Content: diary.Content,
-		MusicData: []model.MusicData{
-			{
-				AudioFile: diary.Music[0].AudioFile,
-				ImageFile: diary.Music[0].ImageFile,
-				ItemUUID:  diary.Music[0].ItemUUID,
-				Title:     diary.Music[0].Title,
-				Lyric:     diary.Music[0].Lyrics,
-				Tags:      diary.Music[0].Tags,
-			},
-		},
+		ID:        diary.ID,
+		Content:   diary.Content,
+		MusicData: musicData,
 		CreatedAt: diary.CreatedAt,
 		UpdatedAt: diary.UpdatedAt,
 	}
 	return resDiary, nil
 }
 
-func (du *diaryUsecase) GetDiaryDates(userId uint, year, month string) (*model.DiaryDateCountResponse, error) {
+func (du *diaryUsecase) GetDiaryDates(ctx context.Context, userId uint, year, month string) (*model.DiaryDateCountResponse, error) {
 	y, _ := strconv.Atoi(year)
 	m, _ := strconv.Atoi(month)
 
-	data, err := du.dr.GetDiaryDates(userId, y, m)
+	data, err := du.dr.GetDiaryDates(ctx, userId, y, m)
 	if err != nil {
 		return nil, err
 	}
@@ -83,14 +99,14 @@ func (du *diaryUsecase) GetDiaryDates(userId uint, year, month string) (*model.D
 	}, nil
 }
 
-func (du *diaryUsecase) CreateDiary(diary model.Diary) (model.DiaryResponse, error) {
+func (du *diaryUsecase) CreateDiary(ctx context.Context, diary model.Diary) (model.DiaryResponse, error) {
 	// Validate the diary
 	if err := du.dv.DiaryValidate(diary); err != nil {
-		return model.DiaryResponse{}, err
+		return model.DiaryResponse{}, apperror.Validation(err)
 	}
 	// Create the diary
-	if err := du.dr.CreateDiary(&diary); err != nil {
-		return model.DiaryResponse{}, err
+	if err := du.dr.CreateDiary(ctx, &diary); err != nil {
+		return model.DiaryResponse{}, deletionPending(err)
 	}
 	resDiary := model.DiaryResponse{
 		ID:        diary.ID,
@@ -98,16 +114,17 @@ func (du *diaryUsecase) CreateDiary(diary model.Diary) (model.DiaryResponse, err
 		CreatedAt: diary.CreatedAt,
 		UpdatedAt: diary.UpdatedAt,
 	}
+	du.publishDiaryEvent(ctx, model.EventDiaryCreated, diary.UserId, diary.ID, &resDiary)
 	return resDiary, nil
 }
 
-func (du *diaryUsecase) UpdateDiary(userId uint, diaryId uint, diary model.Diary) (model.DiaryResponse, error) {
+func (du *diaryUsecase) UpdateDiary(ctx context.Context, userId uint, diaryId uint, diary model.Diary) (model.DiaryResponse, error) {
 	if err := du.dv.DiaryValidate(diary); err != nil {
-		return model.DiaryResponse{}, err
+		return model.DiaryResponse{}, apperror.Validation(err)
 	}
 
-	if err := du.dr.UpdateDiary(&diary, userId, diaryId); err != nil {
-		return model.DiaryResponse{}, err
+	if err := du.dr.UpdateDiary(ctx, &diary, userId, diaryId); err != nil {
+		return model.DiaryResponse{}, notFound(err, ErrDiaryNotFound)
 	}
 	resDiary := model.DiaryResponse{
 		ID:        diary.ID,
@@ -115,20 +132,90 @@ func (du *diaryUsecase) UpdateDiary(userId uint, diaryId uint, diary model.Diary
 		CreatedAt: diary.CreatedAt,
 		UpdatedAt: diary.UpdatedAt,
 	}
+	du.publishDiaryEvent(ctx, model.EventDiaryUpdated, userId, diaryId, &resDiary)
 	return resDiary, nil
 }
 
-func (dr *diaryUsecase) DeleteDiary(userId uint, diaryId uint) error {
-	if err := dr.dr.DeleteDiary(userId, diaryId); err != nil {
-		return err
+func (dr *diaryUsecase) DeleteDiary(ctx context.Context, userId uint, diaryId uint) error {
+	keys, err := dr.dr.DeleteDiary(ctx, userId, diaryId)
+	if err != nil {
+		return notFound(err, ErrDiaryNotFound)
+	}
+	// 曲の音声・カバー画像も削除する。失敗したファイルはアカウントの削除時に残らず削除される
+	for _, key := range keys {
+		if err := dr.st.Delete(context.WithoutCancel(ctx), key); err != nil {
+			slog.WarnContext(ctx, "failed to delete music asset", "diary_id", diaryId, "key", key, "error", err)
+		}
 	}
+	dr.publishDiaryEvent(ctx, model.EventDiaryDeleted, userId, diaryId, nil)
 	return nil
 }
 
-func (du *diaryUsecase) CreateDiaryWithMusic(diary *model.Diary) (*model.DiaryResponse, error) {
-	musicReq := &model.MusicRequest{
-		IsAuto: 1,
-		Prompt: diary.Content,
+func (du *diaryUsecase) CreateDiaryWithMusic(ctx context.Context, diary *model.Diary) (*model.DiaryResponse, error) {
+	if err := du.dv.DiaryValidate(*diary); err != nil {
+		return nil, apperror.Validation(err)
+	}
+	// 上限に達している・メールアドレスを確認していない場合も書いた日記は失わないよう、
+	// 日記のみ保存して生成しなかった理由を返す
+	if err := du.qu.CheckQuota(ctx, diary.UserId); err != nil {
+		skip := musicSkip(err)
+		if skip == nil {
+			return nil, err
+		}
+		return du.createDiaryWithoutMusic(ctx, diary, skip)
+	}
+	// 本文をそのまま送らず、気分やキーワードから組み立てたプロンプトを使う
+	built := du.pb.Build(diary.Content)
+	job := &model.MusicJob{
+		IsAuto:     1,
+		Prompt:     built.Text,
+		PromptTags: built.Tags(),
+	}
+	// 日記の保存と生成ジョブの登録のみ行い、音楽の生成はワーカーに任せる
+	diaryRes, err := du.dr.CreateDiaryWithMusicJob(ctx, diary, job)
+	if err != nil {
+		return nil, deletionPending(err)
+	}
+	du.jn.Notify()
+	du.publishDiaryEvent(ctx, model.EventDiaryCreated, diary.UserId, diary.ID, diaryRes)
+	publishMusicEvent(ctx, du.ep, model.EventMusicQueued, job, nil)
+	return diaryRes, nil
+}
+
+// createDiaryWithoutMusic は日記のみ保存する（音楽は後から POST /diaries/:diaryId/musics で生成できる）
+func (du *diaryUsecase) createDiaryWithoutMusic(ctx context.Context, diary *model.Diary, skip *model.MusicSkip) (*model.DiaryResponse, error) {
+	if err := du.dr.CreateDiary(ctx, diary); err != nil {
+		return nil, deletionPending(err)
+	}
+	diaryRes := &model.DiaryResponse{
+		ID:        diary.ID,
+		Content:   diary.Content,
+		MusicData: []model.MusicData{},
+		MusicSkip: skip,
+		CreatedAt: diary.CreatedAt,
+		UpdatedAt: diary.UpdatedAt,
+	}
+	du.publishDiaryEvent(ctx, model.EventDiaryCreated, diary.UserId, diary.ID, diaryRes)
+	return diaryRes, nil
+}
+
+// musicSkip は音楽を生成できない理由をレスポンスの形にする（日記の保存を止めるべきエラーの場合は nil）
+func musicSkip(err error) *model.MusicSkip {
+	var quotaErr *QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		return &model.MusicSkip{Code: "quota_exceeded", Detail: quotaErr.Error(), ResetsAt: &quotaErr.ResetsAt}
+	}
+	// メールアドレスを確認していない場合も日記は保存する（確認後に生成できる）
+	if errors.Is(err, ErrEmailNotVerified) {
+		return &model.MusicSkip{Code: ErrEmailNotVerified.Code, Detail: ErrEmailNotVerified.Message}
 	}
-	return du.dr.CreateDiaryWithMusic(diary, musicReq)
+	return nil
+}
+
+// publishDiaryEvent は日記の作成・更新・削除を通知する（削除時は diary を nil にする）
+func (du *diaryUsecase) publishDiaryEvent(ctx context.Context, eventType string, userId uint, diaryId uint, diary *model.DiaryResponse) {
+	du.ep.Publish(ctx, userId, eventType, model.DiaryEvent{
+		DiaryID: diaryId,
+		Diary:   diary,
+	})
 }
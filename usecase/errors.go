@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/service"
+	"gorm.io/gorm"
+)
+
+// クライアントに返すエラー（Code はフロントエンドが分岐に使うので変更しない）
+var (
+	ErrDiaryNotFound            = apperror.NotFound("diary_not_found", "Diary not found")
+	ErrMusicNotFound            = apperror.NotFound("music_not_found", "Music not found")
+	ErrMusicJobNotFound         = apperror.NotFound("music_job_not_found", "Music job not found")
+	ErrMusicAssetNotFound       = apperror.NotFound("music_asset_not_found", "Music file not found")
+	ErrWebhookNotFound          = apperror.NotFound("webhook_not_found", "Webhook not found")
+	ErrWebhookDeliveryNotFound  = apperror.NotFound("webhook_delivery_not_found", "Webhook delivery not found")
+	ErrUserNotFound             = apperror.NotFound("user_not_found", "User not found")
+	ErrSessionNotFound          = apperror.NotFound("session_not_found", "Session not found")
+	ErrInvalidCredentials       = apperror.Unauthorized("invalid_credentials", "Email or password is incorrect")
+	ErrEmailTaken               = apperror.Conflict("email_taken", "Email is already registered")
+	ErrInvalidPassword          = apperror.Forbidden("invalid_password", "Current password is incorrect")
+	ErrInvalidRefreshToken      = apperror.Unauthorized("invalid_refresh_token", "Refresh token is invalid or expired")
+	ErrRefreshTokenReused       = apperror.Unauthorized("refresh_token_reused", "Refresh token has already been used; the session has been revoked")
+	ErrSessionRevoked           = apperror.Unauthorized("session_revoked", "Session has been revoked or expired")
+	ErrInvalidToken             = apperror.BadRequest("invalid_token", "Token is invalid, expired or already used")
+	ErrEmailAlreadyVerified     = apperror.Conflict("email_already_verified", "Email is already verified")
+	ErrEmailNotVerified         = apperror.Forbidden("email_not_verified", "Verify your email address before generating music")
+	ErrDeletionAlreadyRequested = apperror.Conflict("deletion_already_requested", "Account deletion is already scheduled")
+	ErrDeletionNotFound         = apperror.NotFound("deletion_not_found", "No cancellable account deletion is scheduled")
+	ErrDeletionPending          = apperror.Conflict("deletion_pending", "Account deletion is scheduled; cancel it to add diaries, music or exports")
+	ErrExportNotFound           = apperror.NotFound("export_not_found", "Export not found")
+	ErrInvalidPrompt            = apperror.Unprocessable("invalid_prompt", "The music provider rejected the prompt")
+	ErrProviderRateLimited      = apperror.TooManyRequests("music_provider_rate_limited", "The music provider is rate limited; try again later")
+	ErrProviderUnavailable      = apperror.Unavailable("music_provider_unavailable", "The music provider is temporarily unavailable")
+	ErrProviderFailure          = apperror.UpstreamFailure("music_provider_error", "The music provider failed", nil)
+)
+
+// ProviderError は音楽生成プロバイダーのエラー (service.ErrProvider*) をクライアントに返すエラーにする。
+// プロバイダーのエラーでない場合は nil を返す
+func ProviderError(err error) *apperror.Error {
+	switch {
+	case errors.Is(err, service.ErrProviderQuotaExceeded):
+		return ErrProviderRateLimited.Wrap(err)
+	case errors.Is(err, service.ErrProviderBadPrompt):
+		return ErrInvalidPrompt.Wrap(err)
+	case errors.Is(err, service.ErrProviderAuthFailed):
+		// サーバー側のAPIキーの問題なのでクライアントには上流の失敗として返す
+		return ErrProviderFailure.Wrap(err)
+	case errors.Is(err, service.ErrProviderUnavailable):
+		return ErrProviderUnavailable.Wrap(err)
+	}
+	return nil
+}
+
+// notFound はレコードが見つからなかった場合に nf に置き換える（それ以外のエラーはそのまま返す）
+func notFound(err error, nf *apperror.Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nf.Wrap(err)
+	}
+	return err
+}
+
+// deletionPending はアカウントの削除が予定されていて登録できなかった場合に ErrDeletionPending に置き換える
+func deletionPending(err error) error {
+	if errors.Is(err, repository.ErrAccountDeletionScheduled) {
+		return ErrDeletionPending.Wrap(err)
+	}
+	return err
+}
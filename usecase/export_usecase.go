@@ -0,0 +1,296 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/export"
+	"github.com/kenta-kenta/diary-music/logging"
+	"github.com/kenta-kenta/diary-music/mail"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/storage"
+	"github.com/kenta-kenta/diary-music/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+const (
+	// 作成中のエクスポートの updated_at を進める間隔
+	exportHeartbeat = time.Minute
+	// updated_at がこれより古い running のエクスポートは、プロセスが止まったとみなして作り直す。
+	// 大きなアーカイブで時間がかかってもハートビートで更新され続ける
+	exportStaleAfter = 10 * time.Minute
+	// 失敗した場合に作成し直す回数の上限
+	exportMaxAttempts = 3
+	// GetExports で返す件数
+	exportHistoryLimit = 10
+)
+
+// errExportStorage はアーカイブをストレージに保存できなかったことを表す
+var errExportStorage = errors.New("export storage unavailable")
+
+// IExportUsecase は個人データのエクスポート（ダウンロードできるアーカイブの作成）を行う
+type IExportUsecase interface {
+	// RequestExport はエクスポートを受け付ける。作成中のエクスポートがある場合はそれを返す
+	RequestExport(ctx context.Context, userId uint) (model.UserExportResponse, error)
+	// GetExports は直近のエクスポートを新しい順に返す
+	GetExports(ctx context.Context, userId uint) ([]model.UserExportResponse, error)
+	GetExportById(ctx context.Context, userId uint, exportId uint) (model.UserExportResponse, error)
+	// ProcessNextExport は作成待ちのエクスポートを1件処理する。
+	// ない場合は保存期間を過ぎたアーカイブを1件削除する（ワーカーから呼び出す）
+	ProcessNextExport(ctx context.Context) (bool, error)
+}
+
+type exportUsecase struct {
+	ur     repository.IUserRepository
+	er     repository.IExportRepository
+	st     storage.IStorage
+	as     IAssetURLSigner
+	jn     IJobNotifier
+	mailer mail.IMailer
+	cfg    config.ExportConfig
+}
+
+// as にはダウンロードのリンクの有効期間 (EXPORT_LINK_TTL) で署名する URLSigner を渡す
+// jn は受け付けたエクスポートをすぐに作成するようワーカーに知らせる
+func NewExportUsecase(ur repository.IUserRepository, er repository.IExportRepository, st storage.IStorage, as IAssetURLSigner, jn IJobNotifier, mailer mail.IMailer, cfg config.ExportConfig) IExportUsecase {
+	return &exportUsecase{ur, er, st, as, jn, mailer, cfg}
+}
+
+func (eu *exportUsecase) RequestExport(ctx context.Context, userId uint) (model.UserExportResponse, error) {
+	exp := model.UserExport{UserID: userId, Status: model.ExportStatusQueued}
+	err := eu.er.CreateExport(ctx, &exp)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// 同時に作成するのは1件だけにする
+		exp = model.UserExport{}
+		if err := eu.er.GetActiveExport(ctx, &exp, userId); err != nil {
+			return model.UserExportResponse{}, err
+		}
+		return eu.exportResponse(exp, time.Now()), nil
+	}
+	if err != nil {
+		return model.UserExportResponse{}, deletionPending(err)
+	}
+	eu.jn.Notify()
+	slog.InfoContext(ctx, "export requested", "export_id", exp.ID)
+	return eu.exportResponse(exp, time.Now()), nil
+}
+
+func (eu *exportUsecase) GetExports(ctx context.Context, userId uint) ([]model.UserExportResponse, error) {
+	exports, err := eu.er.GetExports(ctx, userId, exportHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	res := make([]model.UserExportResponse, 0, len(exports))
+	for _, exp := range exports {
+		res = append(res, eu.exportResponse(exp, now))
+	}
+	return res, nil
+}
+
+func (eu *exportUsecase) GetExportById(ctx context.Context, userId uint, exportId uint) (model.UserExportResponse, error) {
+	exp := model.UserExport{}
+	if err := eu.er.GetExportById(ctx, &exp, userId, exportId); err != nil {
+		return model.UserExportResponse{}, notFound(err, ErrExportNotFound)
+	}
+	return eu.exportResponse(exp, time.Now()), nil
+}
+
+func (eu *exportUsecase) ProcessNextExport(ctx context.Context) (bool, error) {
+	exp, err := eu.er.ClaimNextExport(ctx, exportStaleAfter)
+	if err != nil {
+		return false, err
+	}
+	if exp == nil {
+		return eu.expireNextExport(ctx)
+	}
+	ctx = logging.WithUserID(ctx, exp.UserID)
+	ctx, span := tracing.Start(ctx, "ExportUsecase.BuildExport",
+		attribute.Int64("exp.id", int64(exp.ID)),
+		attribute.Int("exp.attempt", exp.Attempts),
+	)
+	stop := heartbeat(ctx, exportHeartbeat, func(ctx context.Context) error {
+		return eu.er.TouchExport(ctx, exp)
+	})
+	err = eu.build(ctx, exp)
+	stop()
+	tracing.End(span, err)
+	if err != nil && ctx.Err() != nil {
+		// 停止のため中断した。次に起動したワーカーが最初からやり直す
+		slog.WarnContext(ctx, "export interrupted", "export_id", exp.ID, "error", err)
+		if rerr := eu.er.RequeueExport(context.WithoutCancel(ctx), exp); rerr != nil {
+			return true, rerr
+		}
+		return true, nil
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "export failed", "export_id", exp.ID, "attempt", exp.Attempts, "error", err)
+		// 上限に達するまでは作成待ちに戻して作り直す
+		exp.Status = model.ExportStatusQueued
+		if exp.Attempts >= exportMaxAttempts {
+			exp.Status = model.ExportStatusFailed
+			now := time.Now()
+			exp.FinishedAt = &now
+		}
+		// 一時ファイルやストレージのパスを返さないよう、エラーの内容はログのみに出力してコードを記録する
+		exp.Error = exportErrorCode(err)
+		if uerr := eu.er.UpdateExport(context.WithoutCancel(ctx), exp); uerr != nil {
+			slog.ErrorContext(ctx, "failed to record export failure", "export_id", exp.ID, "error", uerr)
+		}
+		return true, fmt.Errorf("build export %d: %w", exp.ID, err)
+	}
+	slog.InfoContext(ctx, "export finished",
+		"export_id", exp.ID,
+		"size", exp.Size,
+		"diaries", exp.Diaries,
+		"musics", exp.Musics,
+		"files", exp.Files,
+	)
+	eu.sendReady(ctx, exp)
+	return true, nil
+}
+
+// build はアーカイブを一時ファイルに作成してからストレージに保存する
+func (eu *exportUsecase) build(ctx context.Context, exp *model.UserExport) error {
+	user := model.User{}
+	if err := eu.ur.GetUserById(ctx, &user, exp.UserID); err != nil {
+		return err
+	}
+	diaries, err := eu.er.GetExportDiaries(ctx, exp.UserID)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "diary-music-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	now := time.Now()
+	stats, err := export.Write(ctx, f, user, diaries, eu.openAsset, now)
+	if err != nil {
+		return err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("users/%d/exports/%d/diary-music-export-%s.zip", exp.UserID, exp.ID, now.Format("20060102"))
+	if err := eu.st.Put(ctx, key, f, size, "application/zip"); err != nil {
+		return fmt.Errorf("put export archive: %w: %w", errExportStorage, err)
+	}
+
+	finishedAt := time.Now()
+	expiresAt := finishedAt.Add(eu.cfg.Retention)
+	done := *exp
+	done.Status = model.ExportStatusSucceeded
+	done.StorageKey = key
+	done.Size = size
+	done.Diaries = stats.Diaries
+	done.Musics = stats.Musics
+	done.Files = stats.Files
+	done.Error = ""
+	done.FinishedAt = &finishedAt
+	done.ExpiresAt = &expiresAt
+	if err := eu.er.UpdateExport(context.WithoutCancel(ctx), &done); err != nil {
+		// DBから辿れないアーカイブを残さない
+		if derr := eu.st.Delete(context.WithoutCancel(ctx), key); derr != nil {
+			slog.ErrorContext(ctx, "failed to delete orphaned export archive", "export_id", exp.ID, "key", key, "error", derr)
+		}
+		return err
+	}
+	*exp = done
+	return nil
+}
+
+// exportErrorCode はエクスポートの失敗の理由をクライアントに返すコードにする
+func exportErrorCode(err error) string {
+	if errors.Is(err, errExportStorage) {
+		return "storage_unavailable"
+	}
+	return "internal_server_error"
+}
+
+func (eu *exportUsecase) openAsset(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, _, err := eu.st.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// sendReady はダウンロードのリンクをメールで送る（送れなくても API から取得できるのでログのみ）
+func (eu *exportUsecase) sendReady(ctx context.Context, exp *model.UserExport) {
+	user := model.User{}
+	if err := eu.ur.GetUserById(ctx, &user, exp.UserID); err != nil {
+		slog.WarnContext(ctx, "failed to load user for export mail", "error", err)
+		return
+	}
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "データのエクスポートが完了しました",
+		Text: fmt.Sprintf("%s さん\n\n"+
+			"日記と曲のデータをまとめたアーカイブを作成しました。次のリンクからダウンロードできます（リンクの有効期限は %s です）。\n\n%s\n\n"+
+			"リンクの期限が切れた場合は、アプリのエクスポートの画面から新しいリンクを取得できます。\n"+
+			"アーカイブは %s に削除されます。\n",
+			user.UserName, formatTTL(eu.cfg.LinkTTL), eu.as.SignedURL(exp.StorageKey),
+			exp.ExpiresAt.Format("2006-01-02 15:04 MST")),
+	}
+	if err := eu.mailer.Send(ctx, msg); err != nil {
+		slog.WarnContext(ctx, "failed to send export mail", "export_id", exp.ID, "error", err)
+	}
+}
+
+// expireNextExport は保存期間を過ぎたアーカイブを1件ストレージから削除する
+func (eu *exportUsecase) expireNextExport(ctx context.Context) (bool, error) {
+	exp, err := eu.er.GetExpiredExport(ctx, time.Now())
+	if err != nil || exp == nil {
+		return false, err
+	}
+	if err := eu.st.Delete(ctx, exp.StorageKey); err != nil {
+		return false, fmt.Errorf("delete export archive %d: %w", exp.ID, err)
+	}
+	exp.Status = model.ExportStatusExpired
+	exp.StorageKey = ""
+	if err := eu.er.UpdateExport(ctx, exp); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "export expired", "export_id", exp.ID, "user_id", exp.UserID)
+	return true, nil
+}
+
+// exportResponse はエクスポートをレスポンスにする。ダウンロードできる場合は署名付きURLを付ける
+func (eu *exportUsecase) exportResponse(exp model.UserExport, now time.Time) model.UserExportResponse {
+	res := model.UserExportResponse{
+		ID:         exp.ID,
+		Status:     exp.Status,
+		Size:       exp.Size,
+		Diaries:    exp.Diaries,
+		Musics:     exp.Musics,
+		Files:      exp.Files,
+		ExpiresAt:  exp.ExpiresAt,
+		FinishedAt: exp.FinishedAt,
+		CreatedAt:  exp.CreatedAt,
+	}
+	if exp.Status == model.ExportStatusSucceeded && exp.StorageKey != "" && exp.ExpiresAt != nil && now.Before(*exp.ExpiresAt) {
+		res.DownloadURL = eu.as.SignedURL(exp.StorageKey)
+	}
+	// 作り直している間は前回のエラーを返さない
+	if exp.Status == model.ExportStatusFailed {
+		res.Error = exp.Error
+	}
+	return res
+}
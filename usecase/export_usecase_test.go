@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/storage"
+)
+
+// fakeExportRepository は claimed を1回だけ取り出させ、更新を記録する
+type fakeExportRepository struct {
+	repository.IExportRepository
+	claimed   *model.UserExport
+	created   bool
+	updated   []model.UserExport
+	requeued  []model.UserExport
+	createErr error
+}
+
+func (r *fakeExportRepository) CreateExport(ctx context.Context, export *model.UserExport) error {
+	if r.createErr != nil {
+		return r.createErr
+	}
+	r.created = true
+	export.ID = 1
+	return nil
+}
+
+func (r *fakeExportRepository) ClaimNextExport(ctx context.Context, staleAfter time.Duration) (*model.UserExport, error) {
+	exp := r.claimed
+	r.claimed = nil
+	return exp, nil
+}
+
+func (r *fakeExportRepository) GetExportDiaries(ctx context.Context, userId uint) ([]model.Diary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []model.Diary{{ID: 3, UserId: userId, Content: "今日"}}, nil
+}
+
+func (r *fakeExportRepository) TouchExport(ctx context.Context, export *model.UserExport) error {
+	return nil
+}
+
+func (r *fakeExportRepository) UpdateExport(ctx context.Context, export *model.UserExport) error {
+	r.updated = append(r.updated, *export)
+	return nil
+}
+
+func (r *fakeExportRepository) RequeueExport(ctx context.Context, export *model.UserExport) error {
+	export.Status = model.ExportStatusQueued
+	export.Attempts--
+	r.requeued = append(r.requeued, *export)
+	return nil
+}
+
+// failingStorage は内部のパスを含むエラーで保存に失敗する
+type failingStorage struct {
+	storage.IStorage
+}
+
+func (failingStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	return errors.New("open /var/lib/diary-music/tmp/" + key + ": no space left on device")
+}
+
+type countingNotifier struct{ n int }
+
+func (c *countingNotifier) Notify() { c.n++ }
+
+type fakeSigner struct{}
+
+func (fakeSigner) SignedURL(key string) string { return "https://assets.example.com/" + key }
+
+func newTestExportUsecase(er repository.IExportRepository, st storage.IStorage, jn IJobNotifier) IExportUsecase {
+	return NewExportUsecase(&fakeQuotaUserRepository{user: model.User{ID: 1, UserName: "taro"}}, er, st, fakeSigner{}, jn, nil,
+		config.ExportConfig{LinkTTL: time.Hour, Retention: time.Hour})
+}
+
+func TestRequestExportNotifiesWorker(t *testing.T) {
+	er := &fakeExportRepository{}
+	jn := &countingNotifier{}
+	if _, err := newTestExportUsecase(er, nil, jn).RequestExport(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if !er.created || jn.n != 1 {
+		t.Fatalf("created = %v, notified %d times, want the worker notified once", er.created, jn.n)
+	}
+}
+
+func TestRequestExportWhileDeletionPending(t *testing.T) {
+	er := &fakeExportRepository{createErr: repository.ErrAccountDeletionScheduled}
+	jn := &countingNotifier{}
+	_, err := newTestExportUsecase(er, nil, jn).RequestExport(context.Background(), 1)
+	if !errors.Is(err, ErrDeletionPending) {
+		t.Fatalf("err = %v, want ErrDeletionPending", err)
+	}
+	if jn.n != 0 {
+		t.Errorf("notified %d times, want 0", jn.n)
+	}
+}
+
+func TestProcessNextExportStoresErrorCode(t *testing.T) {
+	er := &fakeExportRepository{claimed: &model.UserExport{ID: 4, UserID: 1, Status: model.ExportStatusRunning, Attempts: exportMaxAttempts}}
+
+	processed, err := newTestExportUsecase(er, failingStorage{}, &countingNotifier{}).ProcessNextExport(context.Background())
+	if !processed || err == nil {
+		t.Fatalf("ProcessNextExport() = %v, %v, want a processed failure", processed, err)
+	}
+	if len(er.updated) != 1 {
+		t.Fatalf("updated %d times, want 1", len(er.updated))
+	}
+	got := er.updated[0]
+	if got.Status != model.ExportStatusFailed || got.Error != "storage_unavailable" {
+		t.Fatalf("export = %s %q, want failed with storage_unavailable", got.Status, got.Error)
+	}
+	if strings.Contains(got.Error, "/") {
+		t.Errorf("error leaks a path: %q", got.Error)
+	}
+}
+
+func TestProcessNextExportRequeuesWhenInterrupted(t *testing.T) {
+	er := &fakeExportRepository{claimed: &model.UserExport{ID: 4, UserID: 1, Status: model.ExportStatusRunning, Attempts: exportMaxAttempts}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	processed, err := newTestExportUsecase(er, failingStorage{}, &countingNotifier{}).ProcessNextExport(ctx)
+	if !processed || err != nil {
+		t.Fatalf("ProcessNextExport() = %v, %v, want processed without error", processed, err)
+	}
+	if len(er.updated) != 0 {
+		t.Fatalf("export updated %+v, want only a requeue", er.updated)
+	}
+	if len(er.requeued) != 1 || er.requeued[0].Status != model.ExportStatusQueued || er.requeued[0].Attempts != exportMaxAttempts-1 {
+		t.Fatalf("requeued = %+v, want queued without counting the attempt", er.requeued)
+	}
+}
+
+func TestExportErrorCode(t *testing.T) {
+	if code := exportErrorCode(fmt.Errorf("put export archive: %w: %w", errExportStorage, errors.New("s3: 503"))); code != "storage_unavailable" {
+		t.Errorf("storage failure code = %q", code)
+	}
+	if code := exportErrorCode(errors.New(`pq: relation "diaries" does not exist`)); code != "internal_server_error" {
+		t.Errorf("database failure code = %q", code)
+	}
+}
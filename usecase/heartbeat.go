@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// heartbeat は処理中であることを interval ごとに touch で記録する。
+// 止まったワーカーの処理だけを取り出し直せるよう、長く続く処理の間も更新時刻を進める。
+// 返した関数を呼ぶと止まる（止まるまで待つ）
+func heartbeat(ctx context.Context, interval time.Duration, touch func(ctx context.Context) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := touch(ctx); err != nil && ctx.Err() == nil {
+					slog.WarnContext(ctx, "failed to record heartbeat", "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
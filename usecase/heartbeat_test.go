@@ -0,0 +1,43 @@
+package usecase
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var touched atomic.Int32
+	stop := heartbeat(context.Background(), 5*time.Millisecond, func(context.Context) error {
+		touched.Add(1)
+		return nil
+	})
+	time.Sleep(30 * time.Millisecond)
+	stop()
+	n := touched.Load()
+	if n < 2 {
+		t.Fatalf("touched %d times, want at least 2", n)
+	}
+	// 止めた後は記録しない
+	time.Sleep(20 * time.Millisecond)
+	if after := touched.Load(); after != n {
+		t.Errorf("touched %d times after stop", after-n)
+	}
+}
+
+func TestHeartbeatStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := heartbeat(ctx, time.Hour, func(context.Context) error { return nil })
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stop() did not return after the context was cancelled")
+	}
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/logging"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/service"
+	"github.com/kenta-kenta/diary-music/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// プロバイダー障害で延期できる最大回数（これを超えると failed にする）
+	maxMusicJobAttempts = 5
+	// 延期する際の最短の待ち時間
+	minMusicJobDeferral = 30 * time.Second
+	// 処理中のジョブの updated_at を進める間隔。musicJobStaleAfter より十分短くする
+	musicJobHeartbeat = time.Minute
+	// updated_at がこれより古い running のジョブは、ワーカーが止まったとみなして取り出し直す。
+	// 生成に時間がかかっても（タイムアウト 3分 × リトライ 3回 + 待ち時間 + 複製）ハートビートで更新され続ける
+	musicJobStaleAfter = 10 * time.Minute
+)
+
+// IJobNotifier は新しいジョブが登録されたことをワーカーに知らせる
+type IJobNotifier interface {
+	Notify()
+}
+
+// IEventPublisher はユーザーにイベントを通知する
+type IEventPublisher interface {
+	Publish(ctx context.Context, userId uint, eventType string, data interface{})
+}
+
+// publishMusicEvent はジョブの状態を音楽イベントとして通知する
+func publishMusicEvent(ctx context.Context, ep IEventPublisher, eventType string, job *model.MusicJob, musicData []model.MusicData) {
+	if musicData == nil {
+		musicData = []model.MusicData{}
+	}
+	ep.Publish(ctx, job.UserID, eventType, model.MusicEvent{
+		DiaryID:   job.DiaryID,
+		JobID:     job.ID,
+		Status:    job.Status,
+		Error:     job.Error,
+		MusicData: musicData,
+	})
+}
+
+type IMusicJobUsecase interface {
+	// ProcessNextJob は待機中のジョブを1件処理する。処理するジョブがなかった場合は false を返す
+	ProcessNextJob(ctx context.Context) (bool, error)
+}
+
+type musicJobUsecase struct {
+	jr         repository.IMusicJobRepository
+	ms         service.IMusicService
+	am         service.IAssetMirror
+	cb         *service.CircuitBreaker
+	qu         IQuotaUsecase
+	ep         IEventPublisher
+	as         IAssetURLSigner
+	staleAfter time.Duration
+}
+
+// cb はプロバイダーのブレーカー。ブレーカーが開いている場合は閉じる見込みの時刻まで再実行を延期する
+// qu はプロバイダーを呼び出す前に上限を確認し、結果を使用量台帳に記録する
+func NewMusicJobUsecase(jr repository.IMusicJobRepository, ms service.IMusicService, am service.IAssetMirror, cb *service.CircuitBreaker, qu IQuotaUsecase, ep IEventPublisher, as IAssetURLSigner) IMusicJobUsecase {
+	return &musicJobUsecase{jr, ms, am, cb, qu, ep, as, musicJobStaleAfter}
+}
+
+func (ju *musicJobUsecase) ProcessNextJob(ctx context.Context) (bool, error) {
+	job, err := ju.jr.ClaimNextJob(ctx, ju.staleAfter)
+	if err != nil {
+		return false, err
+	}
+	if job == nil {
+		return false, nil
+	}
+	ctx = logging.WithUserID(ctx, job.UserID)
+	ctx, span := tracing.Start(ctx, "MusicJobUsecase.ProcessJob",
+		attribute.Int64("music_job.id", int64(job.ID)),
+		attribute.Int("music_job.attempt", job.Attempts),
+	)
+	stop := heartbeat(ctx, musicJobHeartbeat, func(ctx context.Context) error {
+		return ju.jr.TouchJob(ctx, job)
+	})
+	err = ju.processJob(ctx, job)
+	stop()
+	tracing.End(span, err)
+	return true, err
+}
+
+// processJob は取り出したジョブの音楽を生成して結果を保存する
+func (ju *musicJobUsecase) processJob(ctx context.Context, job *model.MusicJob) error {
+	// 停止のため中断しても結果を記録できるよう、DBへの書き込みにはキャンセルされない context を使う
+	dbCtx := context.WithoutCancel(ctx)
+
+	// 上限に達していればプロバイダーを呼び出さずに失敗させる
+	usage, err := ju.qu.Reserve(ctx, job)
+	if err != nil {
+		slog.WarnContext(ctx, "music job not started", "job_id", job.ID, "error", err)
+		return ju.fail(ctx, job, err)
+	}
+
+	// 音楽を生成
+	req := &model.MusicRequest{
+		IsAuto:       job.IsAuto,
+		Prompt:       job.Prompt,
+		Lyrics:       job.Lyrics,
+		Title:        job.Title,
+		Instrumental: job.Instrumental,
+	}
+	musics, err := ju.ms.CreateMusic(ctx, req)
+	if serr := ju.qu.Settle(dbCtx, usage, err); serr != nil {
+		slog.ErrorContext(ctx, "failed to record music usage", "job_id", job.ID, "error", serr)
+	}
+	if err != nil && ctx.Err() != nil {
+		// 停止のため中断した。次に起動したワーカーが最初からやり直す
+		slog.WarnContext(ctx, "music job interrupted", "job_id", job.ID, "error", err)
+		return ju.jr.RequeueJob(dbCtx, job)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "music job failed", "job_id", job.ID, "attempt", job.Attempts, "error", err)
+		// プロバイダーの障害なら日記はそのままにして後で再実行する
+		if errors.Is(err, service.ErrProviderUnavailable) && job.Attempts < maxMusicJobAttempts {
+			if derr := ju.jr.DeferJob(dbCtx, job, ju.nextRunAt(job, err), jobErrorCode(err)); derr != nil {
+				return derr
+			}
+			publishMusicEvent(dbCtx, ju.ep, model.EventMusicPending, job, nil)
+			return nil
+		}
+		return ju.fail(dbCtx, job, err)
+	}
+
+	// プロバイダーのURLが失効しても再生できるよう自前のストレージに複製する。
+	// 失敗してもプロバイダーのURLは使えるのでジョブは失敗させない
+	for i := range musics {
+		musics[i].UserID = job.UserID
+		musics[i].DiaryID = job.DiaryID
+		if err := ju.am.MirrorMusic(ctx, &musics[i]); err != nil {
+			slog.WarnContext(ctx, "failed to mirror music assets", "job_id", job.ID, "error", err)
+		}
+	}
+
+	// 音楽を保存してジョブを完了
+	if err := ju.jr.CompleteJob(dbCtx, job, musics); err != nil {
+		if errors.Is(err, repository.ErrJobNotRunning) {
+			// 生成中に日記が削除された。複製したファイルが残らないよう削除する
+			slog.InfoContext(ctx, "music job discarded", "job_id", job.ID)
+			for i := range musics {
+				if derr := ju.am.DeleteMusic(dbCtx, &musics[i]); derr != nil {
+					slog.ErrorContext(ctx, "failed to delete mirrored music assets", "job_id", job.ID, "error", derr)
+				}
+			}
+			return nil
+		}
+		if ferr := ju.fail(dbCtx, job, err); ferr != nil {
+			return ferr
+		}
+		return err
+	}
+	slog.InfoContext(ctx, "music job completed", "job_id", job.ID, "songs", len(musics))
+
+	musicData := make([]model.MusicData, 0, len(musics))
+	for _, music := range musics {
+		musicData = append(musicData, model.ToMusicData(music))
+	}
+	linkMusicData(ju.as, musicData)
+	publishMusicEvent(dbCtx, ju.ep, model.EventMusicReady, job, musicData)
+	return nil
+}
+
+// fail はジョブを失敗にする。cause の内容は呼び出し元でログに出力し、ジョブにはコードのみ記録する
+func (ju *musicJobUsecase) fail(ctx context.Context, job *model.MusicJob, cause error) error {
+	if err := ju.jr.FailJob(ctx, job, jobErrorCode(cause)); err != nil {
+		return err
+	}
+	publishMusicEvent(ctx, ju.ep, model.EventMusicFailed, job, nil)
+	return nil
+}
+
+// jobErrorCode はジョブの失敗の理由を、ステータスAPI・SSE・Webhook でクライアントに返すコードにする。
+// プロバイダーのレスポンスなどエラーの内容は返さない
+func jobErrorCode(err error) string {
+	if errors.Is(err, ErrQuotaExceeded) {
+		return "quota_exceeded"
+	}
+	if providerErr := ProviderError(err); providerErr != nil {
+		return providerErr.Code
+	}
+	return "internal_server_error"
+}
+
+// nextRunAt は延期したジョブを再実行する時刻を決める
+func (ju *musicJobUsecase) nextRunAt(job *model.MusicJob, err error) time.Time {
+	if ju.cb != nil && errors.Is(err, service.ErrCircuitOpen) {
+		if retryAt, ok := ju.cb.RetryAt(); ok {
+			return retryAt
+		}
+	}
+	policy := service.RetryPolicy{BaseDelay: minMusicJobDeferral, MaxDelay: 30 * time.Minute}
+	return time.Now().Add(minMusicJobDeferral + policy.Backoff(job.Attempts))
+}
@@ -0,0 +1,29 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kenta-kenta/diary-music/service"
+)
+
+func TestJobErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&service.ProviderError{Provider: "topmediai", StatusCode: 502, Message: "<html>bad gateway</html>", Kind: service.ErrProviderUnavailable}, "music_provider_unavailable"},
+		{fmt.Errorf("create music: %w", service.ErrCircuitOpen), "music_provider_unavailable"},
+		{&service.ProviderError{Provider: "topmediai", StatusCode: 429, Kind: service.ErrProviderQuotaExceeded}, "music_provider_rate_limited"},
+		{&service.ProviderError{Provider: "topmediai", StatusCode: 400, Message: "prompt contains ...", Kind: service.ErrProviderBadPrompt}, "invalid_prompt"},
+		{&service.ProviderError{Provider: "topmediai", StatusCode: 401, Kind: service.ErrProviderAuthFailed}, "music_provider_error"},
+		{&QuotaExceededError{Period: "daily", Limit: 5, Used: 5}, "quota_exceeded"},
+		{errors.New("dial tcp 10.0.0.1:5432: connection refused"), "internal_server_error"},
+	}
+	for _, tt := range tests {
+		if got := jobErrorCode(tt.err); got != tt.want {
+			t.Errorf("jobErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
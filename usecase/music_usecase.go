@@ -1,35 +1,251 @@
 package usecase
 
 import (
+	"context"
+	"errors"
+
+	"github.com/kenta-kenta/diary-music/apperror"
 	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/prompt"
 	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/storage"
+	"github.com/kenta-kenta/diary-music/validator"
+)
+
+// 配信できる曲のファイルの種類
+const (
+	MusicAssetAudio = "audio"
+	MusicAssetCover = "cover"
 )
 
+// ErrAssetNotStored は曲のファイルが自前のストレージに保存されていないことを表す
+var ErrAssetNotStored = errors.New("asset is not stored")
+
 type IMusicUsecase interface {
-	CreateMusic(prompt, lyrics, title string, isAuto, instrumental int, diaryID uint) (*model.MusicResponse, error)
-	GetMusicsList(page int, limit int, userId uint) ([]model.Music, error)
+	CreateMusic(ctx context.Context, userId uint, diaryId uint, req model.MusicRequest) (*model.MusicJobStatusResponse, error)
+	GetMusicsList(ctx context.Context, page int, limit int, userId uint) ([]model.Music, error)
+	GetMusicTakes(ctx context.Context, userId uint, diaryId uint) ([]model.MusicTakeResponse, error)
+	GetMusicStatus(ctx context.Context, userId uint, diaryId uint) (*model.MusicJobStatusResponse, error)
+	SetPrimaryMusic(ctx context.Context, userId uint, diaryId uint, musicId uint) ([]model.MusicData, error)
+	OpenMusicAsset(ctx context.Context, userId uint, musicId uint, asset string) (storage.Object, *storage.ObjectInfo, error)
 }
 
 type MusicUsecase struct {
 	mr repository.IMusicRepository
+	jr repository.IMusicJobRepository
+	dr repository.IDiaryRepository
+	mv validator.IMusicValidator
+	pb prompt.IPromptBuilder
+	jn IJobNotifier
+	qu IQuotaUsecase
+	as IAssetURLSigner
+	st storage.IStorage
+	ep IEventPublisher
 }
 
-func NewMusicUsecase(mr repository.IMusicRepository) *MusicUsecase {
-	return &MusicUsecase{mr}
+func NewMusicUsecase(mr repository.IMusicRepository, jr repository.IMusicJobRepository, dr repository.IDiaryRepository, mv validator.IMusicValidator, pb prompt.IPromptBuilder, jn IJobNotifier, qu IQuotaUsecase, as IAssetURLSigner, st storage.IStorage, ep IEventPublisher) *MusicUsecase {
+	return &MusicUsecase{mr, jr, dr, mv, pb, jn, qu, as, st, ep}
 }
 
-func (mu *MusicUsecase) CreateMusic(prompt, lyrics, title string, isAuto, instrumental int, diaryID uint) (*model.MusicResponse, error) {
-	request := &model.MusicRequest{
-		IsAuto:       isAuto,
-		Prompt:       prompt,
-		Lyrics:       lyrics,
-		Title:        title,
-		Instrumental: instrumental,
+// CreateMusic は既存の日記に対して指定したパラメータで音楽を再生成するジョブを登録する。
+// 以前に生成した曲は履歴として残る。
+func (mu *MusicUsecase) CreateMusic(ctx context.Context, userId uint, diaryId uint, req model.MusicRequest) (*model.MusicJobStatusResponse, error) {
+	if err := mu.mv.MusicRequestValidate(req); err != nil {
+		return nil, apperror.Validation(err)
+	}
+
+	// 日記の所有者を確認
+	diary := model.Diary{}
+	if err := mu.dr.GetDiaryById(ctx, &diary, userId, diaryId); err != nil {
+		return nil, notFound(err, ErrDiaryNotFound)
 	}
+	if err := mu.qu.CheckQuota(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	// プロンプトが指定されていなければ日記の本文から組み立てる
+	var promptTags string
+	if req.Prompt == "" {
+		built := mu.pb.Build(diary.Content)
+		req.Prompt = built.Text
+		promptTags = built.Tags()
+	}
+	job := &model.MusicJob{
+		PromptTags:   promptTags,
+		UserID:       userId,
+		DiaryID:      diary.ID,
+		IsAuto:       req.IsAuto,
+		Prompt:       req.Prompt,
+		Lyrics:       req.Lyrics,
+		Title:        req.Title,
+		Instrumental: req.Instrumental,
+	}
+	if err := mu.jr.CreateJob(ctx, job); err != nil {
+		return nil, deletionPending(err)
+	}
+	mu.jn.Notify()
+	publishMusicEvent(ctx, mu.ep, model.EventMusicQueued, job, nil)
 
-	return mu.mr.CreateMusic(request, diaryID)
+	return &model.MusicJobStatusResponse{
+		JobID:     job.ID,
+		DiaryID:   job.DiaryID,
+		Status:    job.Status,
+		MusicData: []model.MusicData{},
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}, nil
 }
 
-func (mu *MusicUsecase) GetMusicsList(page int, limit int, userId uint) ([]model.Music, error) {
-	return mu.mr.GetMusicsList(page, limit, userId)
+func (mu *MusicUsecase) GetMusicsList(ctx context.Context, page int, limit int, userId uint) ([]model.Music, error) {
+	musics, err := mu.mr.GetMusicsList(ctx, page, limit, userId)
+	if err != nil {
+		return nil, err
+	}
+	linkMusics(mu.as, musics)
+	return musics, nil
+}
+
+// GetMusicTakes は日記に対するこれまでの生成（テイク）を新しい順に返す
+func (mu *MusicUsecase) GetMusicTakes(ctx context.Context, userId uint, diaryId uint) ([]model.MusicTakeResponse, error) {
+	diary := model.Diary{}
+	if err := mu.dr.GetDiaryById(ctx, &diary, userId, diaryId); err != nil {
+		return nil, notFound(err, ErrDiaryNotFound)
+	}
+	jobs, err := mu.jr.GetJobsByDiary(ctx, userId, diaryId)
+	if err != nil {
+		return nil, err
+	}
+	musics, err := mu.mr.GetMusicsByDiary(ctx, userId, diaryId)
+	if err != nil {
+		return nil, err
+	}
+
+	linkMusics(mu.as, musics)
+	musicsByJob := map[uint][]model.MusicData{}
+	for _, music := range musics {
+		musicsByJob[music.JobID] = append(musicsByJob[music.JobID], model.ToMusicData(music))
+	}
+
+	takes := make([]model.MusicTakeResponse, 0, len(jobs)+1)
+	for _, job := range jobs {
+		musicData := musicsByJob[job.ID]
+		if musicData == nil {
+			musicData = []model.MusicData{}
+		}
+		takes = append(takes, model.MusicTakeResponse{
+			JobID:  job.ID,
+			Status: job.Status,
+			Request: model.MusicRequest{
+				IsAuto:       job.IsAuto,
+				Prompt:       job.Prompt,
+				Lyrics:       job.Lyrics,
+				Title:        job.Title,
+				Instrumental: job.Instrumental,
+			},
+			PromptTags: job.PromptTags,
+			Error:      job.Error,
+			MusicData:  musicData,
+			CreatedAt:  job.CreatedAt,
+		})
+	}
+
+	// ジョブ導入前に生成された曲は1つのテイクとしてまとめる
+	if legacy, ok := musicsByJob[0]; ok {
+		first := musics[0]
+		for _, music := range musics {
+			if music.JobID == 0 {
+				first = music
+				break
+			}
+		}
+		takes = append(takes, model.MusicTakeResponse{
+			Status: model.MusicJobStatusSucceeded,
+			Request: model.MusicRequest{
+				IsAuto:       first.IsAuto,
+				Prompt:       first.Prompt,
+				Instrumental: first.Instrumental,
+			},
+			PromptTags: first.PromptTags,
+			MusicData:  legacy,
+			CreatedAt:  first.CreatedAt,
+		})
+	}
+	return takes, nil
+}
+
+func (mu *MusicUsecase) GetMusicStatus(ctx context.Context, userId uint, diaryId uint) (*model.MusicJobStatusResponse, error) {
+	job := model.MusicJob{}
+	if err := mu.jr.GetLatestJobByDiary(ctx, &job, userId, diaryId); err != nil {
+		return nil, notFound(err, ErrMusicJobNotFound)
+	}
+
+	musics, err := mu.mr.GetMusicsByDiary(ctx, userId, diaryId)
+	if err != nil {
+		return nil, err
+	}
+	musicData := []model.MusicData{}
+	for _, music := range musics {
+		musicData = append(musicData, model.ToMusicData(music))
+	}
+	linkMusicData(mu.as, musicData)
+
+	return &model.MusicJobStatusResponse{
+		JobID:      job.ID,
+		DiaryID:    job.DiaryID,
+		Status:     job.Status,
+		Attempts:   job.Attempts,
+		Error:      job.Error,
+		RunAfter:   job.RunAfter,
+		MusicData:  musicData,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+		CreatedAt:  job.CreatedAt,
+		UpdatedAt:  job.UpdatedAt,
+	}, nil
+}
+
+// SetPrimaryMusic は日記の代表曲を切り替え、切り替え後の曲一覧を返す
+func (mu *MusicUsecase) SetPrimaryMusic(ctx context.Context, userId uint, diaryId uint, musicId uint) ([]model.MusicData, error) {
+	if err := mu.mr.SetPrimaryMusic(ctx, userId, diaryId, musicId); err != nil {
+		return nil, notFound(err, ErrMusicNotFound)
+	}
+	musics, err := mu.mr.GetMusicsByDiary(ctx, userId, diaryId)
+	if err != nil {
+		return nil, err
+	}
+	musicData := make([]model.MusicData, 0, len(musics))
+	for _, music := range musics {
+		musicData = append(musicData, model.ToMusicData(music))
+	}
+	linkMusicData(mu.as, musicData)
+	return musicData, nil
+}
+
+// OpenMusicAsset はユーザー自身の曲の音声またはカバー画像を開く。
+// ETag には保存時に計算したSHA-256を使う。
+func (mu *MusicUsecase) OpenMusicAsset(ctx context.Context, userId uint, musicId uint, asset string) (storage.Object, *storage.ObjectInfo, error) {
+	music := model.Music{}
+	if err := mu.mr.GetMusicById(ctx, &music, userId, musicId); err != nil {
+		return nil, nil, notFound(err, ErrMusicNotFound)
+	}
+
+	key, sum := music.AudioKey, music.AudioSHA256
+	if asset == MusicAssetCover {
+		key, sum = music.ImageKey, music.ImageSHA256
+	}
+	if key == "" {
+		return nil, nil, ErrMusicAssetNotFound.Wrap(ErrAssetNotStored)
+	}
+
+	obj, info, err := mu.st.Open(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrMusicAssetNotFound.Wrap(err)
+	}
+	if err != nil {
+		return nil, nil, apperror.UpstreamFailure("storage_unavailable", "Storage is unavailable", err)
+	}
+	if sum != "" {
+		info.ETag = sum
+	}
+	return obj, info, nil
 }
@@ -0,0 +1,253 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+)
+
+// 音楽生成1回あたりのコスト（プロバイダーの1リクエスト分）
+const musicGenerationCost = 1
+
+// プランが見つからない場合に使うプラン
+const DefaultQuotaPlan = "free"
+
+// ErrQuotaExceeded は音楽生成の上限に達したことを表す
+var ErrQuotaExceeded = errors.New("music generation quota exceeded")
+
+// QuotaExceededError は上限に達した期間と再び生成できる時刻を表す
+type QuotaExceededError struct {
+	Period   string // "daily" または "monthly"
+	Limit    int
+	Used     int
+	ResetsAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s music generation quota exceeded (%d/%d), resets at %s",
+		e.Period, e.Used, e.Limit, e.ResetsAt.Format(time.RFC3339))
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaPlan はプランごとの上限（0 は上限なし）
+type QuotaPlan struct {
+	Daily   int
+	Monthly int
+}
+
+// DefaultQuotaPlans は MUSIC_QUOTA_PLANS が指定されていない場合のプラン
+var DefaultQuotaPlans = map[string]QuotaPlan{
+	"free":      {Daily: 5, Monthly: 60},
+	"unlimited": {},
+}
+
+// ParseQuotaPlans は "free=5/60,pro=30/600" の形式（日/月）のプラン定義を読み込む
+func ParseQuotaPlans(s string) (map[string]QuotaPlan, error) {
+	plans := map[string]QuotaPlan{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, limits, ok := strings.Cut(entry, "=")
+		daily, monthly, ok2 := strings.Cut(limits, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid quota plan %q", entry)
+		}
+		d, err := strconv.Atoi(daily)
+		if err != nil {
+			return nil, fmt.Errorf("invalid daily quota in %q: %w", entry, err)
+		}
+		m, err := strconv.Atoi(monthly)
+		if err != nil {
+			return nil, fmt.Errorf("invalid monthly quota in %q: %w", entry, err)
+		}
+		plans[strings.TrimSpace(name)] = QuotaPlan{Daily: d, Monthly: m}
+	}
+	return plans, nil
+}
+
+type IQuotaUsecase interface {
+	// CheckQuota は新しい生成ジョブを登録できるか確認する（生成待ちのジョブも使用量に含める）。
+	// メールアドレスを確認していない場合は ErrEmailNotVerified を返す
+	CheckQuota(ctx context.Context, userId uint) error
+	// Reserve はプロバイダーを呼び出す直前に枠を確保して台帳に記録する。上限に達していれば ErrQuotaExceeded を返す
+	Reserve(ctx context.Context, job *model.MusicJob) (*model.UsageRecord, error)
+	// Settle は生成の結果を台帳に記録する
+	Settle(ctx context.Context, record *model.UsageRecord, cause error) error
+	GetUsage(ctx context.Context, userId uint) (*model.UsageResponse, error)
+}
+
+type quotaUsecase struct {
+	qr       repository.IUsageRepository
+	ur       repository.IUserRepository
+	jr       repository.IMusicJobRepository
+	plans    map[string]QuotaPlan
+	provider string
+}
+
+// provider は台帳に記録するプロバイダー名
+func NewQuotaUsecase(qr repository.IUsageRepository, ur repository.IUserRepository, jr repository.IMusicJobRepository, plans map[string]QuotaPlan, provider string) IQuotaUsecase {
+	return &quotaUsecase{qr, ur, jr, plans, provider}
+}
+
+// quotaLimits はユーザーのプランと上限を返す
+func (qu *quotaUsecase) quotaLimits(ctx context.Context, userId uint) (string, QuotaPlan, error) {
+	user := model.User{}
+	if err := qu.ur.GetUserById(ctx, &user, userId); err != nil {
+		return "", QuotaPlan{}, err
+	}
+	planName, plan := qu.userLimits(user)
+	return planName, plan, nil
+}
+
+// userLimits はユーザーのプランの上限にユーザーごとの上限を反映する
+func (qu *quotaUsecase) userLimits(user model.User) (string, QuotaPlan) {
+	planName := user.Plan
+	plan, ok := qu.plans[planName]
+	if !ok {
+		planName = DefaultQuotaPlan
+		plan = qu.plans[DefaultQuotaPlan]
+	}
+	if user.DailyQuota != nil {
+		plan.Daily = *user.DailyQuota
+	}
+	if user.MonthlyQuota != nil {
+		plan.Monthly = *user.MonthlyQuota
+	}
+	return planName, plan
+}
+
+// checkLimits は使用量に cost を加えると上限を超える場合に QuotaExceededError を返す
+func checkLimits(plan QuotaPlan, now time.Time, daily, monthly, cost int) error {
+	if plan.Daily > 0 && daily+cost > plan.Daily {
+		return &QuotaExceededError{Period: "daily", Limit: plan.Daily, Used: daily, ResetsAt: nextDay(now)}
+	}
+	if plan.Monthly > 0 && monthly+cost > plan.Monthly {
+		return &QuotaExceededError{Period: "monthly", Limit: plan.Monthly, Used: monthly, ResetsAt: nextMonth(now)}
+	}
+	return nil
+}
+
+func (qu *quotaUsecase) CheckQuota(ctx context.Context, userId uint) error {
+	user := model.User{}
+	if err := qu.ur.GetUserById(ctx, &user, userId); err != nil {
+		return err
+	}
+	// メールアドレスを確認していないユーザーは生成できない
+	if user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	_, plan := qu.userLimits(user)
+	if plan.Daily == 0 && plan.Monthly == 0 {
+		return nil
+	}
+	now := time.Now()
+	daily, err := qu.qr.SumCostUnits(ctx, userId, startOfDay(now))
+	if err != nil {
+		return err
+	}
+	monthly, err := qu.qr.SumCostUnits(ctx, userId, startOfMonth(now))
+	if err != nil {
+		return err
+	}
+	waiting, err := qu.jr.CountWaitingJobs(ctx, userId)
+	if err != nil {
+		return err
+	}
+	cost := musicGenerationCost * (int(waiting) + 1)
+	return checkLimits(plan, now, daily, monthly, cost)
+}
+
+func (qu *quotaUsecase) Reserve(ctx context.Context, job *model.MusicJob) (*model.UsageRecord, error) {
+	_, plan, err := qu.quotaLimits(ctx, job.UserID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	record := &model.UsageRecord{
+		UserID:    job.UserID,
+		DiaryID:   job.DiaryID,
+		JobID:     job.ID,
+		Provider:  qu.provider,
+		CostUnits: musicGenerationCost,
+	}
+	err = qu.qr.ReserveUsage(ctx, record, startOfDay(now), startOfMonth(now), func(daily int, monthly int) error {
+		return checkLimits(plan, now, daily, monthly, musicGenerationCost)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (qu *quotaUsecase) Settle(ctx context.Context, record *model.UsageRecord, cause error) error {
+	if cause == nil {
+		record.Outcome = model.UsageOutcomeSucceeded
+	} else {
+		// 失敗した生成にはコストがかからない
+		record.Outcome = model.UsageOutcomeFailed
+		record.CostUnits = 0
+		record.Error = cause.Error()
+	}
+	return qu.qr.UpdateUsage(ctx, record)
+}
+
+func (qu *quotaUsecase) GetUsage(ctx context.Context, userId uint) (*model.UsageResponse, error) {
+	planName, plan, err := qu.quotaLimits(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	daily, err := qu.qr.SumCostUnits(ctx, userId, startOfDay(now))
+	if err != nil {
+		return nil, err
+	}
+	monthly, err := qu.qr.SumCostUnits(ctx, userId, startOfMonth(now))
+	if err != nil {
+		return nil, err
+	}
+	waiting, err := qu.jr.CountWaitingJobs(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return &model.UsageResponse{
+		Plan:    planName,
+		Daily:   model.UsagePeriod{Used: daily, Limit: quotaLimit(plan.Daily), ResetsAt: nextDay(now)},
+		Monthly: model.UsagePeriod{Used: monthly, Limit: quotaLimit(plan.Monthly), ResetsAt: nextMonth(now)},
+		Queued:  int(waiting),
+	}, nil
+}
+
+// quotaLimit は上限なし（0）を nil で表す
+func quotaLimit(limit int) *int {
+	if limit == 0 {
+		return nil
+	}
+	return &limit
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func nextDay(t time.Time) time.Time {
+	return startOfDay(t).AddDate(0, 0, 1)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func nextMonth(t time.Time) time.Time {
+	return startOfMonth(t).AddDate(0, 1, 0)
+}
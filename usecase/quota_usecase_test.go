@@ -0,0 +1,157 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+)
+
+type fakeQuotaUserRepository struct {
+	repository.IUserRepository
+	user model.User
+}
+
+func (r *fakeQuotaUserRepository) GetUserById(ctx context.Context, user *model.User, userId uint) error {
+	*user = r.user
+	return nil
+}
+
+// fakeUsageRepository は since が月初の場合に monthly、それ以外は daily を返す（1日は区別できないため daily）
+type fakeUsageRepository struct {
+	repository.IUsageRepository
+	daily, monthly int
+}
+
+func (r *fakeUsageRepository) SumCostUnits(ctx context.Context, userId uint, since time.Time) (int, error) {
+	if since.Equal(startOfMonth(time.Now())) && !since.Equal(startOfDay(time.Now())) {
+		return r.monthly, nil
+	}
+	return r.daily, nil
+}
+
+type fakeWaitingJobRepository struct {
+	repository.IMusicJobRepository
+	waiting int64
+}
+
+func (r *fakeWaitingJobRepository) CountWaitingJobs(ctx context.Context, userId uint) (int64, error) {
+	return r.waiting, nil
+}
+
+func intPtr(n int) *int { return &n }
+
+func TestParseQuotaPlans(t *testing.T) {
+	plans, err := ParseQuotaPlans(" free=5/60, pro=30/600 ,unlimited=0/0,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]QuotaPlan{"free": {5, 60}, "pro": {30, 600}, "unlimited": {}}
+	if len(plans) != len(want) {
+		t.Fatalf("plans = %v, want %v", plans, want)
+	}
+	for name, plan := range want {
+		if plans[name] != plan {
+			t.Errorf("plans[%q] = %+v, want %+v", name, plans[name], plan)
+		}
+	}
+
+	for _, s := range []string{"free", "free=5", "free=a/60", "free=5/b"} {
+		if _, err := ParseQuotaPlans(s); err == nil {
+			t.Errorf("ParseQuotaPlans(%q) returned no error", s)
+		}
+	}
+}
+
+func TestUserLimits(t *testing.T) {
+	qu := &quotaUsecase{plans: map[string]QuotaPlan{"free": {5, 60}, "pro": {30, 600}}}
+	tests := []struct {
+		name     string
+		user     model.User
+		wantPlan string
+		want     QuotaPlan
+	}{
+		{"plan", model.User{Plan: "pro"}, "pro", QuotaPlan{30, 600}},
+		{"unknown plan falls back to free", model.User{Plan: "gold"}, "free", QuotaPlan{5, 60}},
+		{"per-user override", model.User{Plan: "free", DailyQuota: intPtr(10)}, "free", QuotaPlan{10, 60}},
+		{"per-user unlimited", model.User{Plan: "free", DailyQuota: intPtr(0), MonthlyQuota: intPtr(0)}, "free", QuotaPlan{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			planName, plan := qu.userLimits(tt.user)
+			if planName != tt.wantPlan || plan != tt.want {
+				t.Fatalf("userLimits() = %s %+v, want %s %+v", planName, plan, tt.wantPlan, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	now := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	plan := QuotaPlan{Daily: 5, Monthly: 60}
+	tests := []struct {
+		name           string
+		plan           QuotaPlan
+		daily, monthly int
+		cost           int
+		wantPeriod     string
+		wantResetsAt   time.Time
+	}{
+		{"under the limits", plan, 4, 59, 1, "", time.Time{}},
+		{"daily limit", plan, 5, 10, 1, "daily", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"waiting jobs count toward the limit", plan, 3, 10, 3, "daily", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"monthly limit", plan, 0, 60, 1, "monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"unlimited", QuotaPlan{}, 1000, 1000, 1, "", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLimits(tt.plan, now, tt.daily, tt.monthly, tt.cost)
+			if tt.wantPeriod == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var qe *QuotaExceededError
+			if !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) {
+				t.Fatalf("err = %v, want QuotaExceededError", err)
+			}
+			if qe.Period != tt.wantPeriod || !qe.ResetsAt.Equal(tt.wantResetsAt) {
+				t.Fatalf("err = %+v, want %s resetting at %v", qe, tt.wantPeriod, tt.wantResetsAt)
+			}
+		})
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	verified := time.Now()
+	tests := []struct {
+		name           string
+		user           model.User
+		daily, monthly int
+		waiting        int64
+		wantErr        error
+	}{
+		{"under the limit", model.User{Plan: "free", EmailVerifiedAt: &verified}, 3, 10, 1, nil},
+		{"waiting jobs reach the daily limit", model.User{Plan: "free", EmailVerifiedAt: &verified}, 3, 10, 2, ErrQuotaExceeded},
+		{"unverified email", model.User{Plan: "free"}, 0, 0, 0, ErrEmailNotVerified},
+		{"unlimited plan", model.User{Plan: "unlimited", EmailVerifiedAt: &verified}, 100, 1000, 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qu := NewQuotaUsecase(
+				&fakeUsageRepository{daily: tt.daily, monthly: tt.monthly},
+				&fakeQuotaUserRepository{user: tt.user},
+				&fakeWaitingJobRepository{waiting: tt.waiting},
+				DefaultQuotaPlans, "fake",
+			)
+			err := qu.CheckQuota(context.Background(), 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckQuota() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kenta-kenta/diary-music/config"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"gorm.io/gorm"
+)
+
+// ISessionUsecase はログインごとのセッションとアクセストークン・リフレッシュトークンを管理する
+type ISessionUsecase interface {
+	// CreateSession はログインしたユーザーのセッションを作り、最初のトークンを発行する
+	CreateSession(ctx context.Context, userId uint, client model.SessionClient) (model.AuthTokens, error)
+	// Refresh はリフレッシュトークンを交換して新しいトークンを発行する
+	Refresh(ctx context.Context, refreshToken string) (model.AuthTokens, error)
+	// Logout はリフレッシュトークンのセッションを無効にする
+	Logout(ctx context.Context, refreshToken string) error
+	// CheckSession はアクセストークンのセッションが有効かを確認する
+	CheckSession(ctx context.Context, userId uint, sessionId uint) error
+	GetSessions(ctx context.Context, userId uint, currentSessionId uint) ([]model.SessionResponse, error)
+	RevokeSession(ctx context.Context, userId uint, sessionId uint) error
+	// RevokeOtherSessions は currentSessionId 以外のセッションをすべて無効にする
+	RevokeOtherSessions(ctx context.Context, userId uint, currentSessionId uint, reason string) error
+}
+
+type sessionUsecase struct {
+	sr  repository.ISessionRepository
+	cfg config.AuthConfig
+}
+
+func NewSessionUsecase(sr repository.ISessionRepository, cfg config.AuthConfig) ISessionUsecase {
+	return &sessionUsecase{sr, cfg}
+}
+
+func (su *sessionUsecase) CreateSession(ctx context.Context, userId uint, client model.SessionClient) (model.AuthTokens, error) {
+	now := time.Now()
+	refresh, token, err := su.newRefreshToken(now)
+	if err != nil {
+		return model.AuthTokens{}, err
+	}
+	session := model.Session{
+		UserID:     userId,
+		UserAgent:  truncate(client.UserAgent, 512),
+		IPAddress:  client.IPAddress,
+		LastUsedAt: now,
+		ExpiresAt:  token.ExpiresAt,
+	}
+	if err := su.sr.CreateSession(ctx, &session, &token); err != nil {
+		return model.AuthTokens{}, err
+	}
+	return su.issue(session, refresh, token.ExpiresAt, now)
+}
+
+func (su *sessionUsecase) Refresh(ctx context.Context, refreshToken string) (model.AuthTokens, error) {
+	if refreshToken == "" {
+		return model.AuthTokens{}, ErrInvalidRefreshToken
+	}
+	now := time.Now()
+	refresh, next, err := su.newRefreshToken(now)
+	if err != nil {
+		return model.AuthTokens{}, err
+	}
+	session := model.Session{}
+	err = su.sr.RotateRefreshToken(ctx, hashToken(refreshToken), &next, &session, now)
+	if errors.Is(err, repository.ErrRefreshTokenReused) {
+		// 盗まれたトークンが使われた可能性があるので、同じ系列のトークンをすべて無効にする
+		slog.WarnContext(ctx, "refresh token reused, revoking session", "session_id", session.ID, "user_id", session.UserID)
+		if err := su.sr.RevokeSession(ctx, session.UserID, session.ID, model.SessionRevokedTokenReused, now); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.AuthTokens{}, err
+		}
+		return model.AuthTokens{}, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return model.AuthTokens{}, notFound(err, ErrInvalidRefreshToken)
+	}
+	return su.issue(session, refresh, next.ExpiresAt, now)
+}
+
+func (su *sessionUsecase) Logout(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+	err := su.sr.RevokeSessionByRefreshToken(ctx, hashToken(refreshToken), model.SessionRevokedLogout, time.Now())
+	// 既に無効なセッションでもログアウトは成功とする
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (su *sessionUsecase) CheckSession(ctx context.Context, userId uint, sessionId uint) error {
+	session := model.Session{}
+	if err := su.sr.GetSessionById(ctx, &session, sessionId); err != nil {
+		return notFound(err, ErrSessionRevoked)
+	}
+	if session.UserID != userId || !session.Active(time.Now()) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func (su *sessionUsecase) GetSessions(ctx context.Context, userId uint, currentSessionId uint) ([]model.SessionResponse, error) {
+	sessions, err := su.sr.GetSessions(ctx, userId, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	res := make([]model.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, model.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			Current:    session.ID == currentSessionId,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			CreatedAt:  session.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func (su *sessionUsecase) RevokeSession(ctx context.Context, userId uint, sessionId uint) error {
+	err := su.sr.RevokeSession(ctx, userId, sessionId, model.SessionRevokedByUser, time.Now())
+	return notFound(err, ErrSessionNotFound)
+}
+
+func (su *sessionUsecase) RevokeOtherSessions(ctx context.Context, userId uint, currentSessionId uint, reason string) error {
+	return su.sr.RevokeUserSessions(ctx, userId, currentSessionId, reason, time.Now())
+}
+
+// newRefreshToken はリフレッシュトークンを生成し、保存する値（ハッシュ）と一緒に返す
+func (su *sessionUsecase) newRefreshToken(now time.Time) (string, model.RefreshToken, error) {
+	refresh, err := randomHex(32)
+	if err != nil {
+		return "", model.RefreshToken{}, err
+	}
+	return refresh, model.RefreshToken{
+		TokenHash: hashToken(refresh),
+		ExpiresAt: now.Add(su.cfg.RefreshTokenTTL),
+	}, nil
+}
+
+// issue はセッションのアクセストークンを署名してトークンをまとめる
+func (su *sessionUsecase) issue(session model.Session, refresh string, refreshExpiresAt time.Time, now time.Time) (model.AuthTokens, error) {
+	accessExpiresAt := now.Add(su.cfg.AccessTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":    session.UserID,         // ユーザーID
+		"session_id": session.ID,             // セッションID（無効にしたセッションのトークンは拒否する）
+		"exp":        accessExpiresAt.Unix(), // 有効期限
+	})
+	access, err := token.SignedString([]byte(su.cfg.Secret))
+	if err != nil {
+		return model.AuthTokens{}, err
+	}
+	return model.AuthTokens{
+		SessionID:        session.ID,
+		AccessToken:      access,
+		AccessExpiresAt:  accessExpiresAt,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+// hashToken はトークンをDBに保存する形にする（推測できない長さなのでソルトは付けない）
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate は s を最大 n バイトにする（UTF-8 の途中では切らない）
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
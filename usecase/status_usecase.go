@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/service"
+)
+
+// 1つのチェックにかけられる時間
+const healthCheckTimeout = 2 * time.Second
+
+// IPoolStats はワーカープールの統計を返す
+type IPoolStats interface {
+	Stats() model.PoolStats
+}
+
+// DebugStatusResponse は運用者向けの詳細な状態
+type DebugStatusResponse struct {
+	Version           string                       `json:"version"`
+	GoVersion         string                       `json:"go_version"`
+	StartedAt         time.Time                    `json:"started_at"`
+	Uptime            string                       `json:"uptime"`
+	Goroutines        int                          `json:"goroutines"`
+	HeapAllocBytes    uint64                       `json:"heap_alloc_bytes"`
+	SchemaVersion     string                       `json:"schema_version"`
+	Database          DBPoolStats                  `json:"database"`
+	Pools             []model.PoolStats            `json:"pools"`
+	MusicJobs         map[string]int64             `json:"music_jobs"`         // 状態ごとのジョブ数
+	WebhookDeliveries map[string]int64             `json:"webhook_deliveries"` // 状態ごとの配信数
+	MusicProvider     service.CircuitBreakerStatus `json:"music_provider"`
+}
+
+type DBPoolStats struct {
+	OpenConnections int   `json:"open_connections"`
+	InUse           int   `json:"in_use"`
+	Idle            int   `json:"idle"`
+	WaitCount       int64 `json:"wait_count"`
+	WaitDurationMs  int64 `json:"wait_duration_ms"`
+}
+
+type IStatusUsecase interface {
+	// Ready はリクエストを受け付けられるかを依存先ごとに確認する
+	Ready(ctx context.Context) model.ReadinessResponse
+	DebugStatus(ctx context.Context) (*DebugStatusResponse, error)
+}
+
+type statusUsecase struct {
+	hr        repository.IHealthRepository
+	jr        repository.IMusicJobRepository
+	wr        repository.IWebhookRepository
+	cb        *service.CircuitBreaker
+	provider  string
+	version   string
+	pools     []IPoolStats
+	startedAt time.Time
+}
+
+// version はビルドのバージョン。pools は /debug/status に表示するワーカープール
+func NewStatusUsecase(hr repository.IHealthRepository, jr repository.IMusicJobRepository, wr repository.IWebhookRepository, cb *service.CircuitBreaker, provider string, version string, pools ...IPoolStats) IStatusUsecase {
+	return &statusUsecase{hr, jr, wr, cb, provider, version, pools, time.Now()}
+}
+
+func (su *statusUsecase) Ready(ctx context.Context) model.ReadinessResponse {
+	checks := map[string]func(ctx context.Context) (string, error){
+		"database": func(ctx context.Context) (string, error) {
+			if err := su.hr.Ping(ctx); err != nil {
+				return model.HealthStatusUnavailable, err
+			}
+			return model.HealthStatusOK, nil
+		},
+		// 未適用のマイグレーションがある場合はスキーマが古いので受け付けない
+		"migrations": func(ctx context.Context) (string, error) {
+			_, pending, err := su.hr.SchemaVersion(ctx)
+			if err != nil {
+				return model.HealthStatusUnavailable, err
+			}
+			if pending > 0 {
+				return model.HealthStatusUnavailable, fmt.Errorf("%d pending migration(s)", pending)
+			}
+			return model.HealthStatusOK, nil
+		},
+		// プロバイダーの障害中も日記の閲覧はできるので degraded にとどめる
+		"music_provider": func(ctx context.Context) (string, error) {
+			status := su.cb.Status(su.provider)
+			if status.State != service.CircuitClosed {
+				return model.HealthStatusDegraded, fmt.Errorf("circuit breaker is %s: %s", status.State, status.LastError)
+			}
+			return model.HealthStatusOK, nil
+		},
+	}
+
+	res := model.ReadinessResponse{
+		Status: model.HealthStatusOK,
+		Checks: make(map[string]model.HealthCheckResult, len(checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) (string, error)) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			begin := time.Now()
+			status, err := check(ctx)
+			result := model.HealthCheckResult{Status: status, LatencyMs: time.Since(begin).Milliseconds()}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[name] = result
+			res.Status = worseHealth(res.Status, status)
+		}(name, check)
+	}
+	wg.Wait()
+	return res
+}
+
+// worseHealth は2つの状態のうち悪い方を返す
+func worseHealth(a, b string) string {
+	rank := map[string]int{
+		model.HealthStatusOK:          0,
+		model.HealthStatusDegraded:    1,
+		model.HealthStatusUnavailable: 2,
+	}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+func (su *statusUsecase) DebugStatus(ctx context.Context) (*DebugStatusResponse, error) {
+	schemaVersion, _, err := su.hr.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dbStats, err := su.hr.DBStats()
+	if err != nil {
+		return nil, err
+	}
+	musicJobs, err := su.jr.CountActiveJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhookDeliveries, err := su.wr.CountActiveDeliveries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]model.PoolStats, 0, len(su.pools))
+	for _, pool := range su.pools {
+		pools = append(pools, pool.Stats())
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return &DebugStatusResponse{
+		Version:        su.version,
+		GoVersion:      runtime.Version(),
+		StartedAt:      su.startedAt,
+		Uptime:         time.Since(su.startedAt).Round(time.Second).String(),
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		SchemaVersion:  schemaVersion,
+		Database: DBPoolStats{
+			OpenConnections: dbStats.OpenConnections,
+			InUse:           dbStats.InUse,
+			Idle:            dbStats.Idle,
+			WaitCount:       dbStats.WaitCount,
+			WaitDurationMs:  dbStats.WaitDuration.Milliseconds(),
+		},
+		Pools:             pools,
+		MusicJobs:         musicJobs,
+		WebhookDeliveries: webhookDeliveries,
+		MusicProvider:     su.cb.Status(su.provider),
+	}, nil
+}
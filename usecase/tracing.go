@@ -0,0 +1,437 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/storage"
+	"github.com/kenta-kenta/diary-music/tracing"
+)
+
+// 各ユースケースのメソッドごとにスパンを記録するラッパー。
+// スパン名は "DiaryUsecase.CreateDiary" のように インターフェース名.メソッド名 にする
+
+type tracingDiaryUsecase struct {
+	next IDiaryUsecase
+}
+
+func NewTracingDiaryUsecase(next IDiaryUsecase) IDiaryUsecase {
+	return &tracingDiaryUsecase{next}
+}
+
+func (t *tracingDiaryUsecase) GetAllDiaries(ctx context.Context, userId uint, page int, pageSize int) (*model.PaginationResponse, error) {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.GetAllDiaries")
+	r0, err := t.next.GetAllDiaries(ctx, userId, page, pageSize)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingDiaryUsecase) GetDiaryById(ctx context.Context, userId uint, diaryId uint) (model.DiaryResponse, error) {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.GetDiaryById")
+	r0, err := t.next.GetDiaryById(ctx, userId, diaryId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingDiaryUsecase) CreateDiary(ctx context.Context, diary model.Diary) (model.DiaryResponse, error) {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.CreateDiary")
+	r0, err := t.next.CreateDiary(ctx, diary)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingDiaryUsecase) CreateDiaryWithMusic(ctx context.Context, diary *model.Diary) (*model.DiaryResponse, error) {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.CreateDiaryWithMusic")
+	r0, err := t.next.CreateDiaryWithMusic(ctx, diary)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingDiaryUsecase) UpdateDiary(ctx context.Context, userId uint, diaryId uint, diary model.Diary) (model.DiaryResponse, error) {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.UpdateDiary")
+	r0, err := t.next.UpdateDiary(ctx, userId, diaryId, diary)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingDiaryUsecase) DeleteDiary(ctx context.Context, userId uint, diaryId uint) error {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.DeleteDiary")
+	err := t.next.DeleteDiary(ctx, userId, diaryId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingDiaryUsecase) GetDiaryDates(ctx context.Context, userId uint, year, month string) (*model.DiaryDateCountResponse, error) {
+	ctx, span := tracing.Start(ctx, "DiaryUsecase.GetDiaryDates")
+	r0, err := t.next.GetDiaryDates(ctx, userId, year, month)
+	tracing.End(span, err)
+	return r0, err
+}
+
+type tracingMusicUsecase struct {
+	next IMusicUsecase
+}
+
+func NewTracingMusicUsecase(next IMusicUsecase) IMusicUsecase {
+	return &tracingMusicUsecase{next}
+}
+
+func (t *tracingMusicUsecase) CreateMusic(ctx context.Context, userId uint, diaryId uint, req model.MusicRequest) (*model.MusicJobStatusResponse, error) {
+	ctx, span := tracing.Start(ctx, "MusicUsecase.CreateMusic")
+	r0, err := t.next.CreateMusic(ctx, userId, diaryId, req)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingMusicUsecase) GetMusicsList(ctx context.Context, page int, limit int, userId uint) ([]model.Music, error) {
+	ctx, span := tracing.Start(ctx, "MusicUsecase.GetMusicsList")
+	r0, err := t.next.GetMusicsList(ctx, page, limit, userId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingMusicUsecase) GetMusicTakes(ctx context.Context, userId uint, diaryId uint) ([]model.MusicTakeResponse, error) {
+	ctx, span := tracing.Start(ctx, "MusicUsecase.GetMusicTakes")
+	r0, err := t.next.GetMusicTakes(ctx, userId, diaryId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingMusicUsecase) GetMusicStatus(ctx context.Context, userId uint, diaryId uint) (*model.MusicJobStatusResponse, error) {
+	ctx, span := tracing.Start(ctx, "MusicUsecase.GetMusicStatus")
+	r0, err := t.next.GetMusicStatus(ctx, userId, diaryId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingMusicUsecase) SetPrimaryMusic(ctx context.Context, userId uint, diaryId uint, musicId uint) ([]model.MusicData, error) {
+	ctx, span := tracing.Start(ctx, "MusicUsecase.SetPrimaryMusic")
+	r0, err := t.next.SetPrimaryMusic(ctx, userId, diaryId, musicId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingMusicUsecase) OpenMusicAsset(ctx context.Context, userId uint, musicId uint, asset string) (storage.Object, *storage.ObjectInfo, error) {
+	ctx, span := tracing.Start(ctx, "MusicUsecase.OpenMusicAsset")
+	r0, r1, err := t.next.OpenMusicAsset(ctx, userId, musicId, asset)
+	tracing.End(span, err)
+	return r0, r1, err
+}
+
+type tracingQuotaUsecase struct {
+	next IQuotaUsecase
+}
+
+func NewTracingQuotaUsecase(next IQuotaUsecase) IQuotaUsecase {
+	return &tracingQuotaUsecase{next}
+}
+
+func (t *tracingQuotaUsecase) CheckQuota(ctx context.Context, userId uint) error {
+	ctx, span := tracing.Start(ctx, "QuotaUsecase.CheckQuota")
+	err := t.next.CheckQuota(ctx, userId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingQuotaUsecase) Reserve(ctx context.Context, job *model.MusicJob) (*model.UsageRecord, error) {
+	ctx, span := tracing.Start(ctx, "QuotaUsecase.Reserve")
+	r0, err := t.next.Reserve(ctx, job)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingQuotaUsecase) Settle(ctx context.Context, record *model.UsageRecord, cause error) error {
+	ctx, span := tracing.Start(ctx, "QuotaUsecase.Settle")
+	err := t.next.Settle(ctx, record, cause)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingQuotaUsecase) GetUsage(ctx context.Context, userId uint) (*model.UsageResponse, error) {
+	ctx, span := tracing.Start(ctx, "QuotaUsecase.GetUsage")
+	r0, err := t.next.GetUsage(ctx, userId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+type tracingUserUsecase struct {
+	next IUserUsecase
+}
+
+func NewTracingUserUsecase(next IUserUsecase) IUserUsecase {
+	return &tracingUserUsecase{next}
+}
+
+func (t *tracingUserUsecase) SignUp(ctx context.Context, user model.User) (model.UserResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.SignUp")
+	r0, err := t.next.SignUp(ctx, user)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingUserUsecase) Login(ctx context.Context, user model.User, client model.SessionClient) (model.AuthTokens, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.Login")
+	r0, err := t.next.Login(ctx, user, client)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingUserUsecase) GetUserById(ctx context.Context, user *model.User, userId uint) error {
+	ctx, span := tracing.Start(ctx, "UserUsecase.GetUserById")
+	err := t.next.GetUserById(ctx, user, userId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingUserUsecase) UpdateProfile(ctx context.Context, userId uint, req model.UserProfileRequest) (model.UserResponse, error) {
+	ctx, span := tracing.Start(ctx, "UserUsecase.UpdateProfile")
+	r0, err := t.next.UpdateProfile(ctx, userId, req)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingUserUsecase) ChangeEmail(ctx context.Context, userId uint, req model.EmailChangeRequest) error {
+	ctx, span := tracing.Start(ctx, "UserUsecase.ChangeEmail")
+	err := t.next.ChangeEmail(ctx, userId, req)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingUserUsecase) ChangePassword(ctx context.Context, userId uint, sessionId uint, req model.PasswordChangeRequest) error {
+	ctx, span := tracing.Start(ctx, "UserUsecase.ChangePassword")
+	err := t.next.ChangePassword(ctx, userId, sessionId, req)
+	tracing.End(span, err)
+	return err
+}
+
+type tracingSessionUsecase struct {
+	next ISessionUsecase
+}
+
+func NewTracingSessionUsecase(next ISessionUsecase) ISessionUsecase {
+	return &tracingSessionUsecase{next}
+}
+
+func (t *tracingSessionUsecase) CreateSession(ctx context.Context, userId uint, client model.SessionClient) (model.AuthTokens, error) {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.CreateSession")
+	r0, err := t.next.CreateSession(ctx, userId, client)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingSessionUsecase) Refresh(ctx context.Context, refreshToken string) (model.AuthTokens, error) {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.Refresh")
+	r0, err := t.next.Refresh(ctx, refreshToken)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingSessionUsecase) Logout(ctx context.Context, refreshToken string) error {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.Logout")
+	err := t.next.Logout(ctx, refreshToken)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingSessionUsecase) CheckSession(ctx context.Context, userId uint, sessionId uint) error {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.CheckSession")
+	err := t.next.CheckSession(ctx, userId, sessionId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingSessionUsecase) GetSessions(ctx context.Context, userId uint, currentSessionId uint) ([]model.SessionResponse, error) {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.GetSessions")
+	r0, err := t.next.GetSessions(ctx, userId, currentSessionId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingSessionUsecase) RevokeSession(ctx context.Context, userId uint, sessionId uint) error {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.RevokeSession")
+	err := t.next.RevokeSession(ctx, userId, sessionId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingSessionUsecase) RevokeOtherSessions(ctx context.Context, userId uint, currentSessionId uint, reason string) error {
+	ctx, span := tracing.Start(ctx, "SessionUsecase.RevokeOtherSessions")
+	err := t.next.RevokeOtherSessions(ctx, userId, currentSessionId, reason)
+	tracing.End(span, err)
+	return err
+}
+
+type tracingWebhookUsecase struct {
+	next IWebhookUsecase
+}
+
+func NewTracingWebhookUsecase(next IWebhookUsecase) IWebhookUsecase {
+	return &tracingWebhookUsecase{next}
+}
+
+func (t *tracingWebhookUsecase) CreateWebhook(ctx context.Context, userId uint, req model.WebhookRequest) (model.WebhookResponse, error) {
+	ctx, span := tracing.Start(ctx, "WebhookUsecase.CreateWebhook")
+	r0, err := t.next.CreateWebhook(ctx, userId, req)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingWebhookUsecase) GetWebhooks(ctx context.Context, userId uint) ([]model.WebhookResponse, error) {
+	ctx, span := tracing.Start(ctx, "WebhookUsecase.GetWebhooks")
+	r0, err := t.next.GetWebhooks(ctx, userId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingWebhookUsecase) DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error {
+	ctx, span := tracing.Start(ctx, "WebhookUsecase.DeleteWebhook")
+	err := t.next.DeleteWebhook(ctx, userId, webhookId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingWebhookUsecase) GetDeliveries(ctx context.Context, userId uint, webhookId uint) ([]model.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookUsecase.GetDeliveries")
+	r0, err := t.next.GetDeliveries(ctx, userId, webhookId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingWebhookUsecase) Redeliver(ctx context.Context, userId uint, webhookId uint, deliveryId uint) (*model.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookUsecase.Redeliver")
+	r0, err := t.next.Redeliver(ctx, userId, webhookId, deliveryId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingWebhookUsecase) Publish(ctx context.Context, userId uint, eventType string, data interface{}) {
+	ctx, span := tracing.Start(ctx, "WebhookUsecase.Publish")
+	t.next.Publish(ctx, userId, eventType, data)
+	tracing.End(span, nil)
+}
+
+type tracingStatusUsecase struct {
+	next IStatusUsecase
+}
+
+func NewTracingStatusUsecase(next IStatusUsecase) IStatusUsecase {
+	return &tracingStatusUsecase{next}
+}
+
+func (t *tracingStatusUsecase) Ready(ctx context.Context) model.ReadinessResponse {
+	ctx, span := tracing.Start(ctx, "StatusUsecase.Ready")
+	r0 := t.next.Ready(ctx)
+	tracing.End(span, nil)
+	return r0
+}
+
+func (t *tracingStatusUsecase) DebugStatus(ctx context.Context) (*DebugStatusResponse, error) {
+	ctx, span := tracing.Start(ctx, "StatusUsecase.DebugStatus")
+	r0, err := t.next.DebugStatus(ctx)
+	tracing.End(span, err)
+	return r0, err
+}
+
+type tracingAccountUsecase struct {
+	next IAccountUsecase
+}
+
+func NewTracingAccountUsecase(next IAccountUsecase) IAccountUsecase {
+	return &tracingAccountUsecase{next}
+}
+
+func (t *tracingAccountUsecase) ForgotPassword(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "AccountUsecase.ForgotPassword")
+	err := t.next.ForgotPassword(ctx, email)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingAccountUsecase) ResetPassword(ctx context.Context, req model.PasswordResetRequest) error {
+	ctx, span := tracing.Start(ctx, "AccountUsecase.ResetPassword")
+	err := t.next.ResetPassword(ctx, req)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingAccountUsecase) SendVerification(ctx context.Context, userId uint) error {
+	ctx, span := tracing.Start(ctx, "AccountUsecase.SendVerification")
+	err := t.next.SendVerification(ctx, userId)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingAccountUsecase) SendEmailChange(ctx context.Context, user model.User, email string) error {
+	ctx, span := tracing.Start(ctx, "AccountUsecase.SendEmailChange")
+	err := t.next.SendEmailChange(ctx, user, email)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracingAccountUsecase) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "AccountUsecase.VerifyEmail")
+	err := t.next.VerifyEmail(ctx, token)
+	tracing.End(span, err)
+	return err
+}
+
+type tracingAccountDeletionUsecase struct {
+	next IAccountDeletionUsecase
+}
+
+func NewTracingAccountDeletionUsecase(next IAccountDeletionUsecase) IAccountDeletionUsecase {
+	return &tracingAccountDeletionUsecase{next}
+}
+
+func (t *tracingAccountDeletionUsecase) RequestDeletion(ctx context.Context, userId uint, req model.AccountDeletionRequest) (model.AccountDeletionResponse, error) {
+	ctx, span := tracing.Start(ctx, "AccountDeletionUsecase.RequestDeletion")
+	r0, err := t.next.RequestDeletion(ctx, userId, req)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingAccountDeletionUsecase) CancelDeletion(ctx context.Context, userId uint) error {
+	ctx, span := tracing.Start(ctx, "AccountDeletionUsecase.CancelDeletion")
+	err := t.next.CancelDeletion(ctx, userId)
+	tracing.End(span, err)
+	return err
+}
+
+// PurgeNextAccount は削除したアカウントごとにスパンを記録するのでそのまま呼び出す
+func (t *tracingAccountDeletionUsecase) PurgeNextAccount(ctx context.Context) (bool, error) {
+	return t.next.PurgeNextAccount(ctx)
+}
+
+type tracingExportUsecase struct {
+	next IExportUsecase
+}
+
+func NewTracingExportUsecase(next IExportUsecase) IExportUsecase {
+	return &tracingExportUsecase{next}
+}
+
+func (t *tracingExportUsecase) RequestExport(ctx context.Context, userId uint) (model.UserExportResponse, error) {
+	ctx, span := tracing.Start(ctx, "ExportUsecase.RequestExport")
+	r0, err := t.next.RequestExport(ctx, userId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingExportUsecase) GetExports(ctx context.Context, userId uint) ([]model.UserExportResponse, error) {
+	ctx, span := tracing.Start(ctx, "ExportUsecase.GetExports")
+	r0, err := t.next.GetExports(ctx, userId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+func (t *tracingExportUsecase) GetExportById(ctx context.Context, userId uint, exportId uint) (model.UserExportResponse, error) {
+	ctx, span := tracing.Start(ctx, "ExportUsecase.GetExportById")
+	r0, err := t.next.GetExportById(ctx, userId, exportId)
+	tracing.End(span, err)
+	return r0, err
+}
+
+// ProcessNextExport は作成したエクスポートごとにスパンを記録するのでそのまま呼び出す
+func (t *tracingExportUsecase) ProcessNextExport(ctx context.Context) (bool, error) {
+	return t.next.ProcessNextExport(ctx)
+}
@@ -1,35 +1,45 @@
 package usecase
 
 import (
-	"os"
-	"time"
+	"context"
+	"errors"
+	"log/slog"
 
-	"github.com/golang-jwt/jwt/v4"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/kenta-kenta/diary-music/apperror"
 	"github.com/kenta-kenta/diary-music/model"
 	"github.com/kenta-kenta/diary-music/repository"
 	"github.com/kenta-kenta/diary-music/validator"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type IUserUsecase interface {
-	SignUp(user model.User) (model.UserResponse, error)
-	Login(user model.User) (string, error)
-	GetUserById(user *model.User, userId uint) error
+	SignUp(ctx context.Context, user model.User) (model.UserResponse, error)
+	Login(ctx context.Context, user model.User, client model.SessionClient) (model.AuthTokens, error)
+	GetUserById(ctx context.Context, user *model.User, userId uint) error
+	UpdateProfile(ctx context.Context, userId uint, req model.UserProfileRequest) (model.UserResponse, error)
+	// ChangeEmail は変更後のメールアドレスに確認のメールを送る（確認されるまでメールアドレスは変わらない）
+	ChangeEmail(ctx context.Context, userId uint, req model.EmailChangeRequest) error
+	// ChangePassword はパスワードを変更し、sessionId 以外のセッションを無効にする
+	ChangePassword(ctx context.Context, userId uint, sessionId uint, req model.PasswordChangeRequest) error
 }
 
 type userUsecase struct {
 	ur repository.IUserRepository
 	uv validator.IUserValidator
+	su ISessionUsecase
+	au IAccountUsecase
 }
 
-func NewUserUsecase(ur repository.IUserRepository, uv validator.IUserValidator) IUserUsecase {
-	return &userUsecase{ur, uv}
+func NewUserUsecase(ur repository.IUserRepository, uv validator.IUserValidator, su ISessionUsecase, au IAccountUsecase) IUserUsecase {
+	return &userUsecase{ur, uv, su, au}
 }
 
-func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
+func (uu *userUsecase) SignUp(ctx context.Context, user model.User) (model.UserResponse, error) {
 	// ユーザー情報のバリデーション
-	if err := uu.uv.UserValidate(user); err != nil {
-		return model.UserResponse{}, err
+	if err := uu.uv.SignUpValidate(user); err != nil {
+		return model.UserResponse{}, apperror.Validation(err)
 	}
 	// パスワードのハッシュ化
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 10)
@@ -38,49 +48,124 @@ func (uu *userUsecase) SignUp(user model.User) (model.UserResponse, error) {
 	}
 	// ユーザー情報の作成
 	newUser := model.User{UserName: user.UserName, Email: user.Email, Password: string(hash)}
-	if err := uu.ur.CreateUser(&newUser); err != nil {
+	if err := uu.ur.CreateUser(ctx, &newUser); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return model.UserResponse{}, ErrEmailTaken.Wrap(err)
+		}
 		return model.UserResponse{}, err
 	}
-	resUser := model.UserResponse{
-		ID:       newUser.ID,
-		Email:    newUser.Email,
-		UserName: newUser.UserName,
+	// 確認メールを送る（送れなかった場合は POST /email/verify/resend で再送できる）
+	if err := uu.au.SendVerification(ctx, newUser.ID); err != nil {
+		slog.WarnContext(ctx, "failed to send verification mail", "user_id", newUser.ID, "error", err)
 	}
-	return resUser, nil
+	return userResponse(newUser), nil
 }
 
-func (uu *userUsecase) Login(user model.User) (string, error) {
+func (uu *userUsecase) Login(ctx context.Context, user model.User, client model.SessionClient) (model.AuthTokens, error) {
 	// ユーザー情報のバリデーション
-	if err := uu.uv.UserValidate(user); err != nil {
-		return "", err
+	if err := uu.uv.LoginValidate(user); err != nil {
+		return model.AuthTokens{}, apperror.Validation(err)
 	}
 
 	// ユーザー情報の取得
 	storedUser := model.User{}
-	if err := uu.ur.GetUserByEmail(&storedUser, user.Email); err != nil {
-		return "", err
+	// メールアドレスが登録されているかどうかは区別せずに返す
+	if err := uu.ur.GetUserByEmail(ctx, &storedUser, user.Email); err != nil {
+		return model.AuthTokens{}, notFound(err, ErrInvalidCredentials)
 	}
 	// パスワードの比較
 	err := bcrypt.CompareHashAndPassword([]byte(storedUser.Password), []byte(user.Password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return model.AuthTokens{}, ErrInvalidCredentials.Wrap(err)
+	}
 	if err != nil {
-		return "", err
-	}
-	// JWTトークンの作成
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": storedUser.ID,                         // ユーザーID
-		"exp":     time.Now().Add(time.Hour * 12).Unix(), // 有効期限
-	})
-	tokenString, err := token.SignedString([]byte(os.Getenv("SECRET")))
-	if err != nil {
-		return "", err
+		return model.AuthTokens{}, err
 	}
-	return tokenString, nil
+	// セッションを作ってアクセストークンとリフレッシュトークンを発行
+	return uu.su.CreateSession(ctx, storedUser.ID, client)
 }
 
-func (uu *userUsecase) GetUserById(user *model.User, userId uint) error {
+func (uu *userUsecase) GetUserById(ctx context.Context, user *model.User, userId uint) error {
 	// リポジトリ層のメソッドを呼び出し
-	if err := uu.ur.GetUserById(user, userId); err != nil {
-		return err
+	if err := uu.ur.GetUserById(ctx, user, userId); err != nil {
+		return notFound(err, ErrUserNotFound)
 	}
 	return nil
 }
+
+func (uu *userUsecase) UpdateProfile(ctx context.Context, userId uint, req model.UserProfileRequest) (model.UserResponse, error) {
+	if err := uu.uv.ProfileValidate(req); err != nil {
+		return model.UserResponse{}, apperror.Validation(err)
+	}
+	user := model.User{UserName: req.UserName}
+	if err := uu.ur.UpdateUserName(ctx, &user, userId); err != nil {
+		return model.UserResponse{}, notFound(err, ErrUserNotFound)
+	}
+	return userResponse(user), nil
+}
+
+func (uu *userUsecase) ChangeEmail(ctx context.Context, userId uint, req model.EmailChangeRequest) error {
+	if err := uu.uv.EmailChangeValidate(req); err != nil {
+		return apperror.Validation(err)
+	}
+	user := model.User{}
+	if err := uu.ur.GetUserById(ctx, &user, userId); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if err := checkPassword(user, req.Password); err != nil {
+		return err
+	}
+	if req.Email == user.Email {
+		return apperror.Validation(validation.Errors{"email": errors.New("Email is the same as the current one")})
+	}
+	// 登録済みのメールアドレスは確認のメールを送る前に断る（確認までに登録された場合は確認時に断る）
+	err := uu.ur.GetUserByEmail(ctx, &model.User{}, req.Email)
+	if err == nil {
+		return ErrEmailTaken
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return uu.au.SendEmailChange(ctx, user, req.Email)
+}
+
+func (uu *userUsecase) ChangePassword(ctx context.Context, userId uint, sessionId uint, req model.PasswordChangeRequest) error {
+	if err := uu.uv.PasswordChangeValidate(req); err != nil {
+		return apperror.Validation(err)
+	}
+	user := model.User{}
+	if err := uu.ur.GetUserById(ctx, &user, userId); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if err := checkPassword(user, req.CurrentPassword); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), 10)
+	if err != nil {
+		return err
+	}
+	if err := uu.ur.UpdatePassword(ctx, userId, string(hash)); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	// 変更した端末以外はログインし直す必要がある
+	return uu.su.RevokeOtherSessions(ctx, userId, sessionId, model.SessionRevokedPasswordChange)
+}
+
+// checkPassword はログイン中のユーザーが入力した現在のパスワードを確認する
+func checkPassword(user model.User, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidPassword.Wrap(err)
+	}
+	return err
+}
+
+func userResponse(user model.User) model.UserResponse {
+	return model.UserResponse{
+		ID:                  user.ID,
+		Email:               user.Email,
+		UserName:            user.UserName,
+		EmailVerified:       user.EmailVerifiedAt != nil,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+	}
+}
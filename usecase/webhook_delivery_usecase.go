@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/service"
+	"github.com/kenta-kenta/diary-music/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 最初の送信を含む最大試行回数
+const maxWebhookAttempts = 6
+
+// webhookRetryPolicy は送信に失敗した場合の再送間隔（30秒から最大1時間）
+var webhookRetryPolicy = service.RetryPolicy{
+	BaseDelay: 30 * time.Second,
+	MaxDelay:  time.Hour,
+}
+
+type IWebhookDeliveryUsecase interface {
+	// ProcessNextDelivery は送信待ちの配信を1件送信する。送信するものがなかった場合は false を返す
+	ProcessNextDelivery(ctx context.Context) (bool, error)
+}
+
+type webhookDeliveryUsecase struct {
+	wr         repository.IWebhookRepository
+	ws         service.IWebhookSender
+	staleAfter time.Duration
+}
+
+func NewWebhookDeliveryUsecase(wr repository.IWebhookRepository, ws service.IWebhookSender) IWebhookDeliveryUsecase {
+	return &webhookDeliveryUsecase{wr, ws, 5 * time.Minute}
+}
+
+func (wu *webhookDeliveryUsecase) ProcessNextDelivery(ctx context.Context) (bool, error) {
+	delivery, webhook, err := wu.wr.ClaimNextDelivery(ctx, wu.staleAfter)
+	if err != nil {
+		return false, err
+	}
+	if delivery == nil {
+		return false, nil
+	}
+
+	sendCtx, span := tracing.Start(ctx, "WebhookDeliveryUsecase.Send",
+		attribute.Int64("webhook.id", int64(webhook.ID)),
+		attribute.Int64("webhook.delivery_id", int64(delivery.ID)),
+		attribute.String("webhook.event", delivery.EventType),
+		attribute.Int("webhook.attempt", delivery.Attempts),
+	)
+	status, err := wu.ws.Send(sendCtx, webhook.URL, webhook.Secret, delivery.EventType, strconv.FormatUint(uint64(delivery.ID), 10), []byte(delivery.Payload))
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	tracing.End(span, err)
+	delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		now := time.Now()
+		delivery.Status = model.WebhookDeliverySucceeded
+		delivery.Error = ""
+		delivery.DeliveredAt = &now
+	case ctx.Err() != nil:
+		// 停止のため中断した。次に起動したワーカーがすぐに送り直す
+		delivery.Status = model.WebhookDeliveryPending
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now()
+	case delivery.Attempts >= maxWebhookAttempts:
+		slog.WarnContext(ctx, "webhook delivery failed permanently", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "attempts", delivery.Attempts, "error", err)
+		delivery.Status = model.WebhookDeliveryFailed
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = model.WebhookDeliveryPending
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(webhookRetryPolicy.BaseDelay + webhookRetryPolicy.Backoff(delivery.Attempts))
+	}
+	// 停止のため中断しても結果を記録できるよう、キャンセルされない context を使う
+	return true, wu.wr.UpdateDelivery(context.WithoutCancel(ctx), delivery)
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/apperror"
+	"github.com/kenta-kenta/diary-music/model"
+	"github.com/kenta-kenta/diary-music/repository"
+	"github.com/kenta-kenta/diary-music/validator"
+)
+
+// 配信履歴として返す件数
+const webhookDeliveryHistory = 50
+
+type IWebhookUsecase interface {
+	CreateWebhook(ctx context.Context, userId uint, req model.WebhookRequest) (model.WebhookResponse, error)
+	GetWebhooks(ctx context.Context, userId uint) ([]model.WebhookResponse, error)
+	DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error
+	GetDeliveries(ctx context.Context, userId uint, webhookId uint) ([]model.WebhookDelivery, error)
+	Redeliver(ctx context.Context, userId uint, webhookId uint, deliveryId uint) (*model.WebhookDelivery, error)
+	// Publish はイベントを購読しているWebhookへの配信を登録する（IEventPublisher）
+	Publish(ctx context.Context, userId uint, eventType string, data interface{})
+}
+
+type webhookUsecase struct {
+	wr repository.IWebhookRepository
+	wv validator.IWebhookValidator
+	jn IJobNotifier
+}
+
+func NewWebhookUsecase(wr repository.IWebhookRepository, wv validator.IWebhookValidator, jn IJobNotifier) IWebhookUsecase {
+	return &webhookUsecase{wr, wv, jn}
+}
+
+func (wu *webhookUsecase) CreateWebhook(ctx context.Context, userId uint, req model.WebhookRequest) (model.WebhookResponse, error) {
+	if err := wu.wv.WebhookValidate(req); err != nil {
+		return model.WebhookResponse{}, apperror.Validation(err)
+	}
+	// シークレットが指定されていなければ生成する
+	if req.Secret == "" {
+		secret, err := randomHex(32)
+		if err != nil {
+			return model.WebhookResponse{}, err
+		}
+		req.Secret = secret
+	}
+	if len(req.Events) == 0 {
+		req.Events = model.WebhookEvents
+	}
+
+	webhook := model.Webhook{
+		UserID: userId,
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: strings.Join(req.Events, ","),
+		Active: true,
+	}
+	if err := wu.wr.CreateWebhook(ctx, &webhook); err != nil {
+		return model.WebhookResponse{}, err
+	}
+
+	// シークレットは作成時のみ返す
+	res := toWebhookResponse(webhook)
+	res.Secret = webhook.Secret
+	return res, nil
+}
+
+func (wu *webhookUsecase) GetWebhooks(ctx context.Context, userId uint) ([]model.WebhookResponse, error) {
+	webhooks, err := wu.wr.GetWebhooks(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]model.WebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		res = append(res, toWebhookResponse(webhook))
+	}
+	return res, nil
+}
+
+func (wu *webhookUsecase) DeleteWebhook(ctx context.Context, userId uint, webhookId uint) error {
+	return notFound(wu.wr.DeleteWebhook(ctx, userId, webhookId), ErrWebhookNotFound)
+}
+
+func (wu *webhookUsecase) GetDeliveries(ctx context.Context, userId uint, webhookId uint) ([]model.WebhookDelivery, error) {
+	webhook := model.Webhook{}
+	if err := wu.wr.GetWebhookById(ctx, &webhook, userId, webhookId); err != nil {
+		return nil, notFound(err, ErrWebhookNotFound)
+	}
+	return wu.wr.GetDeliveries(ctx, userId, webhookId, webhookDeliveryHistory)
+}
+
+// Redeliver は過去の配信と同じ内容を新しい配信として登録する（元の配信履歴は残す）
+func (wu *webhookUsecase) Redeliver(ctx context.Context, userId uint, webhookId uint, deliveryId uint) (*model.WebhookDelivery, error) {
+	original := model.WebhookDelivery{}
+	if err := wu.wr.GetDeliveryById(ctx, &original, userId, webhookId, deliveryId); err != nil {
+		return nil, notFound(err, ErrWebhookDeliveryNotFound)
+	}
+	delivery := &model.WebhookDelivery{
+		WebhookID:     original.WebhookID,
+		UserID:        original.UserID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        model.WebhookDeliveryPending,
+		RedeliveryOf:  &original.ID,
+		NextAttemptAt: time.Now(),
+	}
+	if err := wu.wr.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	wu.jn.Notify()
+	return delivery, nil
+}
+
+func (wu *webhookUsecase) Publish(ctx context.Context, userId uint, eventType string, data interface{}) {
+	if !isWebhookEvent(eventType) {
+		return
+	}
+	webhooks, err := wu.wr.GetActiveWebhooks(ctx, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load webhooks", "user_id", userId, "error", err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, webhook := range webhooks {
+		if !subscribes(webhook, eventType) {
+			continue
+		}
+		// 同じイベントはどのWebhookにも同じIDで送る
+		if payload == nil {
+			eventId, err := randomHex(16)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to generate webhook event id", "error", err)
+				return
+			}
+			payload, err = json.Marshal(model.WebhookPayload{
+				ID:        "evt_" + eventId,
+				Type:      eventType,
+				CreatedAt: time.Now(),
+				Data:      data,
+			})
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to encode webhook payload", "event", eventType, "error", err)
+				return
+			}
+		}
+		delivery := model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			UserID:        userId,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        model.WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := wu.wr.CreateDelivery(ctx, &delivery); err != nil {
+			slog.ErrorContext(ctx, "failed to queue webhook delivery", "webhook_id", webhook.ID, "user_id", userId, "error", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		wu.jn.Notify()
+	}
+}
+
+func toWebhookResponse(webhook model.Webhook) model.WebhookResponse {
+	return model.WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    strings.Split(webhook.Events, ","),
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, e := range model.WebhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func subscribes(webhook model.Webhook, eventType string) bool {
+	for _, e := range strings.Split(webhook.Events, ",") {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,41 @@
+package validator
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+type IMusicValidator interface {
+	MusicRequestValidate(req model.MusicRequest) error
+}
+
+type musicValidator struct{}
+
+func NewMusicValidator() IMusicValidator {
+	return &musicValidator{}
+}
+
+func (mv *musicValidator) MusicRequestValidate(req model.MusicRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.Prompt,
+			validation.RuneLength(0, 1000).Error("Prompt must be at most 1000 characters"),
+		),
+		validation.Field(
+			&req.Lyrics,
+			validation.RuneLength(0, 3000).Error("Lyrics must be at most 3000 characters"),
+		),
+		validation.Field(
+			&req.Title,
+			validation.RuneLength(0, 100).Error("Title must be at most 100 characters"),
+		),
+		validation.Field(
+			&req.IsAuto,
+			validation.In(0, 1).Error("IsAuto must be 0 or 1"),
+		),
+		validation.Field(
+			&req.Instrumental,
+			validation.In(0, 1).Error("Instrumental must be 0 or 1"),
+		),
+	)
+}
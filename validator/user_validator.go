@@ -6,8 +6,18 @@ import (
 	"github.com/kenta-kenta/diary-music/model"
 )
 
+// IUserValidator は用途ごとにルールを分ける（ログインでは登録時の長さの制限をかけない）
 type IUserValidator interface {
-	UserValidate(user model.User) error
+	SignUpValidate(user model.User) error
+	LoginValidate(user model.User) error
+	ProfileValidate(req model.UserProfileRequest) error
+	EmailChangeValidate(req model.EmailChangeRequest) error
+	PasswordChangeValidate(req model.PasswordChangeRequest) error
+	DeletionValidate(req model.AccountDeletionRequest) error
+	// EmailValidate はパスワード再設定のメールアドレスを検証する
+	EmailValidate(email string) error
+	// PasswordValidate は再設定するパスワードを検証する
+	PasswordValidate(password string) error
 }
 
 type userValidator struct{}
@@ -16,19 +26,69 @@ func NewUserValidator() IUserValidator {
 	return &userValidator{}
 }
 
-// UserValidate validates the user
-func (uv *userValidator) UserValidate(user model.User) error {
+var emailRules = []validation.Rule{
+	validation.Required.Error("Email is required"),                              // Error message when email is empty
+	validation.Length(1, 30).Error("Email must be between 1 and 30 characters"), // Error message when email is not between 1 and 30 characters
+	is.Email.Error("Email is invalid"),                                          // Error message when email is invalid
+}
+
+var passwordRules = []validation.Rule{
+	validation.Required.Error("Password is required"),                              // Error message when password is empty
+	validation.Length(6, 30).Error("Password must be between 6 and 30 characters"), // Error message when password is not between 6 and 30 characters
+}
+
+var userNameRules = []validation.Rule{
+	validation.Required.Error("User name is required"),
+	validation.RuneLength(1, 30).Error("User name must be between 1 and 30 characters"),
+}
+
+// SignUpValidate はメールアドレス・パスワード・ユーザー名を検証する
+func (uv *userValidator) SignUpValidate(user model.User) error {
 	return validation.ValidateStruct(&user,
-		validation.Field(
-			&user.Email,
-			validation.Required.Error("Email is required"),                              // Error message when email is empty
-			validation.Length(1, 30).Error("Email must be between 1 and 30 characters"), // Error message when email is not between 1 and 30 characters
-			is.Email.Error("Email is invalid"),                                          // Error message when email is invalid
-		),
-		validation.Field(
-			&user.Password,
-			validation.Required.Error("Password is required"),                              // Error message when password is empty
-			validation.Length(6, 30).Error("Password must be between 6 and 30 characters"), // Error message when password is not between 6 and 30 characters
-		),
+		validation.Field(&user.Email, emailRules...),
+		validation.Field(&user.Password, passwordRules...),
+		validation.Field(&user.UserName, userNameRules...),
+	)
+}
+
+// LoginValidate は入力があるかだけを検証する（ルールを変更する前に登録したパスワードでもログインできるように）
+func (uv *userValidator) LoginValidate(user model.User) error {
+	return validation.ValidateStruct(&user,
+		validation.Field(&user.Email, validation.Required.Error("Email is required"), is.Email.Error("Email is invalid")),
+		validation.Field(&user.Password, validation.Required.Error("Password is required")),
+	)
+}
+
+func (uv *userValidator) ProfileValidate(req model.UserProfileRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.UserName, userNameRules...),
 	)
 }
+
+func (uv *userValidator) EmailChangeValidate(req model.EmailChangeRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Email, emailRules...),
+		validation.Field(&req.Password, validation.Required.Error("Password is required")),
+	)
+}
+
+func (uv *userValidator) PasswordChangeValidate(req model.PasswordChangeRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.CurrentPassword, validation.Required.Error("Current password is required")),
+		validation.Field(&req.NewPassword, passwordRules...),
+	)
+}
+
+func (uv *userValidator) DeletionValidate(req model.AccountDeletionRequest) error {
+	return validation.ValidateStruct(&req,
+		validation.Field(&req.Password, validation.Required.Error("Password is required")),
+	)
+}
+
+func (uv *userValidator) EmailValidate(email string) error {
+	return validation.Errors{"email": validation.Validate(email, emailRules...)}.Filter()
+}
+
+func (uv *userValidator) PasswordValidate(password string) error {
+	return validation.Errors{"password": validation.Validate(password, passwordRules...)}.Filter()
+}
@@ -0,0 +1,43 @@
+package validator
+
+import (
+	"regexp"
+
+	"github.com/go-ozzo/ozzo-validation/is"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+type IWebhookValidator interface {
+	WebhookValidate(req model.WebhookRequest) error
+}
+
+type webhookValidator struct{}
+
+func NewWebhookValidator() IWebhookValidator {
+	return &webhookValidator{}
+}
+
+func (wv *webhookValidator) WebhookValidate(req model.WebhookRequest) error {
+	events := make([]interface{}, len(model.WebhookEvents))
+	for i, e := range model.WebhookEvents {
+		events[i] = e
+	}
+	return validation.ValidateStruct(&req,
+		validation.Field(
+			&req.URL,
+			validation.Required.Error("URL is required"),
+			validation.Length(1, 500).Error("URL must be between 1 and 500 characters"),
+			is.URL.Error("URL is invalid"),
+			validation.Match(regexp.MustCompile(`^https?://`)).Error("URL must start with http:// or https://"),
+		),
+		validation.Field(
+			&req.Secret,
+			validation.Length(16, 128).Error("Secret must be between 16 and 128 characters"),
+		),
+		validation.Field(
+			&req.Events,
+			validation.Each(validation.In(events...).Error("Event is not supported")),
+		),
+	)
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kenta-kenta/diary-music/model"
+)
+
+// ProcessFunc はキューから1件取り出して処理する。処理するものがなかった場合は false を返す。
+// ctx がキャンセルされた場合（停止の期限切れ）は処理中の1件をキューに戻すこと
+type ProcessFunc func(ctx context.Context) (bool, error)
+
+// Pool はDBに永続化されたキューを並行して処理するワーカープール
+type Pool struct {
+	name         string
+	process      ProcessFunc
+	size         int
+	pollInterval time.Duration
+	wake         chan struct{}
+	stop         chan struct{}
+	stopOnce     sync.Once
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	busy         atomic.Int64
+	processed    atomic.Int64
+	errors       atomic.Int64
+}
+
+func NewPool(name string, size int, process ProcessFunc) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{
+		name:         name,
+		process:      process,
+		size:         size,
+		pollInterval: 5 * time.Second,
+		wake:         make(chan struct{}, size),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start はワーカーを起動する。ctx は処理に渡すコンテキストの親で、停止には Stop を使う
+func (p *Pool) Start(ctx context.Context) error {
+	ctx, p.cancel = context.WithCancel(ctx)
+	for i := 0; i < p.size; i++ {
+		p.wg.Add(1)
+		go p.run(ctx)
+	}
+	return nil
+}
+
+// Stop は新しい仕事の取り出しをやめ、処理中の仕事が終わるのを待つ。
+// ctx の期限までに終わらない場合は処理中の仕事のコンテキストをキャンセルし、キューに戻されるのを待つ
+func (p *Pool) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	slog.WarnContext(ctx, "drain deadline exceeded, cancelling in-flight work", "worker", p.name)
+	if p.cancel != nil {
+		p.cancel()
+	}
+	<-done
+	return ctx.Err()
+}
+
+// Notify は待機中のワーカーを起こす。ワーカーが全員処理中の場合は何もしない
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats はプールの統計を返す
+func (p *Pool) Stats() model.PoolStats {
+	return model.PoolStats{
+		Name:      p.name,
+		Size:      p.size,
+		Busy:      p.busy.Load(),
+		Processed: p.processed.Load(),
+		Errors:    p.errors.Load(),
+	}
+}
+
+func (p *Pool) stopping() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Pool) run(ctx context.Context) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// キューが空になるまで続けて処理する
+		for !p.stopping() && ctx.Err() == nil {
+			p.busy.Add(1)
+			processed, err := p.process(ctx)
+			p.busy.Add(-1)
+			if err != nil {
+				p.errors.Add(1)
+				slog.ErrorContext(ctx, "worker failed", "worker", p.name, "error", err)
+				break
+			}
+			if !processed {
+				break
+			}
+			p.processed.Add(1)
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}